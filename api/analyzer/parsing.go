@@ -4,21 +4,27 @@ import (
 	"context"
 	"github.com/fadyat/i4u/internal/entity"
 	"strings"
+	"sync/atomic"
 )
 
 type KeywordsAnalyzer struct {
-	keywords []string
+	keywords atomic.Pointer[[]string]
 }
 
 func NewKWAnalyzer(keywords []string) *KeywordsAnalyzer {
-	return &KeywordsAnalyzer{
-		keywords: keywords,
-	}
+	a := &KeywordsAnalyzer{}
+	a.SetKeywords(keywords)
+	return a
+}
+
+// SetKeywords replaces the keywords of the next analyzed messages.
+func (a *KeywordsAnalyzer) SetKeywords(keywords []string) {
+	a.keywords.Store(&keywords)
 }
 
 func (a *KeywordsAnalyzer) IsInternshipRequest(_ context.Context, msg entity.Message) (bool, error) {
 	body := strings.ToLower(msg.Body())
-	for _, keyword := range a.keywords {
+	for _, keyword := range *a.keywords.Load() {
 		if strings.Contains(body, keyword) {
 			return true, nil
 		}
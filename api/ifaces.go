@@ -9,6 +9,10 @@ type Mail interface {
 	GetUnreadMsgs(ctx context.Context) <-chan entity.MessageWithError
 	LabelMsg(context.Context, entity.MessageForLabeler) error
 	CreateLabel(context.Context, string) (*entity.Label, error)
+
+	// ModifyLabels adds and removes labels of the message, used for
+	// relabeling and archiving messages after the user feedback.
+	ModifyLabels(ctx context.Context, msgID string, add []string, remove []string) error
 }
 
 type Analyzer interface {
@@ -3,17 +3,17 @@ package mail
 import (
 	"context"
 	"fmt"
-	"github.com/fadyat/i4u/api"
 	"github.com/fadyat/i4u/cmd/i4u/token"
 	"github.com/fadyat/i4u/internal/config"
 	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/tracing"
 	"github.com/fadyat/i4u/pkg/syncs"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
 	"sync"
-	"time"
 )
 
 type GmailClient struct {
@@ -22,6 +22,9 @@ type GmailClient struct {
 	oauthConfig *oauth2.Config
 	s           *gmail.Service
 
+	// tokens keeps the refreshed token, so it survives the restarts.
+	tokens *token.Store
+
 	// tknMtx is used to prevent concurrent access to the token file
 	// when it is being refreshed.
 	tknMtx sync.Mutex
@@ -31,15 +34,22 @@ func NewGmailClient(
 	tkn *oauth2.Token,
 	oauthConfig *oauth2.Config,
 	gmailConfig *config.Gmail,
-) api.Mail {
+) *GmailClient {
 	return &GmailClient{
 		token:       tkn,
 		oauthConfig: oauthConfig,
 		cfg:         gmailConfig,
+		tokens:      token.NewStore(gmailConfig.TokenFile, nil),
 		tknMtx:      sync.Mutex{},
 	}
 }
 
+// WithTokens replaces the plain token file, like with the encrypted one.
+func (g *GmailClient) WithTokens(tokens *token.Store) *GmailClient {
+	g.tokens = tokens
+	return g
+}
+
 func (g *GmailClient) LabelMsg(ctx context.Context, msg entity.MessageForLabeler) error {
 	if err := g.refreshToken(ctx); err != nil {
 		return fmt.Errorf("failed to refresh access token: %w", err)
@@ -56,6 +66,25 @@ func (g *GmailClient) LabelMsg(ctx context.Context, msg entity.MessageForLabeler
 	return err
 }
 
+func (g *GmailClient) ModifyLabels(
+	ctx context.Context, msgID string, add, remove []string,
+) error {
+	if err := g.refreshToken(ctx); err != nil {
+		return fmt.Errorf("failed to refresh access token: %w", err)
+	}
+
+	_, err := g.s.Users.Messages.Modify(
+		"me",
+		msgID,
+		&gmail.ModifyMessageRequest{
+			AddLabelIds:    add,
+			RemoveLabelIds: remove,
+		},
+	).Context(ctx).Do()
+
+	return err
+}
+
 func (g *GmailClient) CreateLabel(
 	ctx context.Context, labelName string,
 ) (*entity.Label, error) {
@@ -83,9 +112,10 @@ func (g *GmailClient) refreshToken(ctx context.Context) error {
 	if !g.token.Valid() || g.s == nil {
 		g.s, _ = gmail.NewService(
 			context.Background(),
-			option.WithTokenSource(
+			option.WithHTTPClient(oauth2.NewClient(
+				context.WithValue(context.Background(), oauth2.HTTPClient, tracing.HTTPClient()),
 				oauth2.StaticTokenSource(newToken),
-			),
+			)),
 			option.WithScopes(
 				gmail.GmailReadonlyScope,
 				gmail.GmailLabelsScope,
@@ -93,12 +123,35 @@ func (g *GmailClient) refreshToken(ctx context.Context) error {
 			),
 		)
 	}
+	// the token is saved only, when it's refreshed, the encryption of
+	// the token file is too slow for every request.
+	refreshed := newToken.AccessToken != g.token.AccessToken
 	g.token = newToken
+	if !refreshed {
+		return nil
+	}
 
 	g.tknMtx.Lock()
 	defer g.tknMtx.Unlock()
 
-	return token.Save(g.cfg.TokenFile, newToken)
+	return g.tokens.Save(newToken)
+}
+
+// CheckToken makes sure, that the access token can be refreshed,
+// it fails, when the user revoked the access.
+func (g *GmailClient) CheckToken(ctx context.Context) error {
+	_, err := g.oauthConfig.TokenSource(ctx, g.token).Token()
+	return err
+}
+
+// Ping checks, that the Gmail API is reachable with the current token.
+func (g *GmailClient) Ping(ctx context.Context) error {
+	if err := g.refreshToken(ctx); err != nil {
+		return fmt.Errorf("failed to refresh access token: %w", err)
+	}
+
+	_, err := g.s.Users.GetProfile("me").Fields("emailAddress").Context(ctx).Do()
+	return err
 }
 
 func (g *GmailClient) GetUnreadMsgs(ctx context.Context) <-chan entity.MessageWithError {
@@ -134,12 +187,18 @@ func (g *GmailClient) getFullMessageContent(
 	id string,
 	wrappedMsgsCh chan<- entity.MessageWithError,
 ) {
+	// every message starts its own trace, which is continued by the stages.
+	ctx, span := tracing.Start(ctx, "gmail.get_message",
+		trace.WithNewRoot(), trace.WithAttributes(tracing.AttrMessageID.String(id)))
+	defer span.End()
+
 	msg, err := g.s.Users.Messages.Get("me", id).
 		Format("full").
 		Context(ctx).
 		Do()
 
 	if err != nil {
+		tracing.Fail(span, err)
 		wrappedMsgsCh <- entity.MessageWithError{
 			Err: fmt.Errorf("failed to get message: %w", err),
 		}
@@ -148,6 +207,7 @@ func (g *GmailClient) getFullMessageContent(
 
 	parsed, e := entity.NewMsgFromGmailMessage(msg)
 	if e != nil {
+		tracing.Fail(span, e)
 		wrappedMsgsCh <- entity.MessageWithError{
 			Err: fmt.Errorf("failed to parse message: %w", e),
 		}
@@ -156,7 +216,7 @@ func (g *GmailClient) getFullMessageContent(
 
 	zap.S().Debugf("got message: %s", parsed.ID())
 	wrappedMsgsCh <- entity.MessageWithError{
-		Msg: parsed.WithLabel(g.cfg.L.I4U),
+		Msg: parsed.WithLabel(g.cfg.L.I4U).WithTraceParent(tracing.TraceParent(ctx)),
 	}
 }
 
@@ -168,7 +228,7 @@ func (g *GmailClient) getUnreadMsgs(
 	wrappedMsgsCh chan<- entity.MessageWithError,
 ) error {
 	unread, err := g.s.Users.Messages.List("me").
-		Q("in:inbox -label:i4u").
+		Q(g.cfg.Query).
 		MaxResults(g.cfg.MessagesLimit).
 		Context(ctx).
 		Do()
@@ -181,12 +241,9 @@ func (g *GmailClient) getUnreadMsgs(
 	for _, msg := range unread.Messages {
 		id := msg.Id
 
-		wg.Go(func() {
-			timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-
-			g.getFullMessageContent(timeout, id, wrappedMsgsCh)
-		})
+		// the caller's context bounds the whole fetch,
+		// including the content of every message.
+		wg.Go(func() { g.getFullMessageContent(ctx, id, wrappedMsgsCh) })
 	}
 
 	wg.Wait()
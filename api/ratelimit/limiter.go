@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/fadyat/i4u/internal/config"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket of the single provider quota, the budget
+// is restored continuously and can't be saved up more than the burst.
+//
+// The budget may go below zero, when the cost is known only after
+// the call, the next calls wait, until the debt is paid off.
+//
+// Limiter with zero rate doesn't limit the calls.
+type Limiter struct {
+	name  string
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// blockedUntil is set, when the provider asks to slow down.
+	blockedUntil time.Time
+
+	now func() time.Time
+}
+
+func New(name string, rate config.Rate) *Limiter {
+	now := time.Now
+	return &Limiter{
+		name:   name,
+		rate:   rate.PerSecond(),
+		burst:  rate.Burst,
+		tokens: rate.Burst,
+		last:   now(),
+		now:    now,
+	}
+}
+
+// Budget is a snapshot of the limiter state.
+type Budget struct {
+	Name      string
+	Remaining float64
+	Burst     float64
+
+	// BlockedUntil is zero, when the provider didn't ask to slow down.
+	BlockedUntil time.Time
+}
+
+func (l *Limiter) Name() string {
+	return l.name
+}
+
+// Budget returns how much can be spent right now.
+func (l *Limiter) Budget() Budget {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.refill(now)
+
+	b := Budget{Name: l.name, Remaining: l.tokens, Burst: l.burst}
+	if l.blockedUntil.After(now) {
+		b.BlockedUntil = l.blockedUntil
+	}
+
+	return b
+}
+
+// Wait blocks until the cost can be spent, or the context is done.
+func (l *Limiter) Wait(ctx context.Context, cost float64) error {
+	for {
+		delay := l.reserve(cost)
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Take spends the cost without waiting, used, when the call
+// was already made and its cost became known only after it.
+func (l *Limiter) Take(cost float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return
+	}
+
+	l.refill(l.now())
+	l.tokens -= cost
+}
+
+// Block stops spending the budget for the duration,
+// used, when the provider asks to retry later.
+func (l *Limiter) Block(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := l.now().Add(d); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// reserve spends the cost, when it's possible,
+// otherwise returns the delay before the next try.
+func (l *Limiter) reserve(cost float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.blockedUntil.After(now) {
+		return l.blockedUntil.Sub(now)
+	}
+
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.refill(now)
+
+	// the cost more than the burst is allowed, when the bucket
+	// is full, otherwise such calls would never be made.
+	need := min(cost, l.burst)
+	if l.tokens >= need {
+		l.tokens -= cost
+		return 0
+	}
+
+	return time.Duration((need - l.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *Limiter) refill(now time.Time) {
+	if l.rate <= 0 {
+		return
+	}
+
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.burst, l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+}
+
+// waitAll waits for the cost in every limiter one by one.
+func waitAll(ctx context.Context, cost float64, limiters []*Limiter) error {
+	for _, l := range limiters {
+		if err := l.Wait(ctx, cost); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
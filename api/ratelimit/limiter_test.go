@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// fakeClock is moved manually, so the limiter is tested without sleeping.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(rate config.Rate) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)}
+	l := New("test", rate)
+	l.now, l.last = clock.Now, clock.now
+
+	return l, clock
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	l, clock := newTestLimiter(config.Rate{Limit: 10, Per: time.Second, Burst: 2})
+
+	assert.Zero(t, l.reserve(1))
+	assert.Zero(t, l.reserve(1))
+	assert.Equal(t, 100*time.Millisecond, l.reserve(1))
+
+	clock.now = clock.now.Add(100 * time.Millisecond)
+	assert.Zero(t, l.reserve(1))
+
+	// the budget isn't saved up more than the burst.
+	clock.now = clock.now.Add(time.Hour)
+	assert.Equal(t, 2.0, l.Budget().Remaining)
+
+	// the cost more than the burst is paid, when the bucket is full.
+	assert.Zero(t, l.reserve(5))
+	assert.Equal(t, -3.0, l.Budget().Remaining)
+	assert.Equal(t, 500*time.Millisecond, l.reserve(2))
+}
+
+func TestLimiter_Take(t *testing.T) {
+	l, clock := newTestLimiter(config.Rate{Limit: 1, Per: time.Second, Burst: 1})
+
+	l.Take(3)
+	assert.Equal(t, -2.0, l.Budget().Remaining)
+	assert.Equal(t, 3*time.Second, l.reserve(1))
+
+	clock.now = clock.now.Add(3 * time.Second)
+	assert.Zero(t, l.reserve(1))
+}
+
+func TestLimiter_Block(t *testing.T) {
+	l, clock := newTestLimiter(config.Rate{Limit: 10, Per: time.Second})
+
+	l.Block(5 * time.Second)
+	assert.Equal(t, 5*time.Second, l.reserve(1))
+	assert.Equal(t, clock.now.Add(5*time.Second), l.Budget().BlockedUntil)
+
+	// shorter delay doesn't shorten the block.
+	l.Block(time.Second)
+	assert.Equal(t, 5*time.Second, l.reserve(1))
+
+	clock.now = clock.now.Add(5 * time.Second)
+	assert.Zero(t, l.reserve(1))
+	assert.True(t, l.Budget().BlockedUntil.IsZero())
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l, _ := newTestLimiter(config.Rate{})
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, l.Wait(context.Background(), 10))
+	}
+}
+
+func TestLimiter_WaitCanceled(t *testing.T) {
+	l := New("test", config.Rate{Limit: 1, Per: time.Hour, Burst: 1})
+	require.NoError(t, l.Wait(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, l.Wait(ctx, 1), context.DeadlineExceeded)
+}
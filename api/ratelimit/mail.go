@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/fadyat/i4u/api"
+	"github.com/fadyat/i4u/internal/entity"
+)
+
+// Costs of the Gmail API calls in the quota units.
+//
+// https://developers.google.com/gmail/api/reference/quota
+const (
+	gmailListCost   = 5
+	gmailGetCost    = 5
+	gmailModifyCost = 5
+	gmailCreateCost = 5
+	gmailPingCost   = 1
+)
+
+// Mail spends the Gmail quota before every call, so the bursts
+// of the fetched messages don't exceed the per-user limit.
+type Mail struct {
+	next api.Mail
+	l    *Limiter
+}
+
+func NewMail(next api.Mail, l *Limiter) *Mail {
+	return &Mail{next: next, l: l}
+}
+
+// GetUnreadMsgs pays for the list call upfront, every fetched message
+// is paid after it's received, so the next fetch waits for the debt.
+func (m *Mail) GetUnreadMsgs(ctx context.Context) <-chan entity.MessageWithError {
+	out := make(chan entity.MessageWithError)
+
+	go func() {
+		defer close(out)
+
+		if err := m.l.Wait(ctx, gmailListCost); err != nil {
+			out <- entity.MessageWithError{Err: err}
+			return
+		}
+
+		for wrap := range m.next.GetUnreadMsgs(ctx) {
+			m.l.Take(gmailGetCost)
+			observe(wrap.Err, []*Limiter{m.l})
+			out <- wrap
+		}
+	}()
+
+	return out
+}
+
+func (m *Mail) LabelMsg(ctx context.Context, msg entity.MessageForLabeler) error {
+	return m.call(ctx, gmailModifyCost, func() error {
+		return m.next.LabelMsg(ctx, msg)
+	})
+}
+
+func (m *Mail) CreateLabel(ctx context.Context, name string) (*entity.Label, error) {
+	var label *entity.Label
+	err := m.call(ctx, gmailCreateCost, func() (err error) {
+		label, err = m.next.CreateLabel(ctx, name)
+		return err
+	})
+
+	return label, err
+}
+
+func (m *Mail) ModifyLabels(ctx context.Context, msgID string, add, remove []string) error {
+	return m.call(ctx, gmailModifyCost, func() error {
+		return m.next.ModifyLabels(ctx, msgID, add, remove)
+	})
+}
+
+// pinger is the mail client, which can check, that the API is reachable.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping pays for the probe of the API, so the readiness checks don't
+// spend the quota of the fetcher, the client without probe is always ready.
+func (m *Mail) Ping(ctx context.Context) error {
+	p, ok := m.next.(pinger)
+	if !ok {
+		return nil
+	}
+
+	return m.call(ctx, gmailPingCost, func() error {
+		return p.Ping(ctx)
+	})
+}
+
+func (m *Mail) call(ctx context.Context, cost float64, f func() error) error {
+	if err := m.l.Wait(ctx, cost); err != nil {
+		return err
+	}
+
+	err := f()
+	observe(err, []*Limiter{m.l})
+	return err
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"github.com/fadyat/i4u/internal/config"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry keeps the limiters, which are shared by all clients of the
+// same provider, because the quota is given to the account, not to the
+// client instance.
+type Registry struct {
+	rates config.RateLimits
+
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+func NewRegistry(rates config.RateLimits) *Registry {
+	return &Registry{rates: rates, limiters: make(map[string]*Limiter)}
+}
+
+// Limiter returns the limiter by the name, creating it on the first call.
+// Rate is chosen by the name prefix before the colon, so the limiters like
+// `telegram_chat:42` share the configuration, but not the budget.
+func (r *Registry) Limiter(name string) *Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.limiters[name]; ok {
+		return l
+	}
+
+	rate, _, _ := strings.Cut(name, ":")
+	l := New(name, r.rates.For(rate))
+	r.limiters[name] = l
+	return l
+}
+
+// Budgets returns the state of all limiters sorted by the name.
+func (r *Registry) Budgets() []Budget {
+	r.mu.Lock()
+	limiters := make([]*Limiter, 0, len(r.limiters))
+	for _, l := range r.limiters {
+		limiters = append(limiters, l)
+	}
+	r.mu.Unlock()
+
+	budgets := make([]Budget, 0, len(limiters))
+	for _, l := range limiters {
+		budgets = append(budgets, l.Budget())
+	}
+
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].Name < budgets[j].Name })
+	return budgets
+}
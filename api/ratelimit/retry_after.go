@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"google.golang.org/api/googleapi"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryAfterer is implemented by errors, which know,
+// when the provider allows to make the next call.
+type retryAfterer interface {
+	RetryAfterDuration() time.Duration
+}
+
+// RetryAfter returns the delay, which the provider asked to wait before
+// the next call, false means the error doesn't carry such delay.
+func RetryAfter(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	var ra retryAfterer
+	if errors.As(err, &ra) {
+		d := ra.RetryAfterDuration()
+		return d, d > 0
+	}
+
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+		return time.Duration(tgErr.RetryAfter) * time.Second, true
+	}
+
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) && gErr.Header != nil {
+		if secs, e := strconv.Atoi(gErr.Header.Get("Retry-After")); e == nil && secs > 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+
+		if t, e := http.ParseTime(gErr.Header.Get("Retry-After")); e == nil && time.Until(t) > 0 {
+			return time.Until(t), true
+		}
+	}
+
+	return 0, false
+}
+
+// observe blocks the limiters, when the provider asked to slow down.
+func observe(err error, limiters []*Limiter) {
+	d, ok := RetryAfter(err)
+	if !ok {
+		return
+	}
+
+	for _, l := range limiters {
+		l.Block(d)
+	}
+}
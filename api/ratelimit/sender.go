@@ -0,0 +1,28 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/fadyat/i4u/api"
+	"github.com/fadyat/i4u/internal/entity"
+)
+
+// Sender delivers the message, when all limiters allow it, for example,
+// the Telegram limits are shared by the bot and applied to every chat.
+type Sender struct {
+	next     api.Sender
+	limiters []*Limiter
+}
+
+func NewSender(next api.Sender, limiters ...*Limiter) *Sender {
+	return &Sender{next: next, limiters: limiters}
+}
+
+func (s *Sender) Send(ctx context.Context, msg entity.SummaryMessage) error {
+	if err := waitAll(ctx, 1, s.limiters); err != nil {
+		return err
+	}
+
+	err := s.next.Send(ctx, msg)
+	observe(err, s.limiters)
+	return err
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/api/sender"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/mocks"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected time.Duration
+		ok       bool
+	}{
+		{name: "no error"},
+		{name: "unknown error", err: errors.New("kek")},
+		{
+			name:     "http sender",
+			err:      fmt.Errorf("failed: %w", &sender.StatusError{Code: 429, RetryAfter: 3 * time.Second}),
+			expected: 3 * time.Second,
+			ok:       true,
+		},
+		{
+			name: "http sender without header",
+			err:  &sender.StatusError{Code: 429},
+		},
+		{
+			name:     "telegram",
+			err:      &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 7}},
+			expected: 7 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "gmail",
+			err:      &googleapi.Error{Code: 429, Header: http.Header{"Retry-After": []string{"2"}}},
+			expected: 2 * time.Second,
+			ok:       true,
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			d, ok := RetryAfter(tc.err)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, d)
+		})
+	}
+}
+
+func TestSender_Send(t *testing.T) {
+	var (
+		next    = mocks.NewSender(t)
+		shared  = New(config.RateTelegram, config.Rate{Limit: 30, Per: time.Second})
+		chat    = New(config.RateTelegramChat, config.Rate{Limit: 1, Per: time.Second, Burst: 1})
+		s       = NewSender(next, shared, chat)
+		msg     = entity.NewSummaryMsg(entity.NewMsg("0", "is_intern", "kek", true), "summary")
+		tooMany = &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 60}}
+	)
+
+	next.On("Send", mock.Anything, msg).Return(tooMany).Once()
+	assert.ErrorIs(t, s.Send(context.Background(), msg), tooMany)
+
+	// all limiters are blocked, as the provider asked.
+	for _, l := range []*Limiter{shared, chat} {
+		assert.False(t, l.Budget().BlockedUntil.IsZero(), l.Name())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Send(ctx, msg), context.DeadlineExceeded)
+}
+
+func TestMail_GetUnreadMsgs(t *testing.T) {
+	next := mocks.NewMail(t)
+	l := New(config.RateGmail, config.Rate{Limit: 1, Per: time.Hour, Burst: 20})
+	m := NewMail(next, l)
+
+	fetched := make(chan entity.MessageWithError, 2)
+	fetched <- entity.MessageWithError{Msg: entity.NewMsg("0", "i4u", "kek", true)}
+	fetched <- entity.MessageWithError{Msg: entity.NewMsg("1", "i4u", "kek", true)}
+	close(fetched)
+	next.On("GetUnreadMsgs", mock.Anything).Return((<-chan entity.MessageWithError)(fetched))
+
+	var ids []string
+	for wrap := range m.GetUnreadMsgs(context.Background()) {
+		require.NoError(t, wrap.Err)
+		ids = append(ids, wrap.Msg.ID())
+	}
+
+	assert.Equal(t, []string{"0", "1"}, ids)
+	assert.InDelta(t, 20-gmailListCost-2*gmailGetCost, l.Budget().Remaining, 0.01)
+}
+
+// pingMail is the mail client with the probe of the API.
+type pingMail struct {
+	*mocks.Mail
+	pings int
+}
+
+func (m *pingMail) Ping(context.Context) error {
+	m.pings++
+	return nil
+}
+
+func TestMail_Ping(t *testing.T) {
+	next := &pingMail{Mail: mocks.NewMail(t)}
+	l := New(config.RateGmail, config.Rate{Limit: 1, Per: time.Hour, Burst: 1})
+	m := NewMail(next, l)
+
+	require.NoError(t, m.Ping(context.Background()))
+	assert.InDelta(t, 1-gmailPingCost, l.Budget().Remaining, 0.01)
+
+	// the probe waits for the quota, like the other calls.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, m.Ping(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 1, next.pings)
+
+	require.NoError(t, NewMail(mocks.NewMail(t), l).Ping(context.Background()))
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/fadyat/i4u/api"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+)
+
+// Summarizer keeps the OpenAI calls within the requests and tokens per
+// minute limits, the tokens are estimated before the call, because the
+// real usage is known only from the response.
+type Summarizer struct {
+	next      api.Summarizer
+	requests  *Limiter
+	tokens    *Limiter
+	gptConfig *config.GPT
+}
+
+func NewSummarizer(next api.Summarizer, requests, tokens *Limiter, gptConfig *config.GPT) *Summarizer {
+	return &Summarizer{next: next, requests: requests, tokens: tokens, gptConfig: gptConfig}
+}
+
+func (s *Summarizer) GetMsgSummary(ctx context.Context, msg entity.Message) (string, error) {
+	if err := s.requests.Wait(ctx, 1); err != nil {
+		return "", err
+	}
+
+	if err := s.tokens.Wait(ctx, s.estimateTokens(msg)); err != nil {
+		return "", err
+	}
+
+	summary, err := s.next.GetMsgSummary(ctx, msg)
+	observe(err, []*Limiter{s.requests, s.tokens})
+	return summary, err
+}
+
+// estimateTokens counts the prompt and the longest completion,
+// one token is roughly 4 characters for the English text.
+func (s *Summarizer) estimateTokens(msg entity.Message) float64 {
+	prompt := len(s.gptConfig.FeedPrompt(msg.Body()))
+	return float64((prompt+3)/4 + s.gptConfig.MaxTokens)
+}
@@ -0,0 +1,196 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/render"
+	"github.com/fadyat/i4u/internal/storage"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// flushTimeout is a time given to deliver the digest.
+const flushTimeout = 30 * time.Second
+
+// The failed digest is retried after flushRetry, the delay is doubled
+// after each failure up to flushMaxRetry.
+const (
+	flushRetry    = time.Minute
+	flushMaxRetry = time.Hour
+)
+
+var digestTitles = map[entity.Verdict]string{
+	entity.VerdictOffer:     "🎉 Offers",
+	entity.VerdictInterview: "🗓 Interviews",
+	entity.VerdictTestTask:  "📝 Test tasks",
+	entity.VerdictReject:    "⛔ Rejections",
+	entity.VerdictUnknown:   "❔ Other",
+}
+
+// Digest collects summaries during the day and sends them at the
+// configured time as a single email, grouped by verdict.
+//
+// Collected summaries are kept in the storage, so they survive restarts,
+// Digest must be launched via Run, otherwise they will never be sent.
+type Digest struct {
+	mailer *SMTP
+	store  *storage.Storage
+	name   string
+	at     time.Duration
+	now    func() time.Time
+
+	// mu doesn't let flushes send the same entries twice.
+	mu sync.Mutex
+}
+
+// NewDigest creates a digest, which will be sent at the `at` clock time,
+// given as hours and minutes since midnight, in local time; the entries
+// are kept in the storage by the name of the digest.
+func NewDigest(mailer *SMTP, store *storage.Storage, name string, at time.Duration) *Digest {
+	return &Digest{
+		mailer: mailer,
+		store:  store,
+		name:   name,
+		at:     at,
+		now:    time.Now,
+	}
+}
+
+// Send doesn't deliver the message, it's stored until the next digest.
+func (d *Digest) Send(_ context.Context, msg entity.SummaryMessage) error {
+	if err := d.store.SaveDigestEntry(d.name, render.NewMessage(msg)); err != nil {
+		return fmt.Errorf("failed to save digest entry: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Digest) Run(ctx context.Context) {
+	var failures int
+	for {
+		timer := time.NewTimer(d.nextFlush(failures))
+
+		select {
+		case <-timer.C:
+			if err := d.flushWithTimeout(context.Background()); err != nil {
+				failures++
+				continue
+			}
+
+			failures = 0
+		case <-ctx.Done():
+			timer.Stop()
+
+			// the removed digest is never run again, so it sends whatever was
+			// collected, otherwise it's sent at its time after the restart.
+			if errors.Is(context.Cause(ctx), errRouteRemoved) {
+				_ = d.flushWithTimeout(context.Background())
+			}
+			return
+		}
+	}
+}
+
+func (d *Digest) flushWithTimeout(ctx context.Context) error {
+	timeout, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+
+	err := d.Flush(timeout)
+	if err != nil {
+		zap.L().Error("failed to send digest", zap.Error(err))
+	}
+
+	return err
+}
+
+// Flush sends collected summaries, when there is nothing to send it's a no-op.
+// Summaries are removed from the storage only after the digest is sent,
+// on failure they're kept for the next attempt.
+func (d *Digest) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entries, err := d.store.DigestEntries(d.name)
+	if err != nil {
+		return fmt.Errorf("failed to read digest entries: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	groups := make(map[entity.Verdict][]render.Message)
+	for _, e := range entries {
+		var entry render.Message
+		if err = json.Unmarshal(e.Content, &entry); err != nil {
+			return fmt.Errorf("failed to decode digest entry %d: %w", e.ID, err)
+		}
+
+		groups[entry.Verdict] = append(groups[entry.Verdict], entry)
+	}
+
+	if err = d.send(ctx, groups, len(entries)); err != nil {
+		return err
+	}
+
+	return d.store.DeleteDigestEntries(d.name, entries[len(entries)-1].ID)
+}
+
+func (d *Digest) send(ctx context.Context, groups map[entity.Verdict][]render.Message, total int) error {
+	digest := render.Digest{Date: d.now(), Total: total}
+	for _, v := range entity.Verdicts {
+		if messages := groups[v]; len(messages) > 0 {
+			digest.Groups = append(digest.Groups, render.DigestGroup{
+				Title: digestTitles[v], Verdict: v, Messages: messages,
+			})
+		}
+	}
+
+	text, err := d.mailer.r.Render("email", render.KindDigest, render.FormatText, digest)
+	if err != nil {
+		return err
+	}
+
+	htmlBody, err := d.mailer.r.Render("email", render.KindDigest, render.FormatHTML, digest)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("i4u digest: %d update(s) for %s", total, digest.Date.Format("Jan 2"))
+	return d.mailer.SendMail(ctx, subject, text, htmlBody)
+}
+
+// nextFlush returns duration until the next flush, the failed digest is
+// retried with growing delays, but not later than the next digest time.
+func (d *Digest) nextFlush(failures int) time.Duration {
+	next := d.untilNext()
+	if failures == 0 {
+		return next
+	}
+
+	retry := flushRetry
+	for i := 1; i < failures && retry < flushMaxRetry; i++ {
+		retry *= 2
+	}
+
+	return min(retry, flushMaxRetry, next)
+}
+
+// untilNext returns duration until the next digest time, the time is
+// set by the clock, so it isn't shifted on the daylight saving days.
+func (d *Digest) untilNext() time.Duration {
+	now := d.now()
+	y, m, day := now.Date()
+	hh, mm := int(d.at/time.Hour), int(d.at%time.Hour/time.Minute)
+
+	next := time.Date(y, m, day, hh, mm, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(y, m, day+1, hh, mm, 0, 0, now.Location())
+	}
+
+	return next.Sub(now)
+}
@@ -0,0 +1,284 @@
+package sender
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// smtpSink is a minimal SMTP server, which accepts all emails
+// and pushes their content to the channel.
+func smtpSink(t *testing.T) (*config.SMTP, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	mails := make(chan string, 10)
+	go func() {
+		for {
+			conn, e := l.Accept()
+			if e != nil {
+				return
+			}
+
+			go serveSMTP(conn, mails)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return &config.SMTP{
+		Host: host,
+		Port: p,
+		From: "i4u@example.com",
+		To:   []string{"me@example.com"},
+	}, mails
+}
+
+func serveSMTP(conn net.Conn, mails chan<- string) {
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case cmd == "DATA":
+			reply("354 go ahead")
+
+			var data strings.Builder
+			for {
+				l, e := r.ReadString('\n')
+				if e != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+
+			mails <- data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func decodeQP(t *testing.T, s string) string {
+	b, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(s)))
+	require.NoError(t, err)
+
+	return string(b)
+}
+
+func newTestDigestStorage(t *testing.T) *storage.Storage {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "i4u.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func TestDigest_Flush(t *testing.T) {
+	cfg, mails := smtpSink(t)
+	d := NewDigest(NewSMTP(cfg), newTestDigestStorage(t), "digest", 18*time.Hour)
+
+	offer := entity.NewSummaryMsg(
+		entity.NewMsg("1", "i4u", "kek", true),
+		"Company: Globex\nVacancy: Intern\nVerdict: Offer\nReason: Great fit",
+	)
+
+	ctx := context.Background()
+	require.NoError(t, d.Flush(ctx))
+	require.NoError(t, d.Send(ctx, newTestSummary()))
+	require.NoError(t, d.Send(ctx, offer))
+	require.NoError(t, d.Send(ctx, entity.NewAlertMsg(errors.New("kek"))))
+	require.NoError(t, d.Flush(ctx))
+
+	var raw string
+	select {
+	case raw = <-mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("digest wasn't delivered")
+	}
+
+	assert.Contains(t, raw, "Subject: i4u digest: 3 update(s)")
+	assert.Contains(t, raw, "multipart/alternative")
+
+	content := decodeQP(t, raw)
+	offers, rejections, other := strings.Index(content, "Offers (1)"),
+		strings.Index(content, "Rejections (1)"), strings.Index(content, "Other (1)")
+	assert.True(t, offers >= 0 && offers < rejections && rejections < other, content)
+	assert.Contains(t, content, "Acme &lt;Corp&gt;")
+
+	// nothing left for the next digest.
+	require.NoError(t, d.Flush(ctx))
+	select {
+	case <-mails:
+		t.Fatal("empty digest was delivered")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDigest_FlushFailed(t *testing.T) {
+	cfg, _ := smtpSink(t)
+	cfg.Port = 1
+
+	store := newTestDigestStorage(t)
+	d := NewDigest(NewSMTP(cfg), store, "digest", 18*time.Hour)
+	require.NoError(t, d.Send(context.Background(), newTestSummary()))
+	assert.Error(t, d.Flush(context.Background()))
+
+	entries, err := store.DigestEntries("digest")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestDigest_Restart(t *testing.T) {
+	cfg, mails := smtpSink(t)
+	store := newTestDigestStorage(t)
+
+	// the stopped digest isn't sent before its time.
+	d := NewDigest(NewSMTP(cfg), store, "digest", 18*time.Hour)
+	require.NoError(t, d.Send(context.Background(), newTestSummary()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.Run(ctx)
+	select {
+	case <-mails:
+		t.Fatal("digest was delivered on stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// collected summaries are sent by the digest after the restart.
+	restarted := NewDigest(NewSMTP(cfg), store, "digest", 18*time.Hour)
+	require.NoError(t, restarted.Flush(context.Background()))
+	select {
+	case raw := <-mails:
+		assert.Contains(t, raw, "Subject: i4u digest: 1 update(s)")
+	case <-time.After(5 * time.Second):
+		t.Fatal("digest wasn't delivered")
+	}
+
+	entries, err := store.DigestEntries("digest")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDigest_untilNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		now      time.Time
+		expected time.Duration
+	}{
+		{
+			name:     "later today",
+			now:      time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC),
+			expected: 8 * time.Hour,
+		},
+		{
+			name:     "tomorrow",
+			now:      time.Date(2023, 9, 1, 18, 0, 0, 0, time.UTC),
+			expected: 24 * time.Hour,
+		},
+		{
+			name:     "clocks moved forward today",
+			now:      time.Date(2023, 3, 12, 10, 0, 0, 0, ny),
+			expected: 8 * time.Hour,
+		},
+		{
+			name:     "clocks move forward tomorrow",
+			now:      time.Date(2023, 3, 11, 18, 0, 0, 0, ny),
+			expected: 23 * time.Hour,
+		},
+		{
+			name:     "clocks moved back today",
+			now:      time.Date(2023, 11, 5, 10, 0, 0, 0, ny),
+			expected: 8 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		now := tc.now
+
+		d := NewDigest(nil, nil, "digest", 18*time.Hour)
+		d.now = func() time.Time { return now }
+		assert.Equal(t, tc.expected, d.untilNext(), tc.name)
+	}
+}
+
+func TestDigest_nextFlush(t *testing.T) {
+	testCases := []struct {
+		name     string
+		now      time.Time
+		failures int
+		expected time.Duration
+	}{
+		{
+			name:     "sent",
+			now:      time.Date(2023, 9, 1, 18, 0, 0, 0, time.UTC),
+			expected: 24 * time.Hour,
+		},
+		{
+			name:     "failed once",
+			now:      time.Date(2023, 9, 1, 18, 0, 0, 0, time.UTC),
+			failures: 1,
+			expected: time.Minute,
+		},
+		{
+			name:     "failed several times",
+			now:      time.Date(2023, 9, 1, 18, 0, 0, 0, time.UTC),
+			failures: 3,
+			expected: 4 * time.Minute,
+		},
+		{
+			name:     "failed many times",
+			now:      time.Date(2023, 9, 1, 18, 0, 0, 0, time.UTC),
+			failures: 100,
+			expected: time.Hour,
+		},
+		{
+			name:     "next digest is sooner",
+			now:      time.Date(2023, 9, 1, 17, 59, 0, 0, time.UTC),
+			failures: 3,
+			expected: time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		now := tc.now
+
+		d := NewDigest(nil, nil, "digest", 18*time.Hour)
+		d.now = func() time.Time { return now }
+		assert.Equal(t, tc.expected, d.nextFlush(tc.failures), tc.name)
+	}
+}
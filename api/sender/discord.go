@@ -0,0 +1,117 @@
+package sender
+
+import (
+	"context"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/render"
+	"net/http"
+	"time"
+)
+
+// discordInterval is a rate limit for the webhook, Discord allows
+// 5 requests per 2 seconds, exact limits are sent in the headers.
+//
+// https://discord.com/developers/docs/topics/rate-limits
+const discordInterval = 400 * time.Millisecond
+
+// Limits of the embed parts, longer ones are rejected by Discord.
+const (
+	discordMaxTitle       = 256
+	discordMaxDescription = 4096
+	discordMaxFieldValue  = 1024
+)
+
+var verdictColors = map[entity.Verdict]int{
+	entity.VerdictOffer:     0x2ecc71,
+	entity.VerdictInterview: 0x3498db,
+	entity.VerdictTestTask:  0xf1c40f,
+	entity.VerdictReject:    0xe74c3c,
+	entity.VerdictUnknown:   0x95a5a6,
+}
+
+type Discord struct {
+	c          *http.Client
+	webhookURL string
+	t          *throttle
+	r          *render.Renderer
+}
+
+func NewDiscord(c *http.Client, webhookURL string) *Discord {
+	return &Discord{c: c, webhookURL: webhookURL, t: newThrottle(discordInterval), r: render.Default}
+}
+
+// WithRenderer replaces the built-in templates of the messages.
+func (d *Discord) WithRenderer(r *render.Renderer) *Discord {
+	d.r = r
+	return d
+}
+
+func (d *Discord) Send(ctx context.Context, msg entity.SummaryMessage) error {
+	payload, err := d.newPayload(msg)
+	if err != nil {
+		return err
+	}
+
+	return withThrottle(ctx, d.t, func() error {
+		_, err := postJSON(ctx, d.c, d.webhookURL, nil, payload)
+		return err
+	})
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordPayload struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+// newPayload builds an embed for the summary, the title links to
+// the message in Gmail, the description is rendered by the template,
+// other messages are sent as a plain content.
+//
+// https://discord.com/developers/docs/resources/channel#embed-object
+func (d *Discord) newPayload(msg entity.SummaryMessage) (*discordPayload, error) {
+	summary, ok := msg.(*entity.SummaryMsg)
+	if !ok {
+		content, err := d.r.Render("discord", render.KindAlert, render.FormatText, render.NewMessage(msg))
+		if err != nil {
+			return nil, err
+		}
+
+		return &discordPayload{Content: truncate(content, 2000)}, nil
+	}
+
+	description, err := d.r.Render("discord", render.KindSummary, render.FormatText, render.NewMessage(summary))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := summary.Fields()
+	embed := discordEmbed{
+		Title:       truncate("🏢 "+orDefault(fields.Company, "Internship request"), discordMaxTitle),
+		Description: truncate(description, discordMaxDescription),
+		URL:         summary.Link(),
+		Color:       verdictColors[summary.Verdict()],
+	}
+
+	if fields.Verdict != "" {
+		embed.Fields = []discordField{
+			{Name: "Verdict", Value: truncate(fields.Verdict, discordMaxFieldValue), Inline: true},
+			{Name: "Vacancy", Value: truncate(orDash(fields.Vacancy), discordMaxFieldValue), Inline: true},
+		}
+	}
+
+	return &discordPayload{Embeds: []discordEmbed{embed}}, nil
+}
@@ -0,0 +1,57 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiscord_Send(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p discordPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		require.Len(t, p.Embeds, 1)
+
+		embed := p.Embeds[0]
+		assert.Equal(t, "🏢 Acme <Corp>", embed.Title)
+		assert.Equal(t, "No headcount", embed.Description)
+		assert.Equal(t, verdictColors[entity.VerdictReject], embed.Color)
+		assert.Equal(t, []discordField{
+			{Name: "Verdict", Value: "Reject", Inline: true},
+			{Name: "Vacancy", Value: "Intern", Inline: true},
+		}, embed.Fields)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := NewDiscord(srv.Client(), srv.URL)
+	assert.NoError(t, d.Send(context.Background(), newTestSummary()))
+}
+
+func TestDiscord_newPayloadTruncated(t *testing.T) {
+	long := strings.Repeat("a", 2000)
+	summary := entity.NewSummaryMsg(
+		entity.NewMsg("0", "i4u", "kek", true),
+		"Company: "+long+"\nVacancy: "+long+"\nVerdict: Reject",
+	)
+
+	p, err := NewDiscord(http.DefaultClient, "").newPayload(summary)
+	require.NoError(t, err)
+
+	embed := p.Embeds[0]
+	assert.Len(t, []rune(embed.Title), discordMaxTitle)
+	assert.Len(t, []rune(embed.Fields[1].Value), discordMaxFieldValue)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "kek", truncate("kek", 3))
+	assert.Equal(t, "ke…", truncate("keks", 3))
+	assert.Equal(t, 2000, len([]rune(truncate(strings.Repeat("ж", 3000), 2000))))
+}
@@ -0,0 +1,23 @@
+package sender
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+
+	return s
+}
+
+func orDash(s string) string {
+	return orDefault(s, "—")
+}
+
+// truncate cuts the string to the limit of runes.
+func truncate(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+
+	return string(r[:limit-1]) + "…"
+}
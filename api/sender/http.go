@@ -0,0 +1,195 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxAttempts is a number of attempts to deliver the message, when
+// the remote side asks to slow down with 429 status code.
+const maxAttempts = 3
+
+// StatusError is returned when the remote side responds with
+// unsuccessful status code.
+type StatusError struct {
+	Code int
+	Body string
+
+	// RetryAfter is a duration parsed from the Retry-After header,
+	// zero when the header is missing.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.Code, e.Body)
+}
+
+// StatusCode is used for deciding, whether the request can be retried.
+func (e *StatusError) StatusCode() int {
+	return e.Code
+}
+
+// RetryAfterDuration is used by the rate limiters to stop the calls,
+// until the remote side allows them again.
+func (e *StatusError) RetryAfterDuration() time.Duration {
+	return e.RetryAfter
+}
+
+// postJSON marshals the payload and sends it to the url, returning
+// the response body on success.
+func postJSON(
+	ctx context.Context,
+	c *http.Client,
+	url string,
+	headers http.Header,
+	payload any,
+) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	return post(ctx, c, url, headers, body)
+}
+
+func post(
+	ctx context.Context,
+	c *http.Client,
+	url string,
+	headers http.Header,
+	body []byte,
+) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{
+			Code:       resp.StatusCode,
+			Body:       string(respBody),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return respBody, nil
+}
+
+// parseRetryAfter supports both formats of the Retry-After header:
+// delay in seconds and HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(secs * float64(time.Second))
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
+
+// throttle makes sure that calls are made not often than the
+// interval, also allows to postpone the next call when the
+// remote side asks to slow down.
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newThrottle(interval time.Duration) *throttle {
+	return &throttle{interval: interval}
+}
+
+// wait blocks until the next call is allowed or context is done.
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	at := t.next
+	if at.Before(now) {
+		at = now
+	}
+	t.next = at.Add(t.interval)
+	t.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// postpone moves the next allowed call to the future.
+func (t *throttle) postpone(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at := time.Now().Add(d); at.After(t.next) {
+		t.next = at
+	}
+}
+
+// withThrottle performs the call respecting the throttle, when the call
+// fails with 429 status code, it will be retried after the delay
+// provided by the remote side.
+func withThrottle(ctx context.Context, t *throttle, call func() error) error {
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if e := t.wait(ctx); e != nil {
+			return e
+		}
+
+		err = call()
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Code != http.StatusTooManyRequests {
+			return err
+		}
+
+		retryAfter := statusErr.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = t.interval
+		}
+		t.postpone(retryAfter)
+	}
+
+	return err
+}
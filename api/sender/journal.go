@@ -0,0 +1,37 @@
+package sender
+
+import (
+	"context"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	"time"
+)
+
+// Journal doesn't deliver anything, it keeps the history of the
+// applications, which is used by the bot commands.
+type Journal struct {
+	store *storage.Storage
+}
+
+func NewJournal(store *storage.Storage) *Journal {
+	return &Journal{store: store}
+}
+
+func (j *Journal) Send(_ context.Context, msg entity.SummaryMessage) error {
+	summary, ok := msg.(*entity.SummaryMsg)
+	if !ok {
+		return nil
+	}
+
+	fields := summary.Fields()
+	_, err := j.store.SaveApplicationUpdate(fields.Company, fields.Vacancy, &storage.ApplicationUpdate{
+		MessageID: summary.ID(),
+		ThreadID:  summary.ThreadID(),
+		Verdict:   summary.Verdict(),
+		Summary:   summary.Text(),
+		Link:      summary.Link(),
+		At:        time.Now(),
+	})
+
+	return err
+}
@@ -0,0 +1,271 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/api"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/fadyat/i4u/pkg/syncs"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+)
+
+// errRouteRemoved stops the background work of the sender,
+// which isn't routed anymore.
+var errRouteRemoved = errors.New("route removed")
+
+// Route is a destination with the rules, which summaries
+// should be delivered there.
+type Route struct {
+	Name   string
+	Sender api.Sender
+
+	// Verdicts limits delivered summaries, everything is delivered when empty.
+	// Messages without verdict, like alerts, are delivered only to routes
+	// without limits.
+	Verdicts []entity.Verdict
+}
+
+func (r *Route) matches(msg entity.SummaryMessage) bool {
+	if len(r.Verdicts) == 0 {
+		return true
+	}
+
+	summary, ok := msg.(*entity.SummaryMsg)
+	if !ok {
+		return false
+	}
+
+	verdict := summary.Verdict()
+	for _, v := range r.Verdicts {
+		if v == verdict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RouteStats is a delivery accounting of the single route.
+type RouteStats struct {
+	Sent    uint64
+	Failed  uint64
+	Skipped uint64
+}
+
+type routeCounters struct {
+	sent, failed, skipped atomic.Uint64
+}
+
+// Router fans out summaries to multiple senders, each route is delivered
+// independently, so a failing sender doesn't block the others.
+type Router struct {
+	mu     sync.RWMutex
+	routes []route
+
+	// counters are kept by the names of the routes, so the
+	// stats survive replacing the routes.
+	counters map[string]*routeCounters
+
+	// ctx is set by Run, while the background work of the
+	// senders is running, runners stop it for every sender.
+	ctx     context.Context
+	wg      syncs.WaitGroup
+	runners map[api.Sender]context.CancelCauseFunc
+
+	// deliveries keeps the routes, the message was delivered to,
+	// so they're skipped, when the message is retried.
+	deliveries *storage.Storage
+}
+
+type route struct {
+	Route
+	counters *routeCounters
+}
+
+func NewRouter(routes ...Route) *Router {
+	r := &Router{
+		counters: make(map[string]*routeCounters, len(routes)),
+		runners:  make(map[api.Sender]context.CancelCauseFunc),
+	}
+
+	r.SetRoutes(routes...)
+	return r
+}
+
+// WithDeliveries makes the retried messages skip the routes, they were
+// already delivered to, so they aren't duplicated.
+func (r *Router) WithDeliveries(store *storage.Storage) *Router {
+	r.deliveries = store
+	return r
+}
+
+// SetRoutes replaces the routes, the messages being sent are delivered
+// by the previous ones; the background work of the removed senders is
+// stopped and started for the new ones.
+func (r *Router) SetRoutes(routes ...Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	updated := make([]route, 0, len(routes))
+	for _, rt := range routes {
+		counters, ok := r.counters[rt.Name]
+		if !ok {
+			counters = &routeCounters{}
+			r.counters[rt.Name] = counters
+		}
+
+		updated = append(updated, route{Route: rt, counters: counters})
+	}
+
+	r.routes = updated
+	r.syncRunners()
+}
+
+// Send delivers the message to all matching routes concurrently, errors
+// of the failed routes are joined together.
+func (r *Router) Send(ctx context.Context, msg entity.SummaryMessage) error {
+	var (
+		wg   syncs.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	r.mu.RLock()
+	routes := r.routes
+	r.mu.RUnlock()
+
+	key, delivered := r.delivered(msg)
+	for i := range routes {
+		route, counters := &routes[i], routes[i].counters
+		if !route.matches(msg) {
+			counters.skipped.Add(1)
+			continue
+		}
+
+		if delivered[route.Name] {
+			zap.S().Debugf("message already delivered via route %s", route.Name)
+			continue
+		}
+
+		wg.Go(func() {
+			if err := route.Sender.Send(ctx, msg); err != nil {
+				counters.failed.Add(1)
+
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("route %s: %w", route.Name, err))
+				mu.Unlock()
+				return
+			}
+
+			counters.sent.Add(1)
+			zap.S().Debugf("message delivered via route %s", route.Name)
+			if key == "" {
+				return
+			}
+
+			if err := r.deliveries.SaveDelivery(key, route.Name); err != nil {
+				zap.S().Warnf("failed to save delivery of %s via route %s: %s", key, route.Name, err)
+			}
+		})
+	}
+
+	wg.Wait()
+	if len(errs) == 0 && key != "" {
+		if err := r.deliveries.ClearDeliveries(key); err != nil {
+			zap.S().Warnf("failed to clear deliveries of %s: %s", key, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// delivered returns the key of the message and the routes, it was already
+// delivered to, the key is empty, when deliveries aren't kept for it.
+func (r *Router) delivered(msg entity.SummaryMessage) (string, map[string]bool) {
+	m, ok := msg.(entity.Message)
+	if r.deliveries == nil || !ok {
+		return "", nil
+	}
+
+	key := storage.QueueKey(m)
+	delivered, err := r.deliveries.Delivered(key)
+	if err != nil {
+		zap.S().Warnf("failed to read deliveries of %s: %s", key, err)
+	}
+
+	return key, delivered
+}
+
+// Stats returns delivery accounting for each route by its name,
+// including the removed ones.
+func (r *Router) Stats() map[string]RouteStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make(map[string]RouteStats, len(r.counters))
+	for name, counters := range r.counters {
+		stats[name] = RouteStats{
+			Sent:    counters.sent.Load(),
+			Failed:  counters.failed.Load(),
+			Skipped: counters.skipped.Load(),
+		}
+	}
+
+	return stats
+}
+
+// Run launches background work of the senders, like sending digests,
+// and blocks until all of them are finished.
+func (r *Router) Run(ctx context.Context) {
+	r.mu.Lock()
+	r.ctx = ctx
+	r.syncRunners()
+	r.mu.Unlock()
+
+	<-ctx.Done()
+
+	// no runners are started after it, so waiting for them is safe.
+	r.mu.Lock()
+	r.ctx = nil
+	clear(r.runners)
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+// syncRunners starts the background work of the senders without it and
+// stops it for the senders, which aren't routed anymore, like digests,
+// which send collected summaries on stop. The lock must be held.
+func (r *Router) syncRunners() {
+	if r.ctx == nil {
+		return
+	}
+
+	routed := make(map[api.Sender]bool, len(r.routes))
+	for _, rt := range r.routes {
+		runner, ok := rt.Sender.(interface{ Run(context.Context) })
+		if !ok {
+			continue
+		}
+
+		routed[rt.Sender] = true
+		if _, running := r.runners[rt.Sender]; running {
+			continue
+		}
+
+		ctx, cancel := context.WithCancelCause(r.ctx)
+		r.runners[rt.Sender] = cancel
+		r.wg.Go(func() { runner.Run(ctx) })
+	}
+
+	for s, cancel := range r.runners {
+		if !routed[s] {
+			cancel(errRouteRemoved)
+			delete(r.runners, s)
+		}
+	}
+}
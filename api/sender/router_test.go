@@ -0,0 +1,115 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/fadyat/i4u/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouter_Send(t *testing.T) {
+	offers, rejects, everything := mocks.NewSender(t), mocks.NewSender(t), mocks.NewSender(t)
+
+	router := NewRouter(
+		Route{Name: "offers", Sender: offers, Verdicts: []entity.Verdict{entity.VerdictOffer}},
+		Route{Name: "rejects", Sender: rejects, Verdicts: []entity.Verdict{entity.VerdictReject}},
+		Route{Name: "everything", Sender: everything},
+	)
+
+	reject := newTestSummary()
+	alert := entity.NewAlertMsg(errors.New("kek"))
+
+	rejects.On("Send", mock.Anything, reject).Return(errors.New("smtp is down"))
+	everything.On("Send", mock.Anything, reject).Return(nil)
+	everything.On("Send", mock.Anything, alert).Return(nil)
+
+	err := router.Send(context.Background(), reject)
+	assert.ErrorContains(t, err, "route rejects: smtp is down")
+	assert.NoError(t, router.Send(context.Background(), alert))
+
+	assert.Equal(t, map[string]RouteStats{
+		"offers":     {Skipped: 2},
+		"rejects":    {Failed: 1, Skipped: 1},
+		"everything": {Sent: 2},
+	}, router.Stats())
+}
+
+func TestRouter_SendRetried(t *testing.T) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "i4u.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	slack, email := mocks.NewSender(t), mocks.NewSender(t)
+	router := NewRouter(
+		Route{Name: "slack", Sender: slack},
+		Route{Name: "email", Sender: email},
+	).WithDeliveries(store)
+
+	summary := newTestSummary()
+	slack.On("Send", mock.Anything, summary).Return(nil).Once()
+	email.On("Send", mock.Anything, summary).Return(errors.New("smtp is down")).Once()
+	assert.ErrorContains(t, router.Send(context.Background(), summary), "route email: smtp is down")
+
+	// the retry is delivered only to the failed route.
+	email.On("Send", mock.Anything, summary).Return(nil).Once()
+	require.NoError(t, router.Send(context.Background(), summary))
+
+	delivered, err := store.Delivered(storage.QueueKey(summary))
+	require.NoError(t, err)
+	assert.Empty(t, delivered, "deliveries are forgotten, when all routes succeeded")
+}
+
+// runner is a sender with the background work, like digest.
+type runner struct {
+	*mocks.Sender
+	started, stopped chan struct{}
+	cause            error
+}
+
+func (r *runner) Run(ctx context.Context) {
+	close(r.started)
+	<-ctx.Done()
+	r.cause = context.Cause(ctx)
+	close(r.stopped)
+}
+
+func TestRouter_SetRoutes(t *testing.T) {
+	digest := &runner{Sender: mocks.NewSender(t), started: make(chan struct{}), stopped: make(chan struct{})}
+	tg := mocks.NewSender(t)
+
+	router := NewRouter(Route{Name: "digest", Sender: digest})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		router.Run(ctx)
+		close(done)
+	}()
+
+	<-digest.started
+
+	summary := newTestSummary()
+	digest.On("Send", mock.Anything, summary).Return(nil).Once()
+	require.NoError(t, router.Send(context.Background(), summary))
+
+	// the removed digest is stopped, so it sends what was collected.
+	router.SetRoutes(Route{Name: "tg", Sender: tg})
+	<-digest.stopped
+	assert.ErrorIs(t, digest.cause, errRouteRemoved)
+
+	tg.On("Send", mock.Anything, summary).Return(nil).Once()
+	require.NoError(t, router.Send(context.Background(), summary))
+
+	assert.Equal(t, map[string]RouteStats{
+		"digest": {Sent: 1},
+		"tg":     {Sent: 1},
+	}, router.Stats())
+
+	cancel()
+	<-done
+}
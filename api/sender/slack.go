@@ -0,0 +1,163 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/render"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// slackInterval is a rate limit for posting messages, both incoming
+// webhooks and chat.postMessage allow roughly one message per second
+// per channel with short bursts.
+//
+// https://api.slack.com/docs/rate-limits
+const slackInterval = time.Second
+
+type Slack struct {
+	c   *http.Client
+	cfg *config.Slack
+	t   *throttle
+	r   *render.Renderer
+}
+
+func NewSlack(c *http.Client, cfg *config.Slack) *Slack {
+	return &Slack{c: c, cfg: cfg, t: newThrottle(slackInterval), r: render.Default}
+}
+
+// WithRenderer replaces the built-in templates of the messages.
+func (s *Slack) WithRenderer(r *render.Renderer) *Slack {
+	s.r = r
+	return s
+}
+
+func (s *Slack) Send(ctx context.Context, msg entity.SummaryMessage) error {
+	payload, err := s.newPayload(msg)
+	if err != nil {
+		return err
+	}
+
+	return withThrottle(ctx, s.t, func() error {
+		if s.cfg.WebhookURL != "" {
+			_, err := postJSON(ctx, s.c, s.cfg.WebhookURL, nil, payload)
+			return err
+		}
+
+		return s.postMessage(ctx, payload)
+	})
+}
+
+// postMessage sends the message via Web API, unlike webhooks it responds
+// with 200 status code even on failure, so the body needs to be checked.
+func (s *Slack) postMessage(ctx context.Context, payload *slackPayload) error {
+	payload.Channel = s.cfg.Channel
+
+	headers := http.Header{"Authorization": {"Bearer " + s.cfg.Token}}
+	body, err := postJSON(ctx, s.c, strings.TrimRight(s.cfg.APIURL, "/")+"/chat.postMessage", headers, payload)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if e := json.Unmarshal(body, &resp); e != nil {
+		return fmt.Errorf("failed to decode slack response: %w", e)
+	}
+
+	if !resp.OK {
+		return errors.New("slack responded with error: " + resp.Error)
+	}
+
+	return nil
+}
+
+type slackPayload struct {
+	Channel string       `json:"channel,omitempty"`
+	Text    string       `json:"text"`
+	Blocks  []slackBlock `json:"blocks,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackElement struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+	URL  string     `json:"url,omitempty"`
+}
+
+type slackBlock struct {
+	Type     string         `json:"type"`
+	Text     *slackText     `json:"text,omitempty"`
+	Fields   []slackText    `json:"fields,omitempty"`
+	Elements []slackElement `json:"elements,omitempty"`
+}
+
+func mrkdwn(text string) *slackText {
+	return &slackText{Type: "mrkdwn", Text: text}
+}
+
+func plainText(text string) *slackText {
+	return &slackText{Type: "plain_text", Text: text}
+}
+
+// newPayload builds a Block Kit message, summaries are split into
+// company, verdict and link, the rest is rendered by the template.
+//
+// https://api.slack.com/block-kit
+func (s *Slack) newPayload(msg entity.SummaryMessage) (*slackPayload, error) {
+	summary, ok := msg.(*entity.SummaryMsg)
+	if !ok {
+		text, err := s.r.Render("slack", render.KindAlert, render.FormatText, render.NewMessage(msg))
+		if err != nil {
+			return nil, err
+		}
+
+		return &slackPayload{
+			Text:   msg.Summary(),
+			Blocks: []slackBlock{{Type: "section", Text: mrkdwn(text)}},
+		}, nil
+	}
+
+	text, err := s.r.Render("slack", render.KindSummary, render.FormatText, render.NewMessage(summary))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := summary.Fields()
+	blocks := []slackBlock{{Type: "header", Text: plainText("🏢 " + orDefault(fields.Company, "Internship request"))}}
+	if fields.Verdict != "" {
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			Fields: []slackText{
+				*mrkdwn("*Verdict*\n" + render.EscapeSlack(fields.Verdict)),
+				*mrkdwn("*Vacancy*\n" + render.EscapeSlack(orDash(fields.Vacancy))),
+			},
+		})
+	}
+
+	if text != "" {
+		blocks = append(blocks, slackBlock{Type: "section", Text: mrkdwn(text)})
+	}
+
+	if link := summary.Link(); link != "" {
+		blocks = append(blocks, slackBlock{
+			Type: "actions",
+			Elements: []slackElement{
+				{Type: "button", Text: plainText("Open in Gmail"), URL: link},
+			},
+		})
+	}
+
+	return &slackPayload{Text: summary.Summary(), Blocks: blocks}, nil
+}
@@ -0,0 +1,132 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestSummary() *entity.SummaryMsg {
+	return entity.NewSummaryMsg(
+		entity.NewMsg("0", "i4u", "kek", true),
+		"🏢 Company: Acme <Corp>\n📝 Vacancy: Intern\n⛔ Verdict: Reject\n🔎 Reason: No headcount",
+	)
+}
+
+func TestSlack_Send(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         func(url string) *config.Slack
+		handler     func(t *testing.T, calls int32) http.HandlerFunc
+		expectedErr bool
+		calls       int32
+	}{
+		{
+			name: "webhook success",
+			cfg:  func(url string) *config.Slack { return &config.Slack{WebhookURL: url} },
+			handler: func(t *testing.T, _ int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					var p slackPayload
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+
+					assert.Empty(t, p.Channel)
+					assert.Equal(t, "header", p.Blocks[0].Type)
+					assert.Equal(t, "🏢 Acme <Corp>", p.Blocks[0].Text.Text)
+					assert.Equal(t, "*Verdict*\nReject", p.Blocks[1].Fields[0].Text)
+					assert.Equal(t, "No headcount", p.Blocks[2].Text.Text)
+				}
+			},
+			calls: 1,
+		},
+		{
+			name: "webhook rate limited",
+			cfg:  func(url string) *config.Slack { return &config.Slack{WebhookURL: url} },
+			handler: func(t *testing.T, calls int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if calls == 1 {
+						w.Header().Set("Retry-After", "0.01")
+						w.WriteHeader(http.StatusTooManyRequests)
+					}
+				}
+			},
+			calls: 2,
+		},
+		{
+			name: "post message success",
+			cfg: func(url string) *config.Slack {
+				return &config.Slack{Token: "xoxb", Channel: "C1", APIURL: url}
+			},
+			handler: func(t *testing.T, _ int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, "/chat.postMessage", r.URL.Path)
+					assert.Equal(t, "Bearer xoxb", r.Header.Get("Authorization"))
+
+					var p slackPayload
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+					assert.Equal(t, "C1", p.Channel)
+
+					_, _ = w.Write([]byte(`{"ok":true}`))
+				}
+			},
+			calls: 1,
+		},
+		{
+			name: "post message error",
+			cfg: func(url string) *config.Slack {
+				return &config.Slack{Token: "xoxb", Channel: "C1", APIURL: url}
+			},
+			handler: func(t *testing.T, _ int32) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+				}
+			},
+			expectedErr: true,
+			calls:       1,
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.handler(t, calls.Add(1))(w, r)
+			}))
+			defer srv.Close()
+
+			err := NewSlack(srv.Client(), tc.cfg(srv.URL)).Send(context.Background(), newTestSummary())
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.calls, calls.Load())
+		})
+	}
+}
+
+func TestSlack_SendAlert(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p slackPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+
+		assert.Equal(t, "a < b", p.Text)
+		assert.Equal(t, "a &lt; b", p.Blocks[0].Text.Text)
+	}))
+	defer srv.Close()
+
+	s := NewSlack(srv.Client(), &config.Slack{WebhookURL: srv.URL})
+	assert.NoError(t, s.Send(context.Background(), entity.NewAlertMsg(errors.New("a < b"))))
+}
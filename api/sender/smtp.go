@@ -0,0 +1,192 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/render"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// SMTP sends every message as a separate email, it's also used
+// by the Digest for delivering collected summaries.
+type SMTP struct {
+	cfg *config.SMTP
+	r   *render.Renderer
+}
+
+func NewSMTP(cfg *config.SMTP) *SMTP {
+	return &SMTP{cfg: cfg, r: render.Default}
+}
+
+// WithRenderer replaces the built-in templates of the emails,
+// the digest uses the same templates.
+func (s *SMTP) WithRenderer(r *render.Renderer) *SMTP {
+	s.r = r
+	return s
+}
+
+func (s *SMTP) Send(ctx context.Context, msg entity.SummaryMessage) error {
+	subject := "i4u: alert"
+	if summary, ok := msg.(*entity.SummaryMsg); ok {
+		subject = "i4u: " + orDefault(summary.Fields().Company, "internship request")
+	}
+
+	kind, data := render.KindAlert, render.NewMessage(msg)
+	if _, ok := msg.(*entity.SummaryMsg); ok {
+		kind = render.KindSummary
+	}
+
+	text, err := s.r.Render("email", kind, render.FormatText, data)
+	if err != nil {
+		return err
+	}
+
+	htmlBody, err := s.r.Render("email", kind, render.FormatHTML, data)
+	if err != nil {
+		return err
+	}
+
+	return s.SendMail(ctx, subject, text, htmlBody)
+}
+
+// SendMail composes multipart email with both plain text and html
+// alternatives and delivers it to all recipients.
+func (s *SMTP) SendMail(ctx context.Context, subject, text, htmlBody string) error {
+	body, err := s.compose(subject, text, htmlBody)
+	if err != nil {
+		return fmt.Errorf("failed to compose email: %w", err)
+	}
+
+	return s.deliver(ctx, body)
+}
+
+func (s *SMTP) compose(subject, text, htmlBody string) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	headers := []string{
+		"From: " + s.cfg.From,
+		"To: " + strings.Join(s.cfg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: " + messageID(s.cfg.From),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", htmlBody},
+	}
+	for _, p := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err = qp.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+
+		if err = qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	msg.Write(buf.Bytes())
+	return msg.Bytes(), nil
+}
+
+// deliver performs the SMTP session manually, because smtp.SendMail
+// doesn't respect the context.
+func (s *SMTP) deliver(ctx context.Context, body []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.cfg.Addr())
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if e := c.StartTLS(&tls.Config{ServerName: s.cfg.Host, MinVersion: tls.VersionTLS12}); e != nil {
+			return fmt.Errorf("failed to start tls: %w", e)
+		}
+	}
+
+	if s.cfg.Username != "" {
+		auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+		if e := c.Auth(auth); e != nil {
+			return fmt.Errorf("failed to authenticate: %w", e)
+		}
+	}
+
+	if e := c.Mail(s.cfg.From); e != nil {
+		return e
+	}
+
+	for _, to := range s.cfg.To {
+		if e := c.Rcpt(to); e != nil {
+			return fmt.Errorf("recipient %s rejected: %w", to, e)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(body); err != nil {
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func messageID(from string) string {
+	domain := "i4u.local"
+	if _, d, ok := strings.Cut(from, "@"); ok {
+		domain = strings.Trim(d, "> ")
+	}
+
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -2,20 +2,234 @@ package sender
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/render"
+	"github.com/fadyat/i4u/internal/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Card modes, see config.Telegram.CardMode.
+const (
+	CardModeEdit  = "edit"
+	CardModeReply = "reply"
+	CardModeOff   = "off"
 )
 
 type Tg struct {
 	c      *tgbotapi.BotAPI
 	chatID int64
+	r      *render.Renderer
+
+	// cards keeps the messages of the applications, so that updates of
+	// the same application are shown as a single evolving card.
+	cards    *storage.Storage
+	cardMode string
+
+	// cardsMu prevents concurrent updates of the same card, which
+	// may lead to sending duplicates.
+	cardsMu sync.Mutex
+
+	// account is passed to the buttons of the summaries.
+	account string
 }
 
 func NewTg(c *tgbotapi.BotAPI, chatID int64) *Tg {
-	return &Tg{c: c, chatID: chatID}
+	return &Tg{c: c, chatID: chatID, r: render.Default, cardMode: CardModeOff}
+}
+
+// WithRenderer replaces the built-in templates of the messages.
+func (t *Tg) WithRenderer(r *render.Renderer) *Tg {
+	t.r = r
+	return t
+}
+
+// WithAccount marks the buttons of the summaries with the Gmail account,
+// when several accounts are served by the same bot.
+func (t *Tg) WithAccount(name string) *Tg {
+	t.account = name
+	return t
+}
+
+// WithCards enables tracking of the applications, updates are
+// shown according to the mode.
+func (t *Tg) WithCards(store *storage.Storage, mode string) *Tg {
+	t.cards, t.cardMode = store, mode
+	return t
 }
 
 func (t *Tg) Send(_ context.Context, msg entity.SummaryMessage) error {
-	_, err := t.c.Send(tgbotapi.NewMessage(t.chatID, msg.Summary()))
+	summary, ok := msg.(*entity.SummaryMsg)
+
+	// alerts don't need any actions, only summaries can be triaged.
+	if !ok {
+		text, err := t.r.Render("tg", render.KindAlert, render.FormatHTML, render.NewMessage(msg))
+		if err != nil {
+			return err
+		}
+
+		for _, part := range splitMessage(text, tgMaxLength) {
+			if _, err := t.c.Send(t.newMessage(part)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	text, err := t.r.Render("tg", render.KindSummary, render.FormatHTML, render.NewMessage(summary))
+	if err != nil {
+		return err
+	}
+
+	if t.cards == nil || t.cardMode == CardModeOff {
+		_, err = t.sendNew(summary, text, 0)
+		return err
+	}
+
+	return t.sendCard(summary, text)
+}
+
+// sendCard shows the summary on the card of the application,
+// a new card is created, when it's the first summary.
+func (t *Tg) sendCard(summary *entity.SummaryMsg, text string) error {
+	t.cardsMu.Lock()
+	defer t.cardsMu.Unlock()
+
+	fields := summary.Fields()
+	appKey := storage.ApplicationKey(summary.ThreadID(), fields.Company, fields.Vacancy, summary.ID())
+	card, err := t.cards.TgCard(t.chatID, appKey)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("failed to get card: %w", err)
+	}
+
+	if card == nil {
+		card = &storage.TgCard{}
+	}
+
+	// the message is retried, when the next stages failed,
+	// but it's already shown on the card.
+	for _, u := range card.Updates {
+		if u.MessageID == summary.ID() {
+			zap.S().Debugf("message %s is already on the card %s", summary.ID(), appKey)
+			return nil
+		}
+	}
+
+	var (
+		chatMessageID = card.ChatMessageID
+		update        = storage.TgCardUpdate{MessageID: summary.ID(), Verdict: summary.Verdict(), At: time.Now()}
+		updates       = append(card.Updates[:len(card.Updates):len(card.Updates)], update)
+	)
+
+	switch {
+	case card.ChatMessageID == 0:
+		card.ChatMessageID, err = t.sendNew(summary, text, 0)
+	case t.cardMode == CardModeReply:
+		_, err = t.sendNew(summary, text, card.ChatMessageID)
+	default:
+		err = t.edit(summary, text, card, updates)
+	}
+
+	if err != nil {
+		// the first part of the new card is sent, so the retry
+		// updates it instead of posting another one.
+		if card.ChatMessageID != 0 && card.ChatMessageID != chatMessageID {
+			if e := t.cards.SaveTgCard(t.chatID, appKey, card); e != nil {
+				zap.S().Warnf("failed to save card %s: %s", appKey, e)
+			}
+		}
+
+		return err
+	}
+
+	card.Updates = updates
+	if e := t.cards.SaveTgCard(t.chatID, appKey, card); e != nil {
+		return fmt.Errorf("failed to save card: %w", e)
+	}
+
+	return nil
+}
+
+// edit replaces the card content with the latest summary and the history
+// of the application, when the message can't be edited anymore, for example
+// it was deleted or the content doesn't fit, a new card is sent instead.
+func (t *Tg) edit(summary *entity.SummaryMsg, text string, card *storage.TgCard, updates []storage.TgCardUpdate) error {
+	text += "\n\n" + formatHistory(cardHistory(updates))
+	if utf16Len(text) > tgMaxLength {
+		var err error
+		card.ChatMessageID, err = t.sendNew(summary, text, 0)
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(
+		t.chatID, card.ChatMessageID, text, ActionsKeyboard(t.account, summary.ID(), summary.Link()),
+	)
+	edit.ParseMode = tgbotapi.ModeHTML
+
+	_, err := t.c.Send(edit)
+	switch {
+	case err == nil:
+		return nil
+	case strings.Contains(err.Error(), "message is not modified"):
+		return nil
+	case strings.Contains(err.Error(), "message to edit not found"),
+		strings.Contains(err.Error(), "message can't be edited"):
+		card.ChatMessageID, err = t.sendNew(summary, text, 0)
+		return err
+	}
+
 	return err
 }
+
+// sendNew sends the summary, splitting it into several messages, when
+// it's too long, the keyboard is attached to the last one; the id of the
+// first message is returned, because it's the one the user sees first.
+func (t *Tg) sendNew(summary *entity.SummaryMsg, text string, replyTo int) (int, error) {
+	parts := splitMessage(text, tgMaxLength)
+
+	var firstID int
+	for i, part := range parts {
+		m := t.newMessage(part)
+		if i == 0 {
+			m.ReplyToMessageID = replyTo
+		}
+
+		if i == len(parts)-1 {
+			m.ReplyMarkup = ActionsKeyboard(t.account, summary.ID(), summary.Link())
+		}
+
+		sent, err := t.c.Send(m)
+		if err != nil {
+			return firstID, err
+		}
+
+		if i == 0 {
+			firstID = sent.MessageID
+		}
+	}
+
+	return firstID, nil
+}
+
+func (t *Tg) newMessage(text string) tgbotapi.MessageConfig {
+	m := tgbotapi.NewMessage(t.chatID, text)
+	m.ParseMode = tgbotapi.ModeHTML
+	m.DisableWebPagePreview = true
+	return m
+}
+
+// cardHistory renders the verdicts of the application in chronological order.
+func cardHistory(updates []storage.TgCardUpdate) string {
+	steps := make([]string, 0, len(updates))
+	for _, u := range updates {
+		steps = append(steps, fmt.Sprintf("%s (%s)", u.Verdict, u.At.Format("Jan 2")))
+	}
+
+	return "History: " + strings.Join(steps, " → ")
+}
@@ -0,0 +1,154 @@
+package sender
+
+import (
+	"github.com/fadyat/i4u/internal/entity"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+)
+
+// Action is a reaction of the user to the summary, made via inline
+// keyboard under the Telegram message.
+type Action string
+
+const (
+	ActionNotInternship Action = "ni"
+	ActionChangeVerdict Action = "cv"
+	ActionSetVerdict    Action = "sv"
+	ActionSnooze        Action = "sz"
+	ActionReplied       Action = "rp"
+	ActionArchive       Action = "ar"
+	ActionBack          Action = "bk"
+)
+
+// callbackSep separates parts of the callback data, Telegram limits
+// callback data to 64 bytes, so it's kept as short as possible.
+const callbackSep = "|"
+
+// Callback is a parsed callback data of the inline keyboard button.
+type Callback struct {
+	Action    Action
+	MessageID string
+
+	// Verdict is set only for ActionSetVerdict.
+	Verdict entity.Verdict
+
+	// Account is the Gmail account of the message, it's empty, when
+	// a single account is served, keeping the data of old buttons valid.
+	Account string
+}
+
+func (c Callback) String() string {
+	parts := []string{string(c.Action), c.MessageID}
+	if c.Verdict != "" || c.Account != "" {
+		parts = append(parts, string(c.Verdict))
+	}
+
+	if c.Account != "" {
+		parts = append(parts, c.Account)
+	}
+
+	return strings.Join(parts, callbackSep)
+}
+
+// ParseCallback parses the callback data, created by the actions keyboard.
+func ParseCallback(data string) (Callback, bool) {
+	parts := strings.Split(data, callbackSep)
+	if len(parts) < 2 || parts[1] == "" {
+		return Callback{}, false
+	}
+
+	if len(parts) > 4 {
+		return Callback{}, false
+	}
+
+	c := Callback{Action: Action(parts[0]), MessageID: parts[1]}
+	if len(parts) == 4 {
+		c.Account = parts[3]
+	}
+
+	if c.Action == ActionSetVerdict {
+		if len(parts) < 3 || !entity.Verdict(parts[2]).IsValid() {
+			return Callback{}, false
+		}
+
+		c.Verdict = entity.Verdict(parts[2])
+	}
+
+	return c, true
+}
+
+func button(text string, c Callback) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(text, c.String())
+}
+
+// openLinkText is a text of the URL button, leading to the original message.
+const openLinkText = "📬 Open in Gmail"
+
+// linkRows returns a row with the URL button, when the link is known.
+func linkRows(link string) [][]tgbotapi.InlineKeyboardButton {
+	if link == "" {
+		return nil
+	}
+
+	return [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL(openLinkText, link)),
+	}
+}
+
+// LinkFromKeyboard returns the link of the URL button, so it can be kept
+// when the keyboard is replaced.
+func LinkFromKeyboard(kb *tgbotapi.InlineKeyboardMarkup) string {
+	if kb == nil {
+		return ""
+	}
+
+	for _, row := range kb.InlineKeyboard {
+		for _, b := range row {
+			if b.URL != nil && b.Text == openLinkText {
+				return *b.URL
+			}
+		}
+	}
+
+	return ""
+}
+
+// ActionsKeyboard is attached to each summary, allowing the user to
+// triage it without opening Gmail, the buttons carry the account of
+// the message, so the action is applied to the right inbox.
+func ActionsKeyboard(account, msgID, link string) tgbotapi.InlineKeyboardMarkup {
+	callback := func(a Action) Callback {
+		return Callback{Action: a, MessageID: msgID, Account: account}
+	}
+
+	rows := linkRows(link)
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			button("🙅 Not an internship", callback(ActionNotInternship)),
+			button("✏️ Change verdict", callback(ActionChangeVerdict)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button("😴 Snooze", callback(ActionSnooze)),
+			button("✅ Mark replied", callback(ActionReplied)),
+			button("🗄 Archive", callback(ActionArchive)),
+		),
+	)
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// VerdictsKeyboard is shown instead of the actions keyboard,
+// when the user wants to correct the verdict.
+func VerdictsKeyboard(account, msgID, link string) tgbotapi.InlineKeyboardMarkup {
+	rows := linkRows(link)
+	for _, v := range entity.Verdicts {
+		c := Callback{Action: ActionSetVerdict, MessageID: msgID, Verdict: v, Account: account}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button(string(v), c)))
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		button("⬅️ Back", Callback{Action: ActionBack, MessageID: msgID, Account: account}),
+	))
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
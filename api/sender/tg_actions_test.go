@@ -0,0 +1,59 @@
+package sender
+
+import (
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseCallback(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected Callback
+		ok       bool
+	}{
+		{
+			name:     "action",
+			data:     "ar|18a",
+			expected: Callback{Action: ActionArchive, MessageID: "18a"},
+			ok:       true,
+		},
+		{
+			name:     "verdict",
+			data:     "sv|18a|offer",
+			expected: Callback{Action: ActionSetVerdict, MessageID: "18a", Verdict: entity.VerdictOffer},
+			ok:       true,
+		},
+		{
+			name:     "action of account",
+			data:     "ar|18a||alice",
+			expected: Callback{Action: ActionArchive, MessageID: "18a", Account: "alice"},
+			ok:       true,
+		},
+		{
+			name:     "verdict of account",
+			data:     "sv|18a|offer|alice",
+			expected: Callback{Action: ActionSetVerdict, MessageID: "18a", Verdict: entity.VerdictOffer, Account: "alice"},
+			ok:       true,
+		},
+		{name: "no message", data: "ar|"},
+		{name: "unknown verdict", data: "sv|18a|kek|alice"},
+		{name: "too many parts", data: "ar|18a||alice|bob"},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, ok := ParseCallback(tc.data)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, c)
+			if ok {
+				assert.Equal(t, tc.data, c.String())
+			}
+		})
+	}
+}
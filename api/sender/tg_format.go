@@ -0,0 +1,109 @@
+package sender
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// tgMaxLength is a limit of the message text length, Telegram counts it
+// in UTF-16 code units after parsing the entities, so counting the raw
+// html is a safe upper bound.
+//
+// https://core.telegram.org/bots/api#sendmessage
+const tgMaxLength = 4096
+
+func formatHistory(updates string) string {
+	return "<i>" + html.EscapeString(updates) + "</i>"
+}
+
+// utf16RuneLen returns the number of UTF-16 code units of the rune,
+// runes outside of the basic multilingual plane take a surrogate pair.
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
+
+// utf16Len returns the length of the string in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+
+	return n
+}
+
+// splitMessage splits html text into parts, which fit into the limit.
+// Text is split by lines, because the formatting tags never span
+// multiple lines; too long lines are split by runes, keeping
+// html entities, like &amp;, untouched.
+func splitMessage(text string, limit int) []string {
+	if utf16Len(text) <= limit {
+		return []string{text}
+	}
+
+	var (
+		parts []string
+		cur   strings.Builder
+		size  int
+	)
+
+	flush := func() {
+		if cur.Len() > 0 {
+			parts = append(parts, strings.TrimRight(cur.String(), "\n"))
+			cur.Reset()
+			size = 0
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		n := utf16Len(line)
+		if size+n <= limit {
+			cur.WriteString(line)
+			size += n
+			continue
+		}
+
+		flush()
+		for utf16Len(line) > limit {
+			head, tail := cutLine(line, limit)
+			parts = append(parts, head)
+			line = tail
+		}
+
+		cur.WriteString(line)
+		size = utf16Len(line)
+	}
+
+	flush()
+	return parts
+}
+
+// cutLine cuts the line, so the head fits into the limit, preferring
+// to cut by space and never cutting inside the html entity.
+func cutLine(line string, limit int) (head, tail string) {
+	cut, size := 0, 0
+	for i, r := range line {
+		n := utf16RuneLen(r)
+		if size+n > limit {
+			break
+		}
+
+		size += n
+		cut = i + utf8.RuneLen(r)
+	}
+
+	if amp := strings.LastIndexByte(line[:cut], '&'); amp > 0 && !strings.Contains(line[amp:cut], ";") {
+		cut = amp
+	}
+
+	if space := strings.LastIndexByte(line[:cut], ' '); space > cut/2 {
+		cut = space + 1
+	}
+
+	return line[:cut], line[cut:]
+}
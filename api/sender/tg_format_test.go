@@ -0,0 +1,69 @@
+package sender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "fits",
+			text:     "<b>a</b>\nb",
+			limit:    20,
+			expected: []string{"<b>a</b>\nb"},
+		},
+		{
+			name:     "split by lines",
+			text:     "<b>aaaa</b>\nbbbb\ncccc",
+			limit:    17,
+			expected: []string{"<b>aaaa</b>\nbbbb", "cccc"},
+		},
+		{
+			name:     "long line split by spaces",
+			text:     "aaaa bbbb cccc",
+			limit:    10,
+			expected: []string{"aaaa bbbb ", "cccc"},
+		},
+		{
+			name:     "entity isn't cut",
+			text:     "aaaaaa&amp;b",
+			limit:    8,
+			expected: []string{"aaaaaa", "&amp;b"},
+		},
+		{
+			name:     "surrogate pairs are counted twice",
+			text:     "😀😀😀",
+			limit:    4,
+			expected: []string{"😀😀", "😀"},
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parts := splitMessage(tc.text, tc.limit)
+			assert.Equal(t, tc.expected, parts)
+			for _, p := range parts {
+				assert.LessOrEqual(t, utf16Len(p), tc.limit)
+			}
+		})
+	}
+}
+
+func TestSplitMessage_Long(t *testing.T) {
+	text := strings.Repeat("line &amp; more\n", 1000)
+
+	parts := splitMessage(text, tgMaxLength)
+	assert.Greater(t, len(parts), 1)
+	assert.Equal(t, strings.TrimRight(text, "\n"), strings.Join(parts, "\n"))
+}
@@ -0,0 +1,302 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type tgCall struct {
+	method string
+	params map[string]string
+}
+
+// tgStandIn is a fake Telegram Bot API, it records all calls and
+// responds with incrementing message ids.
+type tgStandIn struct {
+	mu     sync.Mutex
+	calls  []tgCall
+	nextID int
+
+	// editErr is returned on editMessageText, when not empty.
+	editErr string
+
+	// sendErr is returned on the next sendMessage, after the failSend
+	// messages are sent, when not empty.
+	sendErr  string
+	failSend int
+}
+
+func (s *tgStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+
+	params := make(map[string]string, len(r.Form))
+	for k := range r.Form {
+		params[k] = r.Form.Get(k)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	method := path.Base(r.URL.Path)
+	s.calls = append(s.calls, tgCall{method: method, params: params})
+
+	if method == "editMessageText" && s.editErr != "" {
+		_, _ = fmt.Fprintf(w, `{"ok":false,"error_code":400,"description":%q}`, s.editErr)
+		return
+	}
+
+	if method == "sendMessage" && s.sendErr != "" {
+		if s.failSend == 0 {
+			_, _ = fmt.Fprintf(w, `{"ok":false,"error_code":500,"description":%q}`, s.sendErr)
+			s.sendErr = ""
+			return
+		}
+
+		s.failSend--
+	}
+
+	if method != "getMe" {
+		s.nextID++
+	}
+
+	result, _ := json.Marshal(map[string]any{
+		"message_id": s.nextID,
+		"chat":       map[string]any{"id": 1},
+		"from":       map[string]any{"id": 1, "is_bot": true, "username": "i4u"},
+		"id":         1,
+		"is_bot":     true,
+	})
+	_, _ = fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+}
+
+func (s *tgStandIn) methods() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	methods := make([]string, 0, len(s.calls))
+	for _, c := range s.calls {
+		methods = append(methods, c.method)
+	}
+
+	return methods
+}
+
+func (s *tgStandIn) last() tgCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[len(s.calls)-1]
+}
+
+func newTestTg(t *testing.T, mode string) (*Tg, *tgStandIn) {
+	standIn := &tgStandIn{}
+	srv := httptest.NewServer(standIn)
+	t.Cleanup(srv.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", srv.Client())
+	require.NoError(t, err)
+
+	store, err := storage.Open(filepath.Join(t.TempDir(), "i4u.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	return NewTg(bot, 1).WithCards(store, mode), standIn
+}
+
+func newAcmeSummary(id, verdict string) *entity.SummaryMsg {
+	return entity.NewSummaryMsg(
+		entity.NewMsg(id, "i4u", "kek", true),
+		"Company: Acme\nVerdict: "+verdict,
+	)
+}
+
+func TestTg_Send(t *testing.T) {
+	testCases := []struct {
+		name            string
+		mode            string
+		editErr         string
+		expectedMethods []string
+		check           func(t *testing.T, last tgCall)
+	}{
+		{
+			name:            "edit card",
+			mode:            CardModeEdit,
+			expectedMethods: []string{"getMe", "sendMessage", "editMessageText", "sendMessage"},
+			check: func(t *testing.T, last tgCall) {
+				assert.Empty(t, last.params["reply_to_message_id"])
+			},
+		},
+		{
+			name:            "reply to card",
+			mode:            CardModeReply,
+			expectedMethods: []string{"getMe", "sendMessage", "sendMessage", "sendMessage"},
+			check: func(t *testing.T, last tgCall) {
+				assert.Empty(t, last.params["reply_to_message_id"])
+			},
+		},
+		{
+			name:            "edited card was deleted",
+			mode:            CardModeEdit,
+			editErr:         "Bad Request: message to edit not found",
+			expectedMethods: []string{"getMe", "sendMessage", "editMessageText", "sendMessage", "sendMessage"},
+		},
+		{
+			name:            "cards are disabled",
+			mode:            CardModeOff,
+			expectedMethods: []string{"getMe", "sendMessage", "sendMessage", "sendMessage"},
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tg, standIn := newTestTg(t, tc.mode)
+			standIn.editErr = tc.editErr
+
+			ctx := context.Background()
+			require.NoError(t, tg.Send(ctx, newAcmeSummary("1", "Interview")))
+			require.NoError(t, tg.Send(ctx, newAcmeSummary("2", "Reject")))
+			require.NoError(t, tg.Send(ctx, entity.NewSummaryMsg(
+				entity.NewMsg("3", "i4u", "kek", true), "Company: Globex\nVerdict: Offer",
+			)))
+
+			assert.Equal(t, tc.expectedMethods, standIn.methods())
+			if tc.check != nil {
+				tc.check(t, standIn.last())
+			}
+		})
+	}
+}
+
+func TestTg_SendEditedCard(t *testing.T) {
+	tg, standIn := newTestTg(t, CardModeEdit)
+
+	ctx := context.Background()
+	require.NoError(t, tg.Send(ctx, newAcmeSummary("1", "Interview")))
+	require.NoError(t, tg.Send(ctx, newAcmeSummary("2", "Reject")))
+
+	edit := standIn.last()
+	assert.Equal(t, "editMessageText", edit.method)
+	assert.Equal(t, "1", edit.params["message_id"])
+	assert.Contains(t, edit.params["text"], "Verdict: Reject")
+	assert.Contains(t, edit.params["text"], "interview (")
+	assert.Contains(t, edit.params["reply_markup"], "ni|2")
+
+	standIn.mu.Lock()
+	first := standIn.calls[1]
+	standIn.mu.Unlock()
+	assert.Contains(t, first.params["reply_markup"], "ni|1")
+}
+
+func TestTg_SendReply(t *testing.T) {
+	tg, standIn := newTestTg(t, CardModeReply)
+
+	ctx := context.Background()
+	require.NoError(t, tg.Send(ctx, newAcmeSummary("1", "Interview")))
+	require.NoError(t, tg.Send(ctx, newAcmeSummary("2", "Reject")))
+
+	assert.Equal(t, "1", standIn.last().params["reply_to_message_id"])
+}
+
+func TestTg_SendThreadCard(t *testing.T) {
+	tg, standIn := newTestTg(t, CardModeReply)
+
+	threaded := func(id, thread, summary string) *entity.SummaryMsg {
+		return entity.NewSummaryMsg(entity.NewMsg(id, "i4u", "kek", true).WithThreadID(thread), summary)
+	}
+
+	// the reply in the thread is on the same card, even when the company is named differently.
+	ctx := context.Background()
+	require.NoError(t, tg.Send(ctx, threaded("1", "t1", "Company: Acme\nVacancy: Intern\nVerdict: Interview")))
+	require.NoError(t, tg.Send(ctx, threaded("2", "t1", "Company: Acme Corp\nVerdict: Reject")))
+	assert.Equal(t, "1", standIn.last().params["reply_to_message_id"])
+
+	// the other vacancy of the company is a separate card.
+	require.NoError(t, tg.Send(ctx, newAcmeSummary("3", "Interview")))
+	require.NoError(t, tg.Send(ctx, entity.NewSummaryMsg(
+		entity.NewMsg("4", "i4u", "kek", true), "Company: Acme\nVacancy: Backend\nVerdict: Offer",
+	)))
+	assert.Empty(t, standIn.last().params["reply_to_message_id"])
+}
+
+func TestTg_SendRetried(t *testing.T) {
+	tg, standIn := newTestTg(t, CardModeReply)
+
+	// the retried message doesn't duplicate the reply and the history.
+	ctx := context.Background()
+	require.NoError(t, tg.Send(ctx, newAcmeSummary("1", "Interview")))
+	require.NoError(t, tg.Send(ctx, newAcmeSummary("1", "Interview")))
+	assert.Equal(t, []string{"getMe", "sendMessage"}, standIn.methods())
+
+	card, err := tg.cards.TgCard(1, storage.ApplicationKey("", "Acme", "", "1"))
+	require.NoError(t, err)
+	assert.Len(t, card.Updates, 1)
+}
+
+func TestTg_SendCardPartFailed(t *testing.T) {
+	tg, standIn := newTestTg(t, CardModeEdit)
+	standIn.sendErr, standIn.failSend = "Internal Server Error", 1
+
+	// the second part of the new card isn't sent.
+	long := entity.NewSummaryMsg(
+		entity.NewMsg("1", "i4u", "kek", true),
+		"Company: Acme\nVerdict: Interview\nReason: "+strings.Repeat("kek ", 2000),
+	)
+	ctx := context.Background()
+	require.Error(t, tg.Send(ctx, long))
+
+	// the retry updates the sent card instead of posting another one.
+	require.NoError(t, tg.Send(ctx, newAcmeSummary("1", "Interview")))
+	assert.Equal(t, []string{"getMe", "sendMessage", "sendMessage", "editMessageText"}, standIn.methods())
+	assert.Equal(t, "1", standIn.last().params["message_id"])
+}
+
+// linkedMsg is a message with the link, which can't be set via entity.NewMsg.
+type linkedMsg struct {
+	*entity.Msg
+	link string
+}
+
+func (m linkedMsg) Link() string { return m.link }
+
+func TestTg_SendFormatted(t *testing.T) {
+	tg, standIn := newTestTg(t, CardModeOff)
+
+	link := "https://mail.google.com/mail/u/0/#inbox/1"
+	require.NoError(t, tg.Send(context.Background(), entity.NewSummaryMsg(
+		linkedMsg{Msg: entity.NewMsg("1", "i4u", "kek", true), link: link},
+		"Company: Acme <Corp>\nVerdict: Offer",
+	)))
+
+	sent := standIn.last()
+	assert.Equal(t, "HTML", sent.params["parse_mode"])
+	assert.Equal(t, "<b>Company: Acme &lt;Corp&gt;</b>\nVerdict: Offer", sent.params["text"])
+	assert.Contains(t, sent.params["reply_markup"], link)
+	assert.Contains(t, sent.params["reply_markup"], "Open in Gmail")
+}
+
+func TestTg_SendLongAlert(t *testing.T) {
+	tg, standIn := newTestTg(t, CardModeOff)
+
+	err := fmt.Errorf("%s", strings.Repeat("failed <to> fetch\n", 500))
+	require.NoError(t, tg.Send(context.Background(), entity.NewAlertMsg(err)))
+
+	assert.Equal(t, []string{"getMe", "sendMessage", "sendMessage", "sendMessage"}, standIn.methods())
+	assert.Contains(t, standIn.last().params["text"], "failed &lt;to&gt; fetch")
+}
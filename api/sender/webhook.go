@@ -0,0 +1,114 @@
+package sender
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader contains hex encoded HMAC-SHA256 of the
+	// "<timestamp>.<body>" string, prefixed with "sha256=".
+	SignatureHeader = "X-I4U-Signature"
+
+	// TimestampHeader contains unix time of the request, it's a part of
+	// the signed content to protect the receiver from replay attacks.
+	TimestampHeader = "X-I4U-Timestamp"
+)
+
+// WebhookPayload is a body of the request, sent by the Webhook sender.
+//
+//	{
+//	  "kind": "summary",
+//	  "message_id": "18a4c5c7e4c2f3a1",
+//	  "link": "https://mail.google.com/mail/u/0/#inbox/18a4c5c7e4c2f3a1",
+//	  "verdict": "reject",
+//	  "summary": "🏢 Company: Acme\n📝 Vacancy: Intern\n⛔ Verdict: Reject\n🔎 Reason: ...",
+//	  "metadata": {"company": "Acme", "vacancy": "Intern", "verdict": "Reject", "reason": "..."},
+//	  "sent_at": "2023-09-01T12:00:00Z"
+//	}
+//
+// Alerts have "alert" kind, the error text in the summary and
+// empty message-related fields.
+type WebhookPayload struct {
+	Kind      string            `json:"kind"`
+	MessageID string            `json:"message_id,omitempty"`
+	Link      string            `json:"link,omitempty"`
+	Verdict   entity.Verdict    `json:"verdict,omitempty"`
+	Summary   string            `json:"summary"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	SentAt    time.Time         `json:"sent_at"`
+}
+
+// Webhook posts summaries to any HTTP endpoint, useful for integrations
+// with automation services or own applications.
+type Webhook struct {
+	c   *http.Client
+	cfg *config.Webhook
+}
+
+func NewWebhook(c *http.Client, cfg *config.Webhook) *Webhook {
+	return &Webhook{c: c, cfg: cfg}
+}
+
+func (w *Webhook) Send(ctx context.Context, msg entity.SummaryMessage) error {
+	body, err := json.Marshal(newWebhookPayload(msg, time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	headers := make(http.Header, len(w.cfg.Headers)+2)
+	for k, v := range w.cfg.Headers {
+		headers.Set(k, v)
+	}
+
+	if w.cfg.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		headers.Set(TimestampHeader, ts)
+		headers.Set(SignatureHeader, "sha256="+Sign(w.cfg.Secret, ts, body))
+	}
+
+	_, err = post(ctx, w.c, w.cfg.URL, headers, body)
+	return err
+}
+
+// Sign computes the signature of the request, receivers can use it
+// for verifying that the request was sent by i4u.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookPayload(msg entity.SummaryMessage, now time.Time) *WebhookPayload {
+	summary, ok := msg.(*entity.SummaryMsg)
+	if !ok {
+		return &WebhookPayload{Kind: "alert", Summary: msg.Summary(), SentAt: now.UTC()}
+	}
+
+	fields := summary.Fields()
+	return &WebhookPayload{
+		Kind:      "summary",
+		MessageID: summary.ID(),
+		Link:      summary.Link(),
+		Verdict:   summary.Verdict(),
+		Summary:   summary.Text(),
+		Metadata: map[string]string{
+			"company": fields.Company,
+			"vacancy": fields.Vacancy,
+			"verdict": fields.Verdict,
+			"reason":  fields.Reason,
+		},
+		SentAt: now.UTC(),
+	}
+}
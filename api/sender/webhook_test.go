@@ -0,0 +1,89 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhook_Send(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      config.Webhook
+		msg      entity.SummaryMessage
+		expected WebhookPayload
+	}{
+		{
+			name: "signed summary",
+			cfg: config.Webhook{
+				Headers: map[string]string{"Authorization": "Bearer kek"},
+				Secret:  "secret",
+			},
+			msg: newTestSummary(),
+			expected: WebhookPayload{
+				Kind:      "summary",
+				MessageID: "0",
+				Verdict:   entity.VerdictReject,
+				Summary:   newTestSummary().Text(),
+				Metadata: map[string]string{
+					"company": "Acme <Corp>",
+					"vacancy": "Intern",
+					"verdict": "Reject",
+					"reason":  "No headcount",
+				},
+			},
+		},
+		{
+			name: "unsigned alert",
+			msg:  entity.NewAlertMsg(errors.New("kek")),
+			expected: WebhookPayload{
+				Kind:    "alert",
+				Summary: "kek",
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				for k, v := range tc.cfg.Headers {
+					assert.Equal(t, v, r.Header.Get(k))
+				}
+
+				if tc.cfg.Secret == "" {
+					assert.Empty(t, r.Header.Get(SignatureHeader))
+				} else {
+					expected := Sign(tc.cfg.Secret, r.Header.Get(TimestampHeader), body)
+					assert.Equal(t, "sha256="+expected, r.Header.Get(SignatureHeader))
+				}
+
+				var p WebhookPayload
+				require.NoError(t, json.Unmarshal(body, &p))
+				assert.False(t, p.SentAt.IsZero())
+
+				p.SentAt = tc.expected.SentAt
+				assert.Equal(t, tc.expected, p)
+			}))
+			defer srv.Close()
+
+			cfg := tc.cfg
+			cfg.URL = srv.URL
+			assert.NoError(t, NewWebhook(srv.Client(), &cfg).Send(context.Background(), tc.msg))
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/fadyat/i4u/internal/config"
 	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/metrics"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -37,6 +38,9 @@ func (o *OpenAI) GetMsgSummary(ctx context.Context, msg entity.Message) (string,
 		return "", err
 	}
 
+	metrics.LLMTokens.WithLabelValues("prompt").Add(float64(resp.Usage.PromptTokens))
+	metrics.LLMTokens.WithLabelValues("completion").Add(float64(resp.Usage.CompletionTokens))
+
 	if len(resp.Choices) == 0 {
 		return "", errors.New("no responses returned")
 	}
@@ -33,10 +33,21 @@ func openBrowser(u string) error {
 	return cmd.Start()
 }
 
-func authorize(gmailConfig *config.Gmail) *cobra.Command {
-	var oauth2Config = token.GetOAuthConfig(gmailConfig)
+// tokenStore opens the token file of the account, the token is
+// encrypted, when the passphrase or the key file is configured.
+func tokenStore(gmailConfig *config.Gmail, account *config.Account) (*token.Store, error) {
+	key, err := gmailConfig.TokenKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return token.NewStore(account.TokenFile, key), nil
+}
+
+func authorize(cfg *config.Config) *cobra.Command {
+	var accountName string
 
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "auth",
 		Args:  cobra.NoArgs,
 		Short: "Grant access to your Gmail account to i4u",
@@ -45,8 +56,24 @@ This command will guide you through the process of granting access to your Gmail
 account to i4u. It will open a browser window and ask you to login to your
 Google account and grant access to i4u. After that, it will save the token
 on your local machine and you will be able to use i4u without having to
-authenticate again.`,
+authenticate again. The token is encrypted, when gmail.token_passphrase
+or gmail.token_key_file is configured.
+
+When several accounts are configured, the account is chosen by --account,
+each account keeps its own token.`,
 		Run: func(cmd *cobra.Command, _ []string) {
+			account, err := cfg.Account(accountName)
+			if err != nil {
+				zap.L().Fatal("failed to choose account", zap.Error(err))
+			}
+
+			tokens, err := tokenStore(&cfg.Gmail, account)
+			if err != nil {
+				zap.L().Fatal("failed to open token file", zap.Error(err))
+			}
+
+			var oauth2Config = token.GetOAuthConfig(&cfg.Gmail)
+
 			done := make(chan bool)
 			defer close(done)
 
@@ -54,7 +81,14 @@ authenticate again.`,
 			signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 			defer close(signalChan)
 
-			authURL := oauth2Config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+			// the browser may be logged in to the other account, so
+			// Google asks, which one to grant, when there are several.
+			opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+			if len(cfg.Accounts) > 0 {
+				opts = append(opts, oauth2.SetAuthURLParam("prompt", "select_account"))
+			}
+
+			authURL := oauth2Config.AuthCodeURL("state-token", opts...)
 			go func() {
 				if err := openBrowser(authURL); err != nil {
 					zap.L().Fatal("failed to open browser", zap.Error(err))
@@ -80,7 +114,7 @@ authenticate again.`,
 						return
 					}
 
-					if e = token.Save(gmailConfig.TokenFile, tok); e != nil {
+					if e = tokens.Save(tok); e != nil {
 						zap.L().Info("failed to save token", zap.Error(e))
 						_, _ = w.Write([]byte("failed to save token"))
 						return
@@ -111,4 +145,7 @@ authenticate again.`,
 			}
 		},
 	}
+
+	cmd.Flags().StringVar(&accountName, "account", "", "name of the account from the config")
+	return cmd
 }
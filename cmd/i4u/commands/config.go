@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+)
+
+func configCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Validate and inspect the configuration",
+		Long: `
+The configuration is read from .i4u/config.yaml, environment variables
+override the values from it. The profile from the file is chosen by
+the profile field or by I4U_PROFILE variable.
+`,
+	}
+
+	cmd.AddCommand(configValidate(cfg))
+	cmd.AddCommand(configShow(cfg))
+	return cmd
+}
+
+func configValidate(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Args:  cobra.NoArgs,
+		Short: "Check the configuration before running the pipeline",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := cfg.Validate(); err != nil {
+				log.Fatalf("config is invalid:\n%s", err)
+			}
+
+			fmt.Println("config is valid")
+		},
+	}
+}
+
+func configShow(cfg *config.Config) *cobra.Command {
+	var redacted bool
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Args:  cobra.NoArgs,
+		Short: "Print the configuration with the profile and the environment applied",
+		Run: func(cmd *cobra.Command, _ []string) {
+			content, err := cfg.YAML(redacted)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			_, _ = os.Stdout.Write(content)
+		},
+	}
+
+	cmd.Flags().BoolVar(&redacted, "redacted", false, "mask the secrets, like tokens and passwords")
+	return cmd
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+func dlq(cfg *config.Config) *cobra.Command {
+	var accountName string
+
+	cmd := &cobra.Command{
+		Use:   "dlq",
+		Short: "Inspect and replay messages, which failed after all retries",
+		Long: `
+Messages, which failed to pass the pipeline stage after all retries,
+are kept in the dead-letter queue. They can be returned to the stage
+they failed at, after the cause is fixed, or removed.
+
+Storage is locked by the running application, so stop it first,
+retried messages will be processed on the next start.
+
+Every account has its own queues, the account is chosen by --account,
+when several accounts are configured.
+`,
+	}
+
+	open := func() *storage.Storage { return openStorage(cfg, accountName) }
+	cmd.AddCommand(dlqList(open))
+	cmd.AddCommand(dlqApply(open, "retry", "Return messages to the stage they failed at", (*storage.Storage).RetryDeadLetter))
+	cmd.AddCommand(dlqApply(open, "purge", "Remove messages forever", (*storage.Storage).PurgeDeadLetter))
+	cmd.PersistentFlags().StringVar(&accountName, "account", "", "name of the account from the config")
+	return cmd
+}
+
+// openStorage opens the storage of the account, the name may be
+// omitted, when there is only one account.
+func openStorage(cfg *config.Config, accountName string) *storage.Storage {
+	account, err := cfg.Account(accountName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := storage.Open(account.StoragePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return store
+}
+
+func dlqList(openStorage func() *storage.Storage) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Args:  cobra.NoArgs,
+		Short: "List dead letters",
+		Run: func(cmd *cobra.Command, _ []string) {
+			store := openStorage()
+			defer func() { _ = store.Close() }()
+
+			letters, err := store.DeadLetters()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "ID\tSTAGE\tMESSAGE\tATTEMPTS\tFAILED AT\tERROR")
+			for i := range letters {
+				dl := &letters[i]
+				_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n",
+					dl.ID, dl.Stage, dl.Item.Msg.ID(), dl.Item.Attempts, dl.FailedAt.Format(time.DateTime), dl.Error)
+			}
+
+			_ = w.Flush()
+		},
+	}
+}
+
+// dlqApply creates a command, which applies the action to the dead
+// letters with the given ids, or to all of them with --all flag.
+func dlqApply(
+	openStorage func() *storage.Storage,
+	use, short string,
+	action func(*storage.Storage, uint64) error,
+) *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   use + " [id...]",
+		Short: short,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 0) {
+				return errors.New("either ids or --all flag must be provided")
+			}
+
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			store := openStorage()
+			defer func() { _ = store.Close() }()
+
+			ids := make([]uint64, 0, len(args))
+			for _, arg := range args {
+				id, err := strconv.ParseUint(arg, 10, 64)
+				if err != nil {
+					log.Fatalf("invalid id: %s", arg)
+				}
+
+				ids = append(ids, id)
+			}
+
+			if all {
+				letters, err := store.DeadLetters()
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				for i := range letters {
+					ids = append(ids, letters[i].ID)
+				}
+			}
+
+			for _, id := range ids {
+				if err := action(store, id); err != nil {
+					log.Fatalf("%s %d: %s", use, id, err)
+				}
+			}
+
+			fmt.Printf("%s: %d message(s)\n", use, len(ids))
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "apply to all dead letters")
+	return cmd
+}
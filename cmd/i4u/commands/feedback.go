@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"encoding/json"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+)
+
+func feedback(cfg *config.Config) *cobra.Command {
+	var accountName string
+
+	cmd := &cobra.Command{
+		Use:   "feedback",
+		Args:  cobra.NoArgs,
+		Short: "Export collected feedback",
+		Long: `
+This command will print the feedback, collected via Telegram buttons
+under the summaries, one JSON object per line. It can be used for
+tuning the analyzer keywords and the summarizer prompts.
+
+Storage is locked by the running application, so stop it first.
+Every account has its own feedback, the account is chosen by --account,
+when several accounts are configured.
+`,
+		Run: func(cmd *cobra.Command, _ []string) {
+			store := openStorage(cfg, accountName)
+			defer func() { _ = store.Close() }()
+
+			records, err := store.Feedback()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			for i := range records {
+				if e := enc.Encode(&records[i]); e != nil {
+					log.Fatal(e)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&accountName, "account", "", "name of the account from the config")
+	return cmd
+}
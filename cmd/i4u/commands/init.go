@@ -5,12 +5,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func Init(
-	gmailConfig *config.Gmail,
-	gptConfig *config.GPT,
-	tgConfig *config.Telegram,
-	appConfig *config.AppConfig,
-) *cobra.Command {
+func Init(cfg *config.Config) *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "i4u",
 		Short: "i4u is your personal assistant to manage your internship requests",
@@ -20,7 +15,7 @@ emails and try to understand is this message is an internship request
 or not.
 
 If current unread message is an internship request, that summary
-will be sent to the Telegram channel.
+will be sent to the Telegram, Slack, Discord or any webhook.
 
 When message is processed, it will get an label "i4u"
 to avoid processing it again.
@@ -28,16 +23,19 @@ to avoid processing it again.
 		CompletionOptions: cobra.CompletionOptions{
 			DisableDefaultCmd: true,
 		},
-		Version: appConfig.Version,
+		Version: cfg.App.Version,
 	}
 
-	if appConfig.IsDev() {
-		rootCmd.AddCommand(devOpenAI(gptConfig))
-		rootCmd.AddCommand(devTg(tgConfig))
+	if cfg.App.IsDev() {
+		rootCmd.AddCommand(devOpenAI(&cfg.GPT))
+		rootCmd.AddCommand(devTg(&cfg.Telegram))
 	}
 
-	rootCmd.AddCommand(authorize(gmailConfig))
-	rootCmd.AddCommand(run(gmailConfig, gptConfig, tgConfig, appConfig))
-	rootCmd.AddCommand(setup(gmailConfig))
+	rootCmd.AddCommand(authorize(cfg))
+	rootCmd.AddCommand(run(cfg))
+	rootCmd.AddCommand(setup(cfg))
+	rootCmd.AddCommand(feedback(cfg))
+	rootCmd.AddCommand(dlq(cfg))
+	rootCmd.AddCommand(configCmd(cfg))
 	return rootCmd
 }
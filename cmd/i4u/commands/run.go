@@ -2,33 +2,47 @@ package commands
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/fadyat/i4u/api"
 	"github.com/fadyat/i4u/api/analyzer"
 	"github.com/fadyat/i4u/api/mail"
+	"github.com/fadyat/i4u/api/ratelimit"
 	"github.com/fadyat/i4u/api/sender"
 	"github.com/fadyat/i4u/api/summary"
 	"github.com/fadyat/i4u/cmd/i4u/token"
+	"github.com/fadyat/i4u/internal/alert"
+	"github.com/fadyat/i4u/internal/bot"
 	"github.com/fadyat/i4u/internal/config"
 	"github.com/fadyat/i4u/internal/entity"
 	"github.com/fadyat/i4u/internal/job"
+	"github.com/fadyat/i4u/internal/metrics"
+	"github.com/fadyat/i4u/internal/render"
+	"github.com/fadyat/i4u/internal/server"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/fadyat/i4u/internal/tracing"
 	"github.com/fadyat/i4u/pkg/syncs"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sashabaranov/go-openai"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"golang.org/x/oauth2"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 )
 
-func run(
-	gmailConfig *config.Gmail,
-	gptConfig *config.GPT,
-	tgConfig *config.Telegram,
-	appConfig *config.AppConfig,
-) *cobra.Command {
-	var oauth2Config = token.GetOAuthConfig(gmailConfig)
+func run(cfg *config.Config) *cobra.Command {
+	var (
+		gmailConfig    = &cfg.Gmail
+		gptConfig      = &cfg.GPT
+		tgConfig       = &cfg.Telegram
+		sendersConfig  = &cfg.Senders
+		pipelineConfig = &cfg.Pipeline
+		appConfig      = &cfg.App
+	)
 
 	return &cobra.Command{
 		Use:   "run",
@@ -41,64 +55,404 @@ Context is used to stop all jobs when signal is received.
 All messages started for processing will go through all stages of the pipeline.
 `,
 		Run: func(cmd *cobra.Command, _ []string) {
-			var staticToken, err = token.ReadTokenFromFile(gmailConfig.TokenFile)
-			if err != nil {
-				log.Fatal("unauthorized, run `i4u init` first")
+			if err := cfg.Validate(); err != nil {
+				log.Fatalf("invalid config:\n%s", err)
 			}
 
+			var oauth2Config = token.GetOAuthConfig(gmailConfig)
+
 			signalChan := make(chan os.Signal, 1)
 			signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 			defer close(signalChan)
 
-			var alertsNotifier api.Sender = sender.NewTg(
-				func() *tgbotapi.BotAPI {
-					bot, e := tgbotapi.NewBotAPI(tgConfig.Token)
-					if e != nil {
-						log.Fatal(e)
-					}
+			shutdownTracing, err := tracing.Setup(context.Background(), appConfig.TracingExporter, appConfig.Version)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer func() {
+				if e := shutdownTracing(context.Background()); e != nil {
+					zap.L().Error("failed to flush traces", zap.Error(e))
+				}
+			}()
 
-					return bot
-				}(),
-				tgConfig.AlertsChatID,
+			tgClient, err := tgbotapi.NewBotAPIWithClient(tgConfig.Token, tgbotapi.APIEndpoint, tracing.HTTPClient())
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			renderer := render.New(appConfig.TemplatesDir)
+			limits := ratelimit.NewRegistry(pipelineConfig.RateLimits)
+			alertsNotifier := newTgSender(limits, sender.NewTg(tgClient, tgConfig.AlertsChatID).WithRenderer(renderer), tgConfig.AlertsChatID)
+
+			// the analyzer and the summarizer are shared by the accounts,
+			// so the keywords, the prompts and the budgets are the same.
+			kwAnalyzer := analyzer.NewKWAnalyzer(appConfig.Keywords)
+			summarizer := ratelimit.NewSummarizer(
+				summary.NewOpenAI(newOpenAIClient(gptConfig), gptConfig),
+				limits.Limiter(config.RateOpenAIRequests),
+				limits.Limiter(config.RateOpenAITokens),
+				gptConfig,
 			)
 
-			producer := job.NewProducer(
-				mail.NewGmailClient(staticToken, oauth2Config, gmailConfig),
-				analyzer.NewKWAnalyzer(appConfig.Keywords),
-				summary.NewOpenAI(openai.NewClient(gptConfig.OpenAIKey), gptConfig),
-				sender.NewTg(
-					func() *tgbotapi.BotAPI {
-						bot, e := tgbotapi.NewBotAPI(tgConfig.Token)
-						if e != nil {
-							log.Fatal(e)
-						}
+			// every account has its own pipeline, the names are shown only,
+			// when several accounts are served, so the single account setup
+			// looks the same as before.
+			accounts := cfg.ListAccounts()
+			inboxes := make([]*inbox, 0, len(accounts))
+			for i := range accounts {
+				in, e := newInbox(&accounts[i], len(accounts) > 1, oauth2Config, gmailConfig, limits)
+				if e != nil {
+					log.Fatal(e)
+				}
+				defer in.close()
 
-						return bot
-					}(),
-					tgConfig.ChatID,
-				),
-				gmailConfig.L,
+				in.producer, e = job.NewProducer(
+					in.mail, kwAnalyzer, summarizer, in.router, in.account.Labels, in.state, in.store, pipelineConfig,
+				)
+				if e != nil {
+					log.Fatal(e)
+				}
+
+				in.routes = &routesBuilder{
+					tgClient: tgClient, tgConfig: tgConfig, sendersConfig: sendersConfig,
+					store: in.store, renderer: renderer, limits: limits,
+					journal: sender.NewJournal(in.store),
+					chatID:  in.account.ChatID, account: in.name,
+				}
+				inboxes = append(inboxes, in)
+			}
+
+			// the first reload configures the subscribers, the next ones
+			// are picked up by the running jobs, the messages in the queues
+			// are processed with the config, which is current at the time.
+			watcher := config.NewWatcher(config.File, func() (*config.Reloadable, error) {
+				c, e := config.Read(config.File)
+				if e != nil {
+					return nil, e
+				}
+
+				if e = c.Validate(); e != nil {
+					return nil, e
+				}
+
+				return config.NewReloadable(c), nil
+			}).Subscribe(
+				config.ApplyFeatures,
+				func(c *config.Reloadable) (func(), error) {
+					return func() { kwAnalyzer.SetKeywords(c.Keywords) }, nil
+				},
+				func(c *config.Reloadable) (func(), error) {
+					return func() { gptConfig.SetPrompts(c.Prompts) }, nil
+				},
 			)
+			for _, in := range inboxes {
+				watcher.Subscribe(in.routes.apply(in.router))
+			}
+
+			if err = watcher.Reload(); err != nil {
+				log.Fatal(err)
+			}
 
 			ctx, cancel := context.WithCancel(context.Background())
 
 			var wg syncs.WaitGroup
-			wg.Go(func() {
-				for e := range producer.Produce(ctx) {
-					zap.L().Error("got error during processing", zap.Error(e))
+			if appConfig.HTTPAddr != "" {
+				var (
+					stores = make(map[string]*storage.Storage, len(inboxes))
+					states = make(map[string]*job.State, len(inboxes))
+					checks = make([]server.Check, 0, 2*len(inboxes))
+				)
 
-					if er := alertsNotifier.Send(ctx, entity.NewAlertMsg(e)); er != nil {
-						zap.L().Error("failed to send alert", zap.Error(er))
-					}
+				for _, in := range inboxes {
+					stores[in.account.Name], states[in.name] = in.store, in.state
+					checks = append(checks,
+						server.Check{Name: in.checkName("token"), Fn: in.gmail.CheckToken},
+						server.Check{Name: in.checkName("gmail"), Fn: in.mail.Ping},
+					)
 				}
-			})
+
+				if e := metrics.RegisterQueues(stores, pipelineConfig.Topology.Names()...); e != nil {
+					log.Fatal(e)
+				}
+
+				srv := server.New(inboxes[0].state, pipelineConfig.FetchInterval, pipelineConfig.Timeouts.For(job.StageFetcher)).
+					WithAccounts(states).
+					WithChecks(checks...).
+					WithAdminToken(appConfig.AdminToken)
+				wg.Go(func() { srv.Run(ctx, appConfig.HTTPAddr) })
+			}
+
+			// some senders, like digest, are delivering messages in the background.
+			for _, in := range inboxes {
+				router := in.router
+				wg.Go(func() { router.Run(ctx) })
+			}
+			wg.Go(func() { watcher.Run(ctx) })
+
+			// the chats of the bot are taken at start, the reloaded
+			// routes to the new chats are served after the restart.
+			bots := make([]*bot.Bot, 0, len(inboxes))
+			for _, in := range inboxes {
+				bots = append(bots, bot.New(
+					tgClient, in.mail, in.store, in.account.Labels, in.state, in.router,
+					tgChats(in.account.ChatID, &watcher.Current().Routing)...,
+				).WithRenderer(renderer).WithLimits(limits).WithAccount(in.name))
+			}
+			dispatcher := bot.NewDispatcher(bots...)
+			wg.Go(func() { dispatcher.Run(ctx) })
+
+			alerter := alert.New(alertsNotifier, appConfig.AlertsWindow)
+			wg.Go(func() { alerter.Run(ctx) })
+			for _, in := range inboxes {
+				in := in
+				wg.Go(func() {
+					for e := range in.producer.Produce(ctx) {
+						if in.name != "" {
+							e = &job.AccountError{Account: in.name, Err: e}
+						}
+
+						zap.L().Error("got error during processing", zap.Error(e))
+						alerter.Alert(ctx, e)
+					}
+				})
+			}
 
 			<-signalChan
 			zap.L().Info("received signal, exiting")
 			cancel()
 
 			wg.Wait()
+			for _, in := range inboxes {
+				for name, stats := range in.router.Stats() {
+					if in.name != "" {
+						name = in.name + "/" + name
+					}
+
+					zap.S().Infof("route %s: sent %d, failed %d, skipped %d", name, stats.Sent, stats.Failed, stats.Skipped)
+				}
+			}
 			zap.L().Info("exiting")
 		},
 	}
 }
+
+// inbox is the pipeline of a single Gmail account, isolated from the
+// other accounts by its own storage, fetcher state and router.
+type inbox struct {
+	account *config.Account
+
+	// name marks the buttons, the alerts and the probes of the
+	// account, it's empty, when the only account is served.
+	name string
+
+	gmail    *mail.GmailClient
+	mail     *ratelimit.Mail
+	store    *storage.Storage
+	state    *job.State
+	router   *sender.Router
+	routes   *routesBuilder
+	producer job.Producer
+}
+
+// newInbox connects to the Gmail account and opens its storage, the
+// requests to Gmail are limited together with the other accounts.
+func newInbox(
+	account *config.Account,
+	named bool,
+	oauth2Config *oauth2.Config,
+	gmailConfig *config.Gmail,
+	limits *ratelimit.Registry,
+) (*inbox, error) {
+	in := &inbox{account: account}
+	auth := "`i4u auth`"
+	if named {
+		in.name = account.Name
+		auth = fmt.Sprintf("`i4u auth --account %s`", account.Name)
+	}
+
+	tokens, err := tokenStore(gmailConfig, account)
+	if err != nil {
+		return nil, err
+	}
+
+	staticToken, err := tokens.Load()
+	if err != nil {
+		return nil, fmt.Errorf("account %s is unauthorized, run %s first: %w", account.Name, auth, err)
+	}
+
+	if in.store, err = storage.Open(account.StoragePath); err != nil {
+		return nil, fmt.Errorf("account %s: %w", account.Name, err)
+	}
+
+	in.gmail = mail.NewGmailClient(staticToken, oauth2Config, account.Gmail(gmailConfig)).WithTokens(tokens)
+	// the quota is given per user, so each account has its own budget.
+	in.mail = ratelimit.NewMail(in.gmail, limits.Limiter(config.RateGmail+":"+account.Name))
+	in.state = job.NewState()
+	in.router = sender.NewRouter().WithDeliveries(in.store)
+	return in, nil
+}
+
+// checkName is the name of the readiness check of the account.
+func (in *inbox) checkName(check string) string {
+	if in.name == "" {
+		return check
+	}
+
+	return check + ":" + in.name
+}
+
+func (in *inbox) close() {
+	if e := in.store.Close(); e != nil {
+		zap.L().Error("failed to close storage", zap.String("account", in.account.Name), zap.Error(e))
+	}
+}
+
+// newOpenAIClient creates the client, which traces the requests to the API.
+func newOpenAIClient(gptConfig *config.GPT) *openai.Client {
+	cfg := openai.DefaultConfig(gptConfig.OpenAIKey)
+	cfg.HTTPClient = tracing.HTTPClient()
+	return openai.NewClientWithConfig(cfg)
+}
+
+// tgChats returns all chats, where summaries of the account are sent to.
+func tgChats(chatID int64, routing *config.Routing) []int64 {
+	chats := []int64{chatID}
+	for _, r := range routing.Routes {
+		if r.Sender == "tg" && r.ChatID != 0 {
+			chats = append(chats, r.ChatID)
+		}
+	}
+
+	return chats
+}
+
+// routesBuilder creates the routes of the router from the config, the
+// senders of the unchanged routes are reused on reload, so their state,
+// like the collected digest, is kept.
+type routesBuilder struct {
+	tgClient      *tgbotapi.BotAPI
+	tgConfig      *config.Telegram
+	sendersConfig *config.Senders
+	store         *storage.Storage
+	renderer      *render.Renderer
+	limits        *ratelimit.Registry
+
+	// journal saves every summary, it's used by the bot commands.
+	journal api.Sender
+	senders map[senderKey]api.Sender
+
+	// chatID is the default chat of the account, account marks
+	// the buttons of the summaries.
+	chatID  int64
+	account string
+}
+
+type senderKey struct {
+	route, sender string
+	chatID        int64
+}
+
+// apply prepares the routes from the config and replaces the routes of
+// the router with them.
+func (b *routesBuilder) apply(router *sender.Router) config.Applier {
+	return func(c *config.Reloadable) (func(), error) {
+		senders := make(map[senderKey]api.Sender, len(c.Routes))
+		routes := make([]sender.Route, 0, len(c.Routes)+1)
+		routes = append(routes, sender.Route{Name: "journal", Sender: b.journal})
+		for _, r := range c.Routes {
+			verdicts := make([]entity.Verdict, 0, len(r.Verdicts))
+			for _, v := range r.Verdicts {
+				verdict := entity.Verdict(v)
+				if !verdict.IsValid() {
+					return nil, fmt.Errorf("route %s: unknown verdict: %s", r.Name, v)
+				}
+
+				verdicts = append(verdicts, verdict)
+			}
+
+			key := senderKey{route: r.Name, sender: r.Sender, chatID: r.ChatID}
+			s, ok := b.senders[key]
+			if !ok {
+				var err error
+				if s, err = b.newSender(r); err != nil {
+					return nil, fmt.Errorf("route %s: %w", r.Name, err)
+				}
+			}
+
+			senders[key] = s
+			routes = append(routes, sender.Route{Name: r.Name, Sender: s, Verdicts: verdicts})
+		}
+
+		return func() {
+			b.senders = senders
+			router.SetRoutes(routes...)
+		}, nil
+	}
+}
+
+// newSender creates the destination for the summaries of the single route.
+func (b *routesBuilder) newSender(route config.Route) (api.Sender, error) {
+	switch route.Sender {
+	case "slack":
+		if !b.sendersConfig.Slack.IsEnabled() {
+			return nil, errors.New("neither slack webhook nor token is provided")
+		}
+
+		return sender.NewSlack(http.DefaultClient, b.sendersConfig.Slack).WithRenderer(b.renderer), nil
+	case "discord":
+		if b.sendersConfig.Discord.WebhookURL == "" {
+			return nil, errors.New("discord webhook is not provided")
+		}
+
+		return sender.NewDiscord(http.DefaultClient, b.sendersConfig.Discord.WebhookURL).WithRenderer(b.renderer), nil
+	case "webhook":
+		if b.sendersConfig.Webhook.URL == "" {
+			return nil, errors.New("webhook url is not provided")
+		}
+
+		return sender.NewWebhook(http.DefaultClient, b.sendersConfig.Webhook), nil
+	case "email", "digest":
+		smtp := b.sendersConfig.SMTP
+		if smtp.Host == "" || len(smtp.To) == 0 {
+			return nil, errors.New("smtp host or recipients are not provided")
+		}
+
+		mailer := sender.NewSMTP(smtp).WithRenderer(b.renderer)
+		if route.Sender == "email" {
+			return mailer, nil
+		}
+
+		at, err := smtp.DigestTime()
+		if err != nil {
+			return nil, err
+		}
+
+		return sender.NewDigest(mailer, b.store, route.Name, at), nil
+	case "tg":
+		chatID := b.chatID
+		if route.ChatID != 0 {
+			chatID = route.ChatID
+		}
+
+		switch b.tgConfig.CardMode {
+		case sender.CardModeEdit, sender.CardModeReply, sender.CardModeOff:
+		default:
+			return nil, fmt.Errorf("unknown telegram card mode: %s", b.tgConfig.CardMode)
+		}
+
+		tg := sender.NewTg(b.tgClient, chatID).WithRenderer(b.renderer).WithCards(b.store, b.tgConfig.CardMode).WithAccount(b.account)
+		return newTgSender(b.limits, tg, chatID), nil
+	}
+
+	return nil, fmt.Errorf("unknown sender: %s", route.Sender)
+}
+
+// newTgSender limits the messages to the chat, the bot-wide limit
+// is shared by all chats.
+func newTgSender(limits *ratelimit.Registry, tg api.Sender, chatID int64) api.Sender {
+	return ratelimit.NewSender(
+		tg,
+		limits.Limiter(config.RateTelegram),
+		limits.Limiter(fmt.Sprintf("%s:%d", config.RateTelegramChat, chatID)),
+	)
+}
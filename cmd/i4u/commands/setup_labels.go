@@ -13,21 +13,36 @@ import (
 	"sync"
 )
 
-func setup(gmailConfig *config.Gmail) *cobra.Command {
-	var oauth2Config = token.GetOAuthConfig(gmailConfig)
+func setup(cfg *config.Config) *cobra.Command {
+	var accountName string
 
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "setup",
 		Args:  cobra.NoArgs,
 		Short: "Setup labels in Gmail",
 		Long: fmt.Sprintf(`
 This command will setup labels in your Gmail account. It will create the following
 labels if they don't exist:
-%s`, gmailConfig.LabelsLst),
+%s
+
+When several accounts are configured, the account is chosen by --account,
+its labels are saved to the accounts section of the config.`, cfg.Gmail.LabelsLst),
 		Run: func(cmd *cobra.Command, _ []string) {
-			var staticToken, err = token.ReadTokenFromFile(gmailConfig.TokenFile)
+			account, err := cfg.Account(accountName)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var oauth2Config = token.GetOAuthConfig(&cfg.Gmail)
+
+			tokens, err := tokenStore(&cfg.Gmail, account)
 			if err != nil {
-				log.Fatal("unauthorized, run `i4u init` first")
+				log.Fatal(err)
+			}
+
+			staticToken, err := tokens.Load()
+			if err != nil {
+				log.Fatalf("unauthorized, run `i4u auth` first: %s", err)
 			}
 
 			var mu sync.Mutex
@@ -43,7 +58,8 @@ labels if they don't exist:
 				labels = make(map[string]string)
 			)
 
-			gmailClient := mail.NewGmailClient(staticToken, oauth2Config, gmailConfig)
+			gmailConfig := account.Gmail(&cfg.Gmail)
+			gmailClient := mail.NewGmailClient(staticToken, oauth2Config, gmailConfig).WithTokens(tokens)
 			for _, label := range gmailConfig.LabelsLst {
 				wg.Add(1)
 
@@ -70,9 +86,18 @@ labels if they don't exist:
 				log.Printf("failed to create label: %v", e)
 			}
 
-			if e := setupConfig.SaveLabelsToYaml(".i4u/config.yaml", labels); e != nil {
+			// the default account keeps the labels in the top-level section.
+			section := ""
+			if len(cfg.Accounts) > 0 {
+				section = account.Name
+			}
+
+			if e := setupConfig.SaveLabelsToYaml(config.File, section, labels); e != nil {
 				log.Printf("failed to save labels to config file: %v", e)
 			}
 		},
 	}
+
+	cmd.Flags().StringVar(&accountName, "account", "", "name of the account from the config")
+	return cmd
 }
@@ -1,13 +1,38 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
 )
 
-func SaveLabelsToYaml(filePath string, labels map[string]string) error {
+// SaveLabelsToYaml replaces labels in the config file, other
+// sections, like routes, are kept as is. The labels of the named
+// account are saved to its entry of the accounts section.
+func SaveLabelsToYaml(filePath, account string, labels map[string]string) error {
+	unitedLabels := map[string]any{}
+
+	content, err := os.ReadFile(filepath.Clean(filePath))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if e := yaml.Unmarshal(content, &unitedLabels); e != nil {
+		return fmt.Errorf("failed to parse file: %w", e)
+	}
+
+	if unitedLabels == nil {
+		unitedLabels = map[string]any{}
+	}
+
+	if account == "" {
+		unitedLabels["labels"] = labels
+	} else if e := setAccountLabels(unitedLabels, account, labels); e != nil {
+		return e
+	}
+
 	file, err := os.Create(filepath.Clean(filePath))
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
@@ -19,6 +44,17 @@ func SaveLabelsToYaml(filePath string, labels map[string]string) error {
 		}
 	}()
 
-	unitedLabels := map[string]any{"labels": labels}
 	return yaml.NewEncoder(file).Encode(unitedLabels)
 }
+
+func setAccountLabels(config map[string]any, account string, labels map[string]string) error {
+	accounts, _ := config["accounts"].([]any)
+	for _, a := range accounts {
+		if entry, ok := a.(map[string]any); ok && entry["name"] == account {
+			entry["labels"] = labels
+			return nil
+		}
+	}
+
+	return fmt.Errorf("account %s isn't found in the accounts section", account)
+}
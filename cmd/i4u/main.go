@@ -20,31 +20,14 @@ func init() {
 func main() {
 	defer func() { _ = zap.L().Sync() }()
 
-	gmailConfig, err := config.NewGmail()
+	cfg, err := config.Read(config.File)
 	if err != nil {
-		zap.L().Fatal("failed to initialize gmail config", zap.Error(err))
+		zap.L().Fatal("failed to read config", zap.Error(err))
 	}
 
-	gptConfig, err := config.NewGPT()
-	if err != nil {
-		zap.L().Fatal("failed to initialize gpt config", zap.Error(err))
-	}
-
-	tgConfig, err := config.NewTelegram()
-	if err != nil {
-		zap.L().Fatal("failed to initialize telegram config", zap.Error(err))
-	}
-
-	if err = config.NewFeatureFlags(); err != nil {
-		zap.L().Fatal("failed to initialize feature flags", zap.Error(err))
-	}
-
-	appConfig, err := config.NewAppConfig()
-	if err != nil {
-		zap.L().Fatal("failed to initialize app config", zap.Error(err))
-	}
+	config.InitFeatures(cfg.Features)
 
-	cmd := commands.Init(gmailConfig, gptConfig, tgConfig, appConfig)
+	cmd := commands.Init(cfg)
 	if e := cmd.Execute(); e != nil {
 		zap.L().Fatal("failed to execute command", zap.Error(e))
 	}
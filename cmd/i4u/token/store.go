@@ -0,0 +1,80 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/fadyat/i4u/internal/secret"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"os"
+	"path/filepath"
+)
+
+// Store keeps the token of the Gmail account in the file, the token is
+// encrypted, when the key is given. The plain tokens are still read,
+// so the existing ones are encrypted on the next save.
+type Store struct {
+	path string
+	key  *secret.Key
+}
+
+func NewStore(path string, key *secret.Key) *Store {
+	return &Store{path: path, key: key}
+}
+
+func (s *Store) Load() (*oauth2.Token, error) {
+	content, err := os.ReadFile(filepath.Clean(s.path))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case secret.IsSealed(content) && s.key == nil:
+		return nil, errors.New("token is encrypted, gmail.token_passphrase or gmail.token_key_file is required")
+	case secret.IsSealed(content):
+		if content, err = s.key.Open(content); err != nil {
+			return nil, err
+		}
+	case s.key != nil:
+		zap.S().Warnf("token %s isn't encrypted yet, it will be on the next refresh or `i4u auth`", s.path)
+	}
+
+	var token oauth2.Token
+	if e := json.Unmarshal(content, &token); e != nil {
+		return nil, e
+	}
+
+	return &token, nil
+}
+
+// Save replaces the token atomically, so the token isn't lost, when
+// the application is stopped in the middle of the refresh.
+func (s *Store) Save(token *oauth2.Token) error {
+	content, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	if s.key != nil {
+		if content, err = s.key.Seal(content); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err = tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,39 @@
+package token
+
+import (
+	"github.com/fadyat/i4u/internal/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	key, err := secret.NewKey([]byte("passphrase"))
+	require.NoError(t, err)
+
+	// the plain token is read with the key, and encrypted on save.
+	require.NoError(t, NewStore(path, nil).Save(&oauth2.Token{AccessToken: "plain"}))
+	tok, err := NewStore(path, key).Load()
+	require.NoError(t, err)
+	assert.Equal(t, "plain", tok.AccessToken)
+
+	require.NoError(t, NewStore(path, key).Save(&oauth2.Token{AccessToken: "sealed"}))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, secret.IsSealed(content))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	tok, err = NewStore(path, key).Load()
+	require.NoError(t, err)
+	assert.Equal(t, "sealed", tok.AccessToken)
+
+	_, err = NewStore(path, nil).Load()
+	assert.EqualError(t, err, "token is encrypted, gmail.token_passphrase or gmail.token_key_file is required")
+}
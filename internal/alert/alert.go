@@ -0,0 +1,178 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/api"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/job"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flushTimeout limits sending the grouped alerts after the shutdown.
+const flushTimeout = 5 * time.Second
+
+// actions describe the failure of the stage in the grouped alerts.
+var actions = map[string]string{
+	job.StageFetcher:    "failed to fetch",
+	job.StageAnalyzer:   "failed to analyze",
+	job.StageSummarizer: "failed to summarize",
+	job.StageLabeler:    "failed to label",
+	job.StageSender:     "failed to send",
+}
+
+// Alerter sends the errors of the pipeline to the alerts chat.
+//
+// The same failures are grouped within the window: the first one is sent
+// right away with all details, the rest are only counted and reported with
+// a single line, like "12× failed to summarize (OpenAI 429) in last 5m",
+// when the window is over. Zero window sends every error as is.
+type Alerter struct {
+	sender api.Sender
+	window time.Duration
+
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+type group struct {
+	title string
+	count int
+}
+
+func New(sender api.Sender, window time.Duration) *Alerter {
+	return &Alerter{
+		sender: sender,
+		window: window,
+		groups: make(map[string]*group),
+	}
+}
+
+// Alert sends the error, unless the same one was already sent in the window.
+func (a *Alerter) Alert(ctx context.Context, err error) {
+	if a.window <= 0 {
+		a.send(ctx, err)
+		return
+	}
+
+	key := describe(err)
+
+	a.mu.Lock()
+	g, ok := a.groups[key]
+	if ok {
+		g.count++
+		a.mu.Unlock()
+		return
+	}
+
+	a.groups[key] = &group{title: key, count: 1}
+	a.mu.Unlock()
+
+	a.send(ctx, err)
+}
+
+// Run reports the grouped errors at the end of every window, until the
+// context is done, the last window is reported after that.
+func (a *Alerter) Run(ctx context.Context) {
+	if a.window <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(a.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.flush(ctx)
+		case <-ctx.Done():
+			timeout, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			a.flush(timeout)
+			cancel()
+			return
+		}
+	}
+}
+
+// flush starts the new window, the failures, which happened only
+// once, were already sent and aren't reported again.
+func (a *Alerter) flush(ctx context.Context) {
+	a.mu.Lock()
+	groups := a.groups
+	a.groups = make(map[string]*group)
+	a.mu.Unlock()
+
+	keys := make([]string, 0, len(groups))
+	for key, g := range groups {
+		if g.count > 1 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		g := groups[key]
+		a.send(ctx, fmt.Errorf("%d× %s in last %s", g.count, g.title, formatWindow(a.window)))
+	}
+}
+
+func (a *Alerter) send(ctx context.Context, err error) {
+	if e := a.sender.Send(ctx, entity.NewAlertMsg(err)); e != nil {
+		zap.L().Error("failed to send alert", zap.Error(e))
+	}
+}
+
+// describe returns the title of the group, the error belongs to; errors
+// of the pipeline are grouped by the stage, provider and cause, so failures
+// of the different messages end up in the same group, but the failures of
+// the different accounts don't.
+func describe(err error) string {
+	var ae *job.AccountError
+	if errors.As(err, &ae) {
+		return ae.Account + ": " + describe(ae.Err)
+	}
+
+	var pe *job.PipelineError
+	if !errors.As(err, &pe) {
+		return err.Error()
+	}
+
+	title, ok := actions[pe.Stage]
+	if !ok {
+		title = "failed at " + pe.Stage
+	}
+
+	var details []string
+	if pe.Provider != "" {
+		details = append(details, pe.Provider)
+	}
+
+	if cause := pe.Cause(); cause != "other" {
+		details = append(details, cause)
+	}
+
+	if len(details) > 0 {
+		title += " (" + strings.Join(details, " ") + ")"
+	}
+
+	return title
+}
+
+// formatWindow drops the zero units of the duration, like 5m0s.
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+
+	return s
+}
@@ -0,0 +1,108 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/job"
+	"github.com/fadyat/i4u/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type statusErr int
+
+func (e statusErr) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusErr) StatusCode() int { return int(e) }
+
+func TestAlerter_Dedup(t *testing.T) {
+	var sent []string
+	s := mocks.NewSender(t)
+	s.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = append(sent, args.Get(1).(entity.SummaryMessage).Summary())
+	}).Return(nil)
+
+	a := New(s, 5*time.Minute)
+	for i := 0; i < 12; i++ {
+		a.Alert(context.Background(), &job.PipelineError{
+			Stage:    job.StageSummarizer,
+			MsgID:    fmt.Sprint(i),
+			Provider: job.ProviderOpenAI,
+			Err:      fmt.Errorf("message %d failed: %w", i, statusErr(429)),
+		})
+	}
+	a.Alert(context.Background(), errors.New("failed to read queue"))
+
+	// the first errors of the groups are sent right away.
+	assert.Equal(t, []string{"message 0 failed: status 429", "failed to read queue"}, sent)
+
+	a.flush(context.Background())
+	assert.Equal(t, "12× failed to summarize (OpenAI 429) in last 5m", sent[2])
+	assert.Len(t, sent, 3)
+
+	// the new window starts from scratch.
+	a.Alert(context.Background(), errors.New("failed to read queue"))
+	assert.Len(t, sent, 4)
+}
+
+func TestAlerter_NoWindow(t *testing.T) {
+	s := mocks.NewSender(t)
+	s.On("Send", mock.Anything, mock.Anything).Return(nil).Times(3)
+
+	a := New(s, 0)
+	for i := 0; i < 3; i++ {
+		a.Alert(context.Background(), errors.New("failed to read queue"))
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "plain error",
+			err:      errors.New("kek"),
+			expected: "kek",
+		},
+		{
+			name:     "provider with status",
+			err:      &job.PipelineError{Stage: job.StageFetcher, Provider: job.ProviderGmail, Err: statusErr(503)},
+			expected: "failed to fetch (Gmail 503)",
+		},
+		{
+			name:     "timeout",
+			err:      &job.PipelineError{Stage: job.StageSender, Err: context.DeadlineExceeded},
+			expected: "failed to send (timeout)",
+		},
+		{
+			name:     "internal",
+			err:      &job.PipelineError{Stage: job.StageLabeler, Err: errors.New("bolt")},
+			expected: "failed to label",
+		},
+		{
+			name:     "wrapped",
+			err:      fmt.Errorf("producer: %w", &job.PipelineError{Stage: "custom", Err: errors.New("bolt")}),
+			expected: "failed at custom",
+		},
+		{
+			name:     "account",
+			err:      &job.AccountError{Account: "alice", Err: &job.PipelineError{Stage: job.StageFetcher, Provider: job.ProviderGmail, Err: statusErr(401)}},
+			expected: "alice: failed to fetch (Gmail 401)",
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, describe(tc.err))
+		})
+	}
+}
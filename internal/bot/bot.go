@@ -0,0 +1,272 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/api"
+	"github.com/fadyat/i4u/api/ratelimit"
+	"github.com/fadyat/i4u/api/sender"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/job"
+	"github.com/fadyat/i4u/internal/render"
+	"github.com/fadyat/i4u/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// snoozeFor is a delay before reminding about the snoozed summary.
+	snoozeFor = 24 * time.Hour
+
+	// remindEvery is a period of checking snoozed summaries.
+	remindEvery = time.Minute
+
+	// handleTimeout is a time given for handling a single update.
+	handleTimeout = 5 * time.Second
+)
+
+// inboxLabel is a system Gmail label, removing it archives the message.
+const inboxLabel = "INBOX"
+
+// client is a part of the Telegram API used by the bot.
+type client interface {
+	Request(tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	Send(tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// Bot handles user interactions with the messages sent by sender.Tg,
+// applying the actions via mail provider and recording them as feedback.
+// Also, it answers commands about the applications and the pipeline.
+type Bot struct {
+	api    *tgbotapi.BotAPI
+	c      client
+	mail   api.Mail
+	store  *storage.Storage
+	labels *config.LabelsMapper
+	state  *job.State
+	router *sender.Router
+	r      *render.Renderer
+	limits *ratelimit.Registry
+
+	// chats is a set of chats, which are allowed to interact with the bot.
+	chats map[int64]bool
+	now   func() time.Time
+
+	// account is the Gmail account served by the bot, it's empty,
+	// when the only account is served.
+	account string
+}
+
+func New(
+	botAPI *tgbotapi.BotAPI,
+	mail api.Mail,
+	store *storage.Storage,
+	labels *config.LabelsMapper,
+	state *job.State,
+	router *sender.Router,
+	chatIDs ...int64,
+) *Bot {
+	chats := make(map[int64]bool, len(chatIDs))
+	for _, id := range chatIDs {
+		chats[id] = true
+	}
+
+	return &Bot{
+		api:    botAPI,
+		c:      botAPI,
+		mail:   mail,
+		store:  store,
+		labels: labels,
+		state:  state,
+		router: router,
+		r:      render.Default,
+		chats:  chats,
+		now:    time.Now,
+	}
+}
+
+// WithRenderer replaces the built-in templates of the reminders.
+func (b *Bot) WithRenderer(r *render.Renderer) *Bot {
+	b.r = r
+	return b
+}
+
+// WithAccount serves the buttons of the account and marks the replies
+// to the commands with it, see Dispatcher.
+func (b *Bot) WithAccount(name string) *Bot {
+	b.account = name
+	return b
+}
+
+// WithLimits shows the remaining budgets of the providers in /status.
+func (b *Bot) WithLimits(limits *ratelimit.Registry) *Bot {
+	b.limits = limits
+	return b
+}
+
+// Run receives updates via long polling until the context is done.
+func (b *Bot) Run(ctx context.Context) {
+	NewDispatcher(b).Run(ctx)
+}
+
+func (b *Bot) isAllowed(chatID int64) bool {
+	return b.chats[chatID]
+}
+
+// handleCallback applies the action chosen via inline keyboard, the
+// result is shown to the user as a short notification.
+func (b *Bot) handleCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	if q.Message == nil || !b.isAllowed(q.Message.Chat.ID) {
+		b.answer(q, "🚫 You are not allowed to do this")
+		return
+	}
+
+	c, ok := sender.ParseCallback(q.Data)
+	if !ok || (c.Account != "" && c.Account != b.account) {
+		b.answer(q, "🤔 Unknown action")
+		return
+	}
+
+	reply, err := b.apply(ctx, q, c)
+	if err != nil {
+		zap.L().Error("failed to apply action", zap.String("action", string(c.Action)), zap.Error(err))
+		b.answer(q, "❌ Failed, try again later")
+		return
+	}
+
+	b.answer(q, reply)
+}
+
+func (b *Bot) apply(ctx context.Context, q *tgbotapi.CallbackQuery, c sender.Callback) (string, error) {
+	chatID, chatMsgID := q.Message.Chat.ID, q.Message.MessageID
+	link := sender.LinkFromKeyboard(q.Message.ReplyMarkup)
+
+	switch c.Action {
+	case sender.ActionNotInternship:
+		if err := b.mail.ModifyLabels(
+			ctx, c.MessageID, []string{b.labels.NotIntern}, []string{b.labels.IsIntern},
+		); err != nil {
+			return "", fmt.Errorf("failed to relabel message: %w", err)
+		}
+
+		return "👌 Marked as not an internship", b.feedback(q, c)
+	case sender.ActionChangeVerdict:
+		return "", b.editKeyboard(chatID, chatMsgID, sender.VerdictsKeyboard(c.Account, c.MessageID, link))
+	case sender.ActionBack:
+		return "", b.editKeyboard(chatID, chatMsgID, sender.ActionsKeyboard(c.Account, c.MessageID, link))
+	case sender.ActionSetVerdict:
+		if err := b.feedback(q, c); err != nil {
+			return "", err
+		}
+
+		if err := b.store.SetApplicationStage(c.MessageID, c.Verdict); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return "", fmt.Errorf("failed to update application: %w", err)
+		}
+
+		return "👌 Verdict changed to " + string(c.Verdict),
+			b.editKeyboard(chatID, chatMsgID, sender.ActionsKeyboard(c.Account, c.MessageID, link))
+	case sender.ActionSnooze:
+		if err := b.store.SaveSnooze(&storage.Snooze{
+			ChatID:        chatID,
+			ChatMessageID: chatMsgID,
+			MessageID:     c.MessageID,
+			Until:         b.now().Add(snoozeFor),
+		}); err != nil {
+			return "", fmt.Errorf("failed to save snooze: %w", err)
+		}
+
+		return "😴 Will remind you tomorrow", b.feedback(q, c)
+	case sender.ActionReplied:
+		return "👌 Marked as replied", b.feedback(q, c)
+	case sender.ActionArchive:
+		if err := b.mail.ModifyLabels(ctx, c.MessageID, nil, []string{inboxLabel}); err != nil {
+			return "", fmt.Errorf("failed to archive message: %w", err)
+		}
+
+		return "🗄 Archived in Gmail", b.feedback(q, c)
+	}
+
+	return "🤔 Unknown action", nil
+}
+
+// feedback records the action, so it can be used for
+// improving the analyzer and the summarizer later.
+func (b *Bot) feedback(q *tgbotapi.CallbackQuery, c sender.Callback) error {
+	f := &storage.Feedback{
+		MessageID: c.MessageID,
+		Action:    actionNames[c.Action],
+		Verdict:   string(c.Verdict),
+		Summary:   q.Message.Text,
+		At:        b.now(),
+	}
+
+	if q.From != nil {
+		f.UserID = q.From.ID
+	}
+
+	if err := b.store.SaveFeedback(f); err != nil {
+		return fmt.Errorf("failed to save feedback: %w", err)
+	}
+
+	return nil
+}
+
+var actionNames = map[sender.Action]string{
+	sender.ActionNotInternship: "not_internship",
+	sender.ActionSetVerdict:    "change_verdict",
+	sender.ActionSnooze:        "snooze",
+	sender.ActionReplied:       "replied",
+	sender.ActionArchive:       "archive",
+}
+
+func (b *Bot) editKeyboard(chatID int64, chatMsgID int, kb tgbotapi.InlineKeyboardMarkup) error {
+	_, err := b.c.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, chatMsgID, kb))
+	return err
+}
+
+func (b *Bot) reply(chatID int64, text string) {
+	if _, err := b.c.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		zap.L().Warn("failed to reply", zap.Error(err))
+	}
+}
+
+func (b *Bot) answer(q *tgbotapi.CallbackQuery, text string) {
+	if _, err := b.c.Request(tgbotapi.NewCallback(q.ID, text)); err != nil {
+		zap.L().Warn("failed to answer callback", zap.Error(err))
+	}
+}
+
+// remindSnoozed replies to the snoozed summaries, which time has come.
+func (b *Bot) remindSnoozed() {
+	due, err := b.store.DueSnoozes(b.now())
+	if err != nil {
+		zap.L().Error("failed to get snoozed messages", zap.Error(err))
+		return
+	}
+
+	for i := range due {
+		sn := &due[i]
+
+		text, err := b.r.Render("tg", render.KindReminder, render.FormatHTML, render.Message{
+			ID: sn.MessageID, At: sn.Until,
+		})
+		if err != nil {
+			zap.L().Error("failed to render reminder", zap.Error(err))
+			continue
+		}
+
+		reminder := tgbotapi.NewMessage(sn.ChatID, text)
+		reminder.ParseMode = tgbotapi.ModeHTML
+		reminder.ReplyToMessageID = sn.ChatMessageID
+		if _, e := b.c.Send(reminder); e != nil {
+			zap.L().Error("failed to send reminder", zap.Error(e))
+			continue
+		}
+
+		if e := b.store.DeleteSnooze(sn); e != nil {
+			zap.L().Error("failed to delete snooze", zap.Error(e))
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"context"
+	"github.com/fadyat/i4u/api/sender"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/job"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/fadyat/i4u/mocks"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testChatID = 42
+
+// fakeClient records all requests made to the Telegram API.
+type fakeClient struct {
+	mu   sync.Mutex
+	sent []tgbotapi.Chattable
+}
+
+func (f *fakeClient) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (f *fakeClient) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	_, err := f.Request(c)
+	return tgbotapi.Message{}, err
+}
+
+func newTestBot(t *testing.T) (*Bot, *mocks.Mail, *fakeClient) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "i4u.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	mail, c := mocks.NewMail(t), &fakeClient{}
+	b := New(nil, mail, store, &config.LabelsMapper{
+		I4U: "i4u", IsIntern: "is_intern", NotIntern: "not_intern",
+	}, job.NewState(), nil, testChatID)
+	b.c = c
+	b.now = func() time.Time { return time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC) }
+
+	return b, mail, c
+}
+
+func newCallbackQuery(chatID int64, c sender.Callback) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:   "q",
+		From: &tgbotapi.User{ID: 7},
+		Message: &tgbotapi.Message{
+			MessageID: 100,
+			Chat:      &tgbotapi.Chat{ID: chatID},
+			Text:      "Company: Acme",
+		},
+		Data: c.String(),
+	}
+}
+
+func lastAnswer(t *testing.T, c *fakeClient) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	require.NotEmpty(t, c.sent)
+	answer, ok := c.sent[len(c.sent)-1].(tgbotapi.CallbackConfig)
+	require.True(t, ok)
+
+	return answer.Text
+}
+
+func TestBot_handleCallback(t *testing.T) {
+	testCases := []struct {
+		name             string
+		chatID           int64
+		callback         sender.Callback
+		pre              func(m *mocks.Mail)
+		expectedAnswer   string
+		expectedFeedback []storage.Feedback
+	}{
+		{
+			name:           "not allowed chat",
+			chatID:         1,
+			callback:       sender.Callback{Action: sender.ActionArchive, MessageID: "0"},
+			pre:            func(m *mocks.Mail) {},
+			expectedAnswer: "🚫 You are not allowed to do this",
+		},
+		{
+			name:     "not an internship",
+			chatID:   testChatID,
+			callback: sender.Callback{Action: sender.ActionNotInternship, MessageID: "0"},
+			pre: func(m *mocks.Mail) {
+				m.On("ModifyLabels", context.Background(), "0", []string{"not_intern"}, []string{"is_intern"}).
+					Return(nil)
+			},
+			expectedAnswer: "👌 Marked as not an internship",
+			expectedFeedback: []storage.Feedback{
+				{MessageID: "0", Action: "not_internship", Summary: "Company: Acme", UserID: 7},
+			},
+		},
+		{
+			name:     "archive",
+			chatID:   testChatID,
+			callback: sender.Callback{Action: sender.ActionArchive, MessageID: "0"},
+			pre: func(m *mocks.Mail) {
+				m.On("ModifyLabels", context.Background(), "0", []string(nil), []string{"INBOX"}).
+					Return(nil)
+			},
+			expectedAnswer: "🗄 Archived in Gmail",
+			expectedFeedback: []storage.Feedback{
+				{MessageID: "0", Action: "archive", Summary: "Company: Acme", UserID: 7},
+			},
+		},
+		{
+			name:   "change verdict",
+			chatID: testChatID,
+			callback: sender.Callback{
+				Action: sender.ActionSetVerdict, MessageID: "0", Verdict: entity.VerdictOffer,
+			},
+			pre:            func(m *mocks.Mail) {},
+			expectedAnswer: "👌 Verdict changed to offer",
+			expectedFeedback: []storage.Feedback{
+				{MessageID: "0", Action: "change_verdict", Verdict: "offer", Summary: "Company: Acme", UserID: 7},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, mail, c := newTestBot(t)
+			tc.pre(mail)
+
+			b.handleCallback(context.Background(), newCallbackQuery(tc.chatID, tc.callback))
+			assert.Equal(t, tc.expectedAnswer, lastAnswer(t, c))
+
+			feedback, err := b.store.Feedback()
+			require.NoError(t, err)
+			for i := range feedback {
+				feedback[i].At = time.Time{}
+			}
+
+			assert.Equal(t, tc.expectedFeedback, feedback)
+		})
+	}
+}
+
+func TestBot_remindSnoozed(t *testing.T) {
+	b, _, c := newTestBot(t)
+
+	b.handleCallback(context.Background(), newCallbackQuery(
+		testChatID, sender.Callback{Action: sender.ActionSnooze, MessageID: "0"},
+	))
+	assert.Equal(t, "😴 Will remind you tomorrow", lastAnswer(t, c))
+
+	// too early for the reminder.
+	b.remindSnoozed()
+	assert.Len(t, c.sent, 1)
+
+	b.now = func() time.Time { return time.Date(2023, 9, 2, 12, 0, 0, 0, time.UTC) }
+	b.remindSnoozed()
+	require.Len(t, c.sent, 2)
+
+	reminder := c.sent[1].(tgbotapi.MessageConfig)
+	assert.Equal(t, 100, reminder.ReplyToMessageID)
+
+	// reminder is sent only once.
+	b.remindSnoozed()
+	assert.Len(t, c.sent, 2)
+}
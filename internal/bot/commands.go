@@ -0,0 +1,274 @@
+package bot
+
+import (
+	"fmt"
+	"github.com/fadyat/i4u/api/ratelimit"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// searchLimit is a maximum number of applications shown by /search.
+	searchLimit = 10
+
+	// statsPeriod is a period covered by /stats.
+	statsPeriod = 7 * 24 * time.Hour
+)
+
+const helpText = `Available commands:
+/status — pipeline health and last fetch time
+/apps — open applications by stage
+/search <text> — find applications
+/stats — weekly counts by verdict
+/pause — stop fetching new messages
+/resume — continue fetching`
+
+// commands are shown in the Telegram menu.
+var commands = []tgbotapi.BotCommand{
+	{Command: "status", Description: "Pipeline health and last fetch time"},
+	{Command: "apps", Description: "Open applications by stage"},
+	{Command: "search", Description: "Find applications"},
+	{Command: "stats", Description: "Weekly counts by verdict"},
+	{Command: "pause", Description: "Stop fetching new messages"},
+	{Command: "resume", Description: "Continue fetching"},
+}
+
+// handleCommand answers the command in the same chat, commands from
+// unknown chats are ignored, to avoid leaking the data.
+func (b *Bot) handleCommand(m *tgbotapi.Message) {
+	if m.Chat == nil || !b.isAllowed(m.Chat.ID) {
+		return
+	}
+
+	b.reply(m.Chat.ID, b.command(m))
+}
+
+// command executes the command and returns the reply, the replies of
+// the accounts are titled with their names.
+func (b *Bot) command(m *tgbotapi.Message) string {
+	var (
+		text string
+		err  error
+	)
+
+	switch m.Command() {
+	case "status":
+		text = b.status()
+	case "apps":
+		text, err = b.apps()
+	case "search":
+		text, err = b.search(m.CommandArguments())
+	case "stats":
+		text, err = b.stats()
+	case "pause":
+		b.state.Pause()
+		text = "⏸ Fetching is paused, /resume to continue"
+	case "resume":
+		b.state.Resume()
+		text = "▶️ Fetching is resumed"
+	default:
+		return helpText
+	}
+
+	if err != nil {
+		text = "❌ Failed: " + err.Error()
+	}
+
+	if b.account != "" {
+		text = "📮 " + b.account + "\n" + text
+	}
+
+	return text
+}
+
+// isCommand tells, whether the command is known, unknown ones are
+// answered with the help.
+func isCommand(name string) bool {
+	for _, c := range commands {
+		if c.Command == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Bot) status() string {
+	s := b.state.Status()
+
+	var sb strings.Builder
+	if s.Paused {
+		sb.WriteString("⏸ Paused\n")
+	} else {
+		sb.WriteString("▶️ Running\n")
+	}
+
+	fmt.Fprintf(&sb, "Uptime: %s\n", b.now().Sub(s.StartedAt).Round(time.Second))
+	if s.LastFetch.IsZero() {
+		sb.WriteString("Last fetch: never\n")
+	} else {
+		fmt.Fprintf(&sb, "Last fetch: %s (%s ago)\n",
+			s.LastFetch.Format(time.DateTime), b.now().Sub(s.LastFetch).Round(time.Second))
+	}
+
+	if s.LastError != "" {
+		fmt.Fprintf(&sb, "Last error: %s\n", s.LastError)
+	}
+
+	if b.router != nil {
+		stats := b.router.Stats()
+		names := make([]string, 0, len(stats))
+		for name := range stats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		sb.WriteString("Routes:\n")
+		for _, name := range names {
+			st := stats[name]
+			fmt.Fprintf(&sb, "• %s: sent %d, failed %d, skipped %d\n", name, st.Sent, st.Failed, st.Skipped)
+		}
+	}
+
+	if budgets := b.budgets(); len(budgets) > 0 {
+		sb.WriteString("Rate limits:\n")
+		for _, budget := range budgets {
+			fmt.Fprintf(&sb, "• %s: %.0f/%.0f", budget.Name, max(budget.Remaining, 0), budget.Burst)
+			if !budget.BlockedUntil.IsZero() {
+				fmt.Fprintf(&sb, ", blocked for %s", budget.BlockedUntil.Sub(b.now()).Round(time.Second))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+func (b *Bot) budgets() []ratelimit.Budget {
+	if b.limits == nil {
+		return nil
+	}
+
+	return b.limits.Budgets()
+}
+
+func (b *Bot) apps() (string, error) {
+	apps, err := b.store.Applications()
+	if err != nil {
+		return "", err
+	}
+
+	byStage := make(map[entity.Verdict][]storage.Application)
+	for _, app := range apps {
+		if app.IsOpen() {
+			byStage[app.Stage] = append(byStage[app.Stage], app)
+		}
+	}
+
+	var sb strings.Builder
+	for _, stage := range entity.Verdicts {
+		group := byStage[stage]
+		if len(group) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "%s (%d)\n", stage, len(group))
+		for i := range group {
+			fmt.Fprintf(&sb, "• %s\n", appTitle(&group[i]))
+		}
+		sb.WriteString("\n")
+	}
+
+	if sb.Len() == 0 {
+		return "No open applications", nil
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+func (b *Bot) search(query string) (string, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return "Usage: /search <text>", nil
+	}
+
+	apps, err := b.store.Applications()
+	if err != nil {
+		return "", err
+	}
+
+	var found []storage.Application
+	for _, app := range apps {
+		haystack := strings.ToLower(app.Company + " " + app.Vacancy + " " + app.LastUpdate().Summary)
+		if strings.Contains(haystack, query) {
+			found = append(found, app)
+		}
+	}
+
+	if len(found) == 0 {
+		return "Nothing found", nil
+	}
+
+	// the most recent applications first.
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].LastUpdate().At.After(found[j].LastUpdate().At)
+	})
+
+	var sb strings.Builder
+	for i := range found {
+		if i == searchLimit {
+			fmt.Fprintf(&sb, "…and %d more", len(found)-searchLimit)
+			break
+		}
+
+		last := found[i].LastUpdate()
+		fmt.Fprintf(&sb, "• %s — %s (%s)\n", appTitle(&found[i]), found[i].Stage, last.At.Format("Jan 2"))
+		if last.Link != "" {
+			fmt.Fprintf(&sb, "  %s\n", last.Link)
+		}
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+func (b *Bot) stats() (string, error) {
+	apps, err := b.store.Applications()
+	if err != nil {
+		return "", err
+	}
+
+	since := b.now().Add(-statsPeriod)
+	counts := make(map[entity.Verdict]int)
+	for _, app := range apps {
+		for _, u := range app.Updates {
+			if u.At.After(since) {
+				counts[u.Verdict]++
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Last 7 days:\n")
+	for _, v := range entity.Verdicts {
+		fmt.Fprintf(&sb, "• %s: %d\n", v, counts[v])
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+func appTitle(app *storage.Application) string {
+	title := app.Company
+	if title == "" {
+		title = "Unknown company"
+	}
+
+	if app.Vacancy != "" {
+		title += ", " + app.Vacancy
+	}
+
+	return title
+}
@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"github.com/fadyat/i4u/api/ratelimit"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCommand(chatID int64, text string) *tgbotapi.Message {
+	cmd, _, _ := strings.Cut(text, " ")
+
+	return &tgbotapi.Message{
+		Chat: &tgbotapi.Chat{ID: chatID},
+		Text: text,
+		Entities: []tgbotapi.MessageEntity{
+			{Type: "bot_command", Offset: 0, Length: len(cmd)},
+		},
+	}
+}
+
+func lastReply(t *testing.T, c *fakeClient) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	require.NotEmpty(t, c.sent)
+	reply, ok := c.sent[len(c.sent)-1].(tgbotapi.MessageConfig)
+	require.True(t, ok)
+
+	return reply.Text
+}
+
+func TestBot_handleCommand(t *testing.T) {
+	b, _, c := newTestBot(t)
+
+	updates := []struct {
+		company, vacancy string
+		update           storage.ApplicationUpdate
+	}{
+		{"Acme", "Intern", storage.ApplicationUpdate{MessageID: "1", ThreadID: "t1", Verdict: entity.VerdictInterview, At: b.now().Add(-time.Hour)}},
+		{"Globex", "", storage.ApplicationUpdate{MessageID: "2", Verdict: entity.VerdictReject, At: b.now().Add(-time.Hour)}},
+		{"acme", "", storage.ApplicationUpdate{MessageID: "3", ThreadID: "t1", Verdict: entity.VerdictOffer, At: b.now()}},
+		{"Initech", "", storage.ApplicationUpdate{MessageID: "4", Verdict: entity.VerdictTestTask, At: b.now().Add(-30 * 24 * time.Hour)}},
+	}
+	for i := range updates {
+		u := updates[i]
+		_, err := b.store.SaveApplicationUpdate(u.company, u.vacancy, &u.update)
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		command  string
+		expected string
+	}{
+		{
+			command:  "/apps",
+			expected: "offer (1)\n• Acme, Intern\n\ntest_task (1)\n• Initech",
+		},
+		{
+			command:  "/search acme",
+			expected: "• Acme, Intern — offer (Sep 1)",
+		},
+		{
+			command:  "/search",
+			expected: "Usage: /search <text>",
+		},
+		{
+			command:  "/stats",
+			expected: "Last 7 days:\n• offer: 1\n• interview: 1\n• test_task: 0\n• reject: 1\n• unknown: 0",
+		},
+		{
+			command:  "/pause",
+			expected: "⏸ Fetching is paused, /resume to continue",
+		},
+		{
+			command:  "/start",
+			expected: helpText,
+		},
+	}
+
+	for _, tc := range testCases {
+		b.handleCommand(newCommand(testChatID, tc.command))
+		assert.Equal(t, tc.expected, lastReply(t, c), tc.command)
+	}
+
+	assert.True(t, b.state.IsPaused())
+	assert.Contains(t, b.status(), "⏸ Paused")
+
+	limits := ratelimit.NewRegistry(config.RateLimits{})
+	limits.Limiter(config.RateGmail).Take(50)
+	b.WithLimits(limits)
+	assert.Contains(t, b.status(), "Rate limits:\n• gmail: 200/250")
+
+	b.handleCommand(newCommand(testChatID, "/resume"))
+	assert.False(t, b.state.IsPaused())
+
+	// unknown chats are ignored.
+	sent := len(c.sent)
+	b.handleCommand(newCommand(1, "/pause"))
+	assert.Len(t, c.sent, sent)
+	assert.False(t, b.state.IsPaused())
+}
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"context"
+	"github.com/fadyat/i4u/api/sender"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// Dispatcher receives the updates of the Telegram bot once and passes
+// them to the bots of the Gmail accounts, sharing the same bot token.
+//
+// The buttons are handled by the bot of their account, the old buttons
+// without the account, by the first bot of the chat. The commands are
+// answered by all bots of the chat in a single reply.
+type Dispatcher struct {
+	bots []*Bot
+}
+
+func NewDispatcher(bots ...*Bot) *Dispatcher {
+	return &Dispatcher{bots: bots}
+}
+
+// Run receives updates via long polling until the context is done,
+// the snoozed summaries of all accounts are reminded meanwhile.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if len(d.bots) == 0 {
+		return
+	}
+
+	first := d.bots[0]
+	if _, err := first.c.Request(tgbotapi.NewSetMyCommands(commands...)); err != nil {
+		zap.L().Warn("failed to register bot commands", zap.Error(err))
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 30
+	updates := first.api.GetUpdatesChan(u)
+
+	ticker := time.NewTicker(remindEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case update := <-updates:
+			timeout, cancel := context.WithTimeout(ctx, handleTimeout)
+			d.handleUpdate(timeout, &update)
+			cancel()
+		case <-ticker.C:
+			for _, b := range d.bots {
+				b.remindSnoozed()
+			}
+		case <-ctx.Done():
+			first.api.StopReceivingUpdates()
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) handleUpdate(ctx context.Context, update *tgbotapi.Update) {
+	if q := update.CallbackQuery; q != nil && len(d.bots) > 0 {
+		d.callbackBot(q).handleCallback(ctx, q)
+	}
+
+	if m := update.Message; m != nil && m.IsCommand() {
+		d.handleCommand(m)
+	}
+}
+
+// callbackBot returns the bot, which applies the action of the button,
+// the first bot rejects the buttons, which no bot can handle.
+func (d *Dispatcher) callbackBot(q *tgbotapi.CallbackQuery) *Bot {
+	c, ok := sender.ParseCallback(q.Data)
+	for _, b := range d.bots {
+		switch {
+		case !ok || q.Message == nil:
+		case c.Account != "":
+			if b.account == c.Account {
+				return b
+			}
+		case b.isAllowed(q.Message.Chat.ID):
+			return b
+		}
+	}
+
+	return d.bots[0]
+}
+
+// handleCommand answers the command with the replies of all accounts of
+// the chat, the help is the same for all of them, so it's sent once.
+func (d *Dispatcher) handleCommand(m *tgbotapi.Message) {
+	if m.Chat == nil {
+		return
+	}
+
+	var (
+		replier *Bot
+		texts   []string
+	)
+
+	for _, b := range d.bots {
+		if !b.isAllowed(m.Chat.ID) {
+			continue
+		}
+
+		if replier == nil {
+			replier = b
+		}
+
+		texts = append(texts, b.command(m))
+		if !isCommand(m.Command()) {
+			break
+		}
+	}
+
+	if replier != nil {
+		replier.reply(m.Chat.ID, strings.Join(texts, "\n\n"))
+	}
+}
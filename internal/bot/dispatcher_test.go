@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"context"
+	"github.com/fadyat/i4u/api/sender"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDispatcher(t *testing.T) {
+	alice, aliceMail, c := newTestBot(t)
+	bob, bobMail, _ := newTestBot(t)
+	alice.WithAccount("alice")
+	bob.WithAccount("bob")
+	bob.c = c
+
+	d := NewDispatcher(alice, bob)
+
+	// the button is handled by the bot of its account.
+	bobMail.On("ModifyLabels", context.Background(), "0", []string(nil), []string{"INBOX"}).Return(nil)
+	q := newCallbackQuery(testChatID, sender.Callback{Action: sender.ActionArchive, MessageID: "0", Account: "bob"})
+	d.callbackBot(q).handleCallback(context.Background(), q)
+	assert.Equal(t, "🗄 Archived in Gmail", lastAnswer(t, c))
+
+	feedback, err := bob.store.Feedback()
+	require.NoError(t, err)
+	assert.Len(t, feedback, 1)
+
+	// the old buttons are handled by the first bot of the chat.
+	legacy := newCallbackQuery(testChatID, sender.Callback{Action: sender.ActionReplied, MessageID: "1"})
+	assert.Same(t, alice, d.callbackBot(legacy))
+
+	// the button of the unknown account isn't applied to any inbox.
+	unknown := newCallbackQuery(testChatID, sender.Callback{Action: sender.ActionArchive, MessageID: "0", Account: "eve"})
+	d.callbackBot(unknown).handleCallback(context.Background(), unknown)
+	assert.Equal(t, "🤔 Unknown action", lastAnswer(t, c))
+	aliceMail.AssertNotCalled(t, "ModifyLabels")
+
+	// the commands are answered by all accounts at once.
+	sent := len(c.sent)
+	d.handleCommand(newCommand(testChatID, "/pause"))
+	assert.Len(t, c.sent, sent+1)
+	assert.Equal(t, "📮 alice\n⏸ Fetching is paused, /resume to continue\n\n"+
+		"📮 bob\n⏸ Fetching is paused, /resume to continue", lastReply(t, c))
+	assert.True(t, alice.state.IsPaused())
+	assert.True(t, bob.state.IsPaused())
+
+	d.handleCommand(newCommand(testChatID, "/start"))
+	assert.Equal(t, helpText, lastReply(t, c))
+}
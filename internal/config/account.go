@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultAccount is the name of the only account, which is made from
+// the gmail section, when no accounts are configured.
+const DefaultAccount = "default"
+
+// accountNameRe keeps the names short, because the name is a part of
+// the callback data of the Telegram buttons, limited to 64 bytes.
+var accountNameRe = regexp.MustCompile(`^[a-z0-9_-]{1,16}$`)
+
+// Account is a Gmail inbox, served by its own pipeline: it has its own
+// token, labels, fetch query, storage and destination chat, the LLM,
+// the senders and the rate limits are shared by all accounts.
+//
+//	accounts:
+//	  - name: alice
+//	    chat_id: 42
+//	  - name: work
+//	    query: in:inbox -label:i4u newer_than:7d
+//
+// The token and the storage are kept next to the ones from the gmail and
+// app sections by default, like .i4u/alice.token.json and .i4u/alice.db;
+// the query and the chat are taken from the gmail and telegram sections.
+type Account struct {
+	Name        string `yaml:"name"`
+	TokenFile   string `yaml:"token_file,omitempty"`
+	Query       string `yaml:"query,omitempty"`
+	ChatID      int64  `yaml:"chat_id,omitempty"`
+	StoragePath string `yaml:"storage_path,omitempty"`
+
+	// Labels are written by the setup command.
+	Labels *LabelsMapper `yaml:"labels,omitempty"`
+}
+
+// Gmail returns the config of the Gmail client of the account.
+func (a *Account) Gmail(base *Gmail) *Gmail {
+	g := *base
+	g.TokenFile, g.Query, g.L = a.TokenFile, a.Query, a.Labels
+	return &g
+}
+
+// ListAccounts returns the configured accounts, or the default one,
+// made from the gmail section, when none are configured.
+func (c *Config) ListAccounts() []Account {
+	if len(c.Accounts) > 0 {
+		return c.Accounts
+	}
+
+	return []Account{{
+		Name:        DefaultAccount,
+		TokenFile:   c.Gmail.TokenFile,
+		Query:       c.Gmail.Query,
+		ChatID:      c.Telegram.ChatID,
+		StoragePath: c.App.StoragePath,
+		Labels:      c.Labels,
+	}}
+}
+
+// Account returns the account by the name, the name may be omitted,
+// when there is only one account.
+func (c *Config) Account(name string) (*Account, error) {
+	accounts := c.ListAccounts()
+	if name == "" {
+		if len(accounts) > 1 {
+			names := make([]string, 0, len(accounts))
+			for i := range accounts {
+				names = append(names, accounts[i].Name)
+			}
+
+			return nil, fmt.Errorf("account is required, one of: %s", strings.Join(names, ", "))
+		}
+
+		return &accounts[0], nil
+	}
+
+	for i := range accounts {
+		if accounts[i].Name == name {
+			return &accounts[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown account: %s", name)
+}
+
+// withAccountDefaults fills the empty values of the accounts from the
+// gmail, telegram and app sections.
+func (c *Config) withAccountDefaults() {
+	for i := range c.Accounts {
+		a := &c.Accounts[i]
+		if a.TokenFile == "" {
+			a.TokenFile = filepath.Join(filepath.Dir(c.Gmail.TokenFile), a.Name+".token.json")
+		}
+
+		if a.StoragePath == "" {
+			a.StoragePath = filepath.Join(filepath.Dir(c.App.StoragePath), a.Name+".db")
+		}
+
+		if a.Query == "" {
+			a.Query = c.Gmail.Query
+		}
+
+		if a.ChatID == 0 {
+			a.ChatID = c.Telegram.ChatID
+		}
+	}
+}
+
+// validateAccounts checks the accounts, the default one is reported
+// by the fields of the sections, it's made from.
+func (c *Config) validateAccounts(v *validator) {
+	if len(c.Accounts) == 0 {
+		v.check(isFile(c.Gmail.TokenFile), "gmail.token_file: %s isn't found, run `i4u auth` first", c.Gmail.TokenFile)
+		v.labels("labels", c.Labels, "`i4u setup`")
+		return
+	}
+
+	var (
+		names    = make(map[string]bool, len(c.Accounts))
+		tokens   = make(map[string]bool, len(c.Accounts))
+		storages = make(map[string]bool, len(c.Accounts))
+	)
+
+	for i := range c.Accounts {
+		a, field := &c.Accounts[i], fmt.Sprintf("accounts[%d]", i)
+
+		v.check(accountNameRe.MatchString(a.Name), "%s.name: %q must be up to 16 lowercase letters, digits, - or _", field, a.Name)
+		v.check(!names[a.Name], "%s.name: %s is duplicated", field, a.Name)
+		v.check(!tokens[a.TokenFile], "%s.token_file: %s is used by another account", field, a.TokenFile)
+		v.check(!storages[a.StoragePath], "%s.storage_path: %s is used by another account", field, a.StoragePath)
+		names[a.Name], tokens[a.TokenFile], storages[a.StoragePath] = true, true, true
+
+		v.check(isFile(a.TokenFile), "%s.token_file: %s isn't found, run `i4u auth --account %s` first", field, a.TokenFile, a.Name)
+		v.check(!isDir(a.StoragePath), "%s.storage_path: %s is a directory", field, a.StoragePath)
+		v.check(a.ChatID != 0, "%s.chat_id: is required, or telegram.chat_id", field)
+		v.labels(field+".labels", a.Labels, fmt.Sprintf("`i4u setup --account %s`", a.Name))
+	}
+}
@@ -1,21 +1,44 @@
 package config
 
-import "github.com/ilyakaznacheev/cleanenv"
+import (
+	"time"
+)
 
 type AppConfig struct {
-	Keywords []string `env:"APP_ANALYZER_KEYWORDS" env-default:"internship,opportunity,training,intern"`
-	Version  string   `env:"APP_VERSION" env-default:"development"`
+	Keywords []string `yaml:"keywords" env:"APP_ANALYZER_KEYWORDS" env-default:"internship,opportunity,training,intern"`
+	Version  string   `yaml:"version" env:"APP_VERSION" env-default:"development"`
+
+	// StoragePath is a path to the database file, used for keeping the state
+	// between restarts, like user feedback and snoozed messages.
+	StoragePath string `yaml:"storage_path" env:"APP_STORAGE_PATH" env-default:".i4u/i4u.db"`
+
+	// Sender is a destination for the summaries, one of: tg, slack, discord, webhook, email, digest.
+	// Used only when no routes are provided in the config file.
+	Sender string `yaml:"sender" env:"APP_SENDER" env-default:"tg"`
+
+	// TemplatesDir is a directory with the templates of the messages, named as
+	// <sender>/<kind>.<txt|html>, missing templates are taken from the built-in ones.
+	TemplatesDir string `yaml:"templates_dir" env:"APP_TEMPLATES_DIR" env-default:".i4u/templates"`
+
+	// HTTPAddr is an address of the HTTP server, running along with the
+	// pipeline: Prometheus metrics on /metrics, liveness and readiness
+	// probes on /healthz and /readyz; empty disables it.
+	HTTPAddr string `yaml:"http_addr" env:"APP_HTTP_ADDR"`
+
+	// AdminToken enables the /admin endpoints of the HTTP server, requests
+	// are authorized with the "Authorization: Bearer <token>" header.
+	AdminToken string `yaml:"admin_token" env:"APP_ADMIN_TOKEN"`
+
+	// AlertsWindow is a period, during which the same errors are sent to
+	// the alerts chat only once, the repeated ones are counted and reported
+	// at the end of the period; zero sends every error.
+	AlertsWindow time.Duration `yaml:"alerts_window" env:"APP_ALERTS_WINDOW"`
+
+	// TracingExporter is a destination of the traces, one of: stdout, otlp,
+	// empty disables it. OTLP is configured with OTEL_EXPORTER_OTLP_* variables.
+	TracingExporter string `yaml:"tracing_exporter" env:"APP_TRACING_EXPORTER"`
 }
 
 func (a *AppConfig) IsDev() bool {
 	return a.Version == "development"
 }
-
-func NewAppConfig() (*AppConfig, error) {
-	var appConfig AppConfig
-	if err := cleanenv.ReadEnv(&appConfig); err != nil {
-		return nil, err
-	}
-
-	return &appConfig, nil
-}
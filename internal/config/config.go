@@ -0,0 +1,241 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
+	"os"
+	"time"
+)
+
+// File is the default path to the config file.
+const File = ".i4u/config.yaml"
+
+// ProfileEnv chooses the profile, overriding the one from the file.
+const ProfileEnv = "I4U_PROFILE"
+
+// Config is the whole configuration of the application, read from the
+// yaml file, the environment variables override the values from it:
+//
+//	profile: prod
+//	gmail:
+//	  credentials_file: credentials.json
+//	gpt:
+//	  openai_key: vault:i4u#openai_key
+//	  prompts:
+//	    before_msg: pretend you are an internship message parser
+//	telegram:
+//	  token: 123:abc
+//	  chat_id: 42
+//	  alerts_chat_id: 42
+//	senders:
+//	  slack:
+//	    webhook_url: https://hooks.slack.com/services/...
+//	app:
+//	  keywords: [internship, intern]
+//	features:
+//	  summarizer: false
+//	routes:
+//	  - sender: tg
+//	accounts:
+//	  - name: alice
+//	    chat_id: 43
+//	pipeline:
+//	  fetch_interval: 1m
+//	vault:
+//	  addr: http://127.0.0.1:8200
+//	profiles:
+//	  dev:
+//	    app:
+//	      version: development
+//	    pipeline:
+//	      fetch_interval: 10s
+//
+// The sections of the chosen profile are merged over the top-level ones,
+// the profile is chosen by the profile field or by I4U_PROFILE variable.
+//
+// Empty values are replaced with the defaults, except for the features
+// and the optional parts of the app config, like the HTTP server.
+//
+// The secrets, like the tokens and the passwords, may be references to
+// the files or to the Vault, see secret.Resolver, or be read from the files
+// by the environment variables with _FILE suffix, like OPENAI_KEY_FILE.
+type Config struct {
+	Profile string `yaml:"profile,omitempty"`
+
+	Gmail    Gmail     `yaml:"gmail"`
+	GPT      GPT       `yaml:"gpt"`
+	Telegram Telegram  `yaml:"telegram"`
+	Senders  Senders   `yaml:"senders"`
+	App      AppConfig `yaml:"app"`
+	Features Flags     `yaml:"features"`
+	Pipeline Pipeline  `yaml:"pipeline"`
+	Vault    Vault     `yaml:"vault"`
+	Routing  `yaml:",inline"`
+
+	// Accounts are the Gmail inboxes served by the pipeline, the gmail
+	// section with the labels is the only account, when they are empty.
+	Accounts []Account `yaml:"accounts,omitempty"`
+
+	// Labels are written by the setup command.
+	Labels *LabelsMapper `yaml:"labels,omitempty"`
+}
+
+// Read reads the config from the file and the environment, missing
+// file isn't an error, the config is taken from the environment then.
+//
+// The config isn't validated, because some commands need only its part.
+func Read(path string) (*Config, error) {
+	var file struct {
+		Config   `yaml:",inline"`
+		Profiles map[string]yaml.Node `yaml:"profiles"`
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	// the defaults are set before, so the file can override them with
+	// the empty values, unlike the defaults from the tags.
+	file.App.HTTPAddr = defaultHTTPAddr
+	file.App.AlertsWindow = defaultAlertsWindow
+	file.Features = defaultFlags()
+	if err = yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if profile := os.Getenv(ProfileEnv); profile != "" {
+		file.Profile = profile
+	}
+
+	if file.Profile != "" {
+		node, ok := file.Profiles[file.Profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile: %s", file.Profile)
+		}
+
+		if err = node.Decode(&file.Config); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", file.Profile, err)
+		}
+	}
+
+	cfg := &file.Config
+	cfg.Senders.withDefaults()
+	if err = cfg.readEnv(); err != nil {
+		return nil, err
+	}
+
+	if err = cfg.readSecrets(); err != nil {
+		return nil, err
+	}
+
+	cfg.Gmail.L = cfg.Labels
+	cfg.withAccountDefaults()
+	cfg.Pipeline.withDefaults()
+	cfg.Routing.withDefaults(&cfg.App)
+	return cfg, nil
+}
+
+// readEnv overrides the values by the environment variables.
+func (c *Config) readEnv() error {
+	sections := []struct {
+		name string
+		cfg  any
+	}{
+		{"gmail", &c.Gmail},
+		{"gpt", &c.GPT},
+		{"telegram", &c.Telegram},
+		{"slack", c.Senders.Slack},
+		{"discord", c.Senders.Discord},
+		{"webhook", c.Senders.Webhook},
+		{"smtp", c.Senders.SMTP},
+		{"app", &c.App},
+		{"features", &c.Features},
+		{"vault", &c.Vault},
+	}
+
+	for _, s := range sections {
+		if err := cleanenv.ReadEnv(s.cfg); err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+
+	return nil
+}
+
+// secrets are the paths to the values, which are masked by YAML.
+var secrets = [][]string{
+	{"gmail", "token_passphrase"},
+	{"gpt", "openai_key"},
+	{"telegram", "token"},
+	{"senders", "slack", "webhook_url"},
+	{"senders", "slack", "token"},
+	{"senders", "discord", "webhook_url"},
+	{"senders", "webhook", "headers"},
+	{"senders", "webhook", "secret"},
+	{"senders", "smtp", "password"},
+	{"app", "admin_token"},
+	{"vault", "token"},
+}
+
+// YAML returns the config in the format of the file, with the chosen
+// profile applied; the secrets are masked, when it's redacted.
+func (c *Config) YAML(redacted bool) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(c); err != nil {
+		return nil, err
+	}
+
+	if redacted {
+		for _, path := range secrets {
+			redact(&node, path)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// redact masks the non-empty values by the path, all values of the maps.
+func redact(node *yaml.Node, path []string) {
+	if len(path) == 0 {
+		switch {
+		case node.Kind == yaml.MappingNode:
+			for i := 1; i < len(node.Content); i += 2 {
+				redact(node.Content[i], nil)
+			}
+		case node.Kind == yaml.ScalarNode && node.Value != "":
+			node.Value, node.Tag, node.Style = "[redacted]", "!!str", 0
+		}
+
+		return
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == path[0] {
+			redact(node.Content[i+1], path[1:])
+		}
+	}
+}
+
+const (
+	defaultHTTPAddr     = ":9090"
+	defaultAlertsWindow = 5 * time.Minute
+)
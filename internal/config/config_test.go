@@ -0,0 +1,256 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+gpt:
+  openai_key: sk-secret
+telegram:
+  token: "123:abc"
+  chat_id: 42
+  alerts_chat_id: 43
+senders:
+  webhook:
+    url: https://example.com
+    headers:
+      Authorization: Bearer secret
+app:
+  http_addr: ""
+features:
+  summarizer: false
+  tracker: false
+routes:
+  - sender: webhook
+    verdicts: [offer]
+pipeline:
+  fetch_interval: 1m
+labels:
+  i4u: Label_1
+  intern:true: Label_2
+  intern:false: Label_3
+profiles:
+  dev:
+    app:
+      version: development
+    pipeline:
+      fetch_interval: 10s
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRead(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	c, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-secret", c.GPT.OpenAIKey)
+	assert.Equal(t, int64(42), c.Telegram.ChatID)
+	assert.Equal(t, "https://example.com", c.Senders.Webhook.URL)
+	assert.Equal(t, 587, c.Senders.SMTP.Port)
+	assert.Equal(t, "", c.App.HTTPAddr)
+	assert.Equal(t, defaultAlertsWindow, c.App.AlertsWindow)
+	assert.Equal(t, time.Minute, c.Pipeline.FetchInterval)
+	assert.Equal(t, "Label_1", c.Gmail.L.I4U)
+	assert.Equal(t, []Route{{Name: "webhook", Sender: "webhook", Verdicts: []string{"offer"}}}, c.Routes)
+
+	assert.False(t, c.Features.IsSummarizerJobEnabled)
+	assert.True(t, c.Features.IsAnalyzerJobEnabled)
+	assert.False(t, c.Features.IsStageEnabled("tracker"))
+
+	// the profile is merged over the file, the environment overrides both.
+	t.Setenv(ProfileEnv, "dev")
+	t.Setenv("TG_CHAT_ID", "44")
+	t.Setenv("SUMMARIZER_JOB_ENABLED", "true")
+
+	c, err = Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, "dev", c.Profile)
+	assert.True(t, c.App.IsDev())
+	assert.Equal(t, 10*time.Second, c.Pipeline.FetchInterval)
+	assert.Equal(t, "sk-secret", c.GPT.OpenAIKey)
+	assert.Equal(t, int64(44), c.Telegram.ChatID)
+	assert.True(t, c.Features.IsSummarizerJobEnabled)
+
+	t.Setenv(ProfileEnv, "prod")
+	_, err = Read(path)
+	assert.EqualError(t, err, "unknown profile: prod")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	dir := t.TempDir()
+	credentials, token := filepath.Join(dir, "credentials.json"), filepath.Join(dir, "token.json")
+	require.NoError(t, os.WriteFile(credentials, []byte("{}"), 0o600))
+	require.NoError(t, os.WriteFile(token, []byte("{}"), 0o600))
+
+	t.Setenv("GMAIL_CREDENTIALS_FILE", credentials)
+	t.Setenv("GMAIL_TOKEN_FILE", token)
+	t.Setenv("APP_STORAGE_PATH", filepath.Join(dir, "i4u.db"))
+
+	c, err := Read(writeConfig(t, testConfig))
+	require.NoError(t, err)
+	require.NoError(t, c.Validate())
+
+	t.Setenv("GMAIL_TOKEN_FILE", filepath.Join(dir, "missing.json"))
+	t.Setenv("TG_CHAT_ID", "0")
+	t.Setenv("TG_CARD_MODE", "kek")
+	t.Setenv("WEBHOOK_URL", "")
+
+	c, err = Read(writeConfig(t, strings.Replace(testConfig, "Label_2", "intern", 1)))
+	require.NoError(t, err)
+	assert.EqualError(t, c.Validate(), strings.Join([]string{
+		"gmail.token_file: " + filepath.Join(dir, "missing.json") + " isn't found, run `i4u auth` first",
+		`labels.intern:true: invalid label id "intern", run ` + "`i4u setup`" + ` again`,
+		"telegram.chat_id: is required",
+		`telegram.card_mode: unknown mode "kek"`,
+		"routes[0]: senders.webhook: url is required",
+	}, "\n"))
+}
+
+func TestConfig_YAML(t *testing.T) {
+	c, err := Read(writeConfig(t, testConfig))
+	require.NoError(t, err)
+
+	content, err := c.YAML(false)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "openai_key: sk-secret")
+
+	content, err = c.YAML(true)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "sk-secret")
+	assert.NotContains(t, string(content), "Bearer secret")
+	assert.Contains(t, string(content), "openai_key: '[redacted]'")
+	assert.Contains(t, string(content), "Authorization: '[redacted]'")
+	assert.Contains(t, string(content), "chat_id: 42")
+
+	// the redacted config is still readable.
+	reread, err := Read(writeConfig(t, string(content)))
+	require.NoError(t, err)
+	assert.Equal(t, c.Routes, reread.Routes)
+	assert.Equal(t, c.Features, reread.Features)
+}
+
+func TestConfig_Accounts(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GMAIL_TOKEN_FILE", filepath.Join(dir, "token.json"))
+	t.Setenv("APP_STORAGE_PATH", filepath.Join(dir, "i4u.db"))
+
+	c, err := Read(writeConfig(t, testConfig))
+	require.NoError(t, err)
+
+	// the gmail section is the only account without the accounts section.
+	account, err := c.Account("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAccount, account.Name)
+	assert.Equal(t, filepath.Join(dir, "token.json"), account.TokenFile)
+	assert.Equal(t, "in:inbox -label:i4u", account.Query)
+	assert.Equal(t, int64(42), account.ChatID)
+	assert.Equal(t, "Label_1", account.Gmail(&c.Gmail).L.I4U)
+
+	c, err = Read(writeConfig(t, testConfig+`
+accounts:
+  - name: alice
+    labels: {i4u: Label_4, intern:true: Label_5, intern:false: Label_6}
+  - name: work
+    query: in:inbox newer_than:1d
+    chat_id: 44
+    storage_path: `+filepath.Join(dir, "work.db")+`
+`))
+	require.NoError(t, err)
+
+	_, err = c.Account("")
+	assert.EqualError(t, err, "account is required, one of: alice, work")
+	_, err = c.Account("bob")
+	assert.EqualError(t, err, "unknown account: bob")
+
+	alice, err := c.Account("alice")
+	require.NoError(t, err)
+	assert.Equal(t, Account{
+		Name:        "alice",
+		TokenFile:   filepath.Join(dir, "alice.token.json"),
+		Query:       "in:inbox -label:i4u",
+		ChatID:      42,
+		StoragePath: filepath.Join(dir, "alice.db"),
+		Labels:      &LabelsMapper{I4U: "Label_4", IsIntern: "Label_5", NotIntern: "Label_6"},
+	}, *alice)
+
+	work, err := c.Account("work")
+	require.NoError(t, err)
+	assert.Equal(t, "in:inbox newer_than:1d", work.Gmail(&c.Gmail).Query)
+	assert.Equal(t, int64(44), work.ChatID)
+	assert.Nil(t, work.Labels)
+}
+
+func TestConfig_ValidateAccounts(t *testing.T) {
+	dir := t.TempDir()
+	credentials, token := filepath.Join(dir, "credentials.json"), filepath.Join(dir, "alice.token.json")
+	require.NoError(t, os.WriteFile(credentials, []byte("{}"), 0o600))
+	require.NoError(t, os.WriteFile(token, []byte("{}"), 0o600))
+
+	t.Setenv("GMAIL_CREDENTIALS_FILE", credentials)
+	t.Setenv("GMAIL_TOKEN_FILE", filepath.Join(dir, "token.json"))
+	t.Setenv("APP_STORAGE_PATH", filepath.Join(dir, "i4u.db"))
+
+	c, err := Read(writeConfig(t, testConfig+`
+accounts:
+  - name: alice
+    labels: {i4u: Label_4, intern:true: Label_5, intern:false: Label_6}
+  - name: Bob
+    token_file: `+token+`
+`))
+	require.NoError(t, err)
+	assert.EqualError(t, c.Validate(), strings.Join([]string{
+		`accounts[1].name: "Bob" must be up to 16 lowercase letters, digits, - or _`,
+		"accounts[1].token_file: " + token + " is used by another account",
+		"accounts[1].labels: not found, run `i4u setup --account Bob` first",
+	}, "\n"))
+}
+
+func TestRead_Secrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.URL.Path != "/v1/kv/data/i4u" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":{"data":{"tg_token":"1:vault"}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	vaultToken, adminToken := filepath.Join(dir, "vault_token"), filepath.Join(dir, "admin_token")
+	require.NoError(t, os.WriteFile(vaultToken, []byte("root\n"), 0o600))
+	require.NoError(t, os.WriteFile(adminToken, []byte("admin\n"), 0o600))
+
+	path := writeConfig(t, testConfig+`
+vault:
+  addr: `+srv.URL+`
+  token: file:`+vaultToken+`
+  mount: kv
+`)
+	t.Setenv("TG_TOKEN", "vault:i4u#tg_token")
+	t.Setenv("APP_ADMIN_TOKEN_FILE", adminToken)
+
+	c, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, "1:vault", c.Telegram.Token)
+	assert.Equal(t, "admin", c.App.AdminToken)
+	assert.Equal(t, "sk-secret", c.GPT.OpenAIKey)
+
+	t.Setenv("APP_ADMIN_TOKEN", "admin")
+	_, err = Read(path)
+	assert.EqualError(t, err, "app.admin_token: either APP_ADMIN_TOKEN or APP_ADMIN_TOKEN_FILE must be set, not both")
+}
@@ -0,0 +1,9 @@
+package config
+
+type Discord struct {
+
+	// WebhookURL is a channel webhook, can be created in the channel settings.
+	//
+	// https://discord.com/developers/docs/resources/webhook#execute-webhook
+	WebhookURL string `yaml:"webhook_url" env:"DISCORD_WEBHOOK_URL" env-description:"Discord webhook URL"`
+}
@@ -1,18 +1,168 @@
 package config
 
-import "github.com/ilyakaznacheev/cleanenv"
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sync"
+)
 
 var (
 	FeatureFlags Flags
+
+	// flagsMu guards the flags, which are toggled at runtime.
+	flagsMu sync.RWMutex
 )
 
 type Flags struct {
-	IsLabelerJobEnabled    bool `env:"LABELER_JOB_ENABLED" env-default:"true"`
-	IsAnalyzerJobEnabled   bool `env:"ANALYZER_JOB_ENABLED" env-default:"true"`
-	IsSummarizerJobEnabled bool `env:"SUMMARIZER_JOB_ENABLED" env-default:"true"`
-	IsSenderJobEnabled     bool `env:"SENDER_JOB_ENABLED" env-default:"true"`
+	// the jobs are enabled by default, the defaults are set before reading
+	// the config, so the jobs can be disabled by the file too.
+	IsLabelerJobEnabled    bool `env:"LABELER_JOB_ENABLED"`
+	IsAnalyzerJobEnabled   bool `env:"ANALYZER_JOB_ENABLED"`
+	IsSummarizerJobEnabled bool `env:"SUMMARIZER_JOB_ENABLED"`
+	IsSenderJobEnabled     bool `env:"SENDER_JOB_ENABLED"`
+
+	// Stages are the flags of the custom stages by their names, like
+	// STAGES_ENABLED=tracker:false, the stages are enabled by default.
+	Stages map[string]bool `env:"STAGES_ENABLED"`
+}
+
+// Map returns the flags by the names of the jobs.
+func (f Flags) Map() map[string]bool {
+	m := map[string]bool{
+		"labeler":    f.IsLabelerJobEnabled,
+		"analyzer":   f.IsAnalyzerJobEnabled,
+		"summarizer": f.IsSummarizerJobEnabled,
+		"sender":     f.IsSenderJobEnabled,
+	}
+
+	for name, enabled := range f.Stages {
+		m[name] = enabled
+	}
+
+	return m
+}
+
+// IsStageEnabled tells, whether the custom stage is enabled.
+func (f Flags) IsStageEnabled(name string) bool {
+	enabled, ok := f.Stages[name]
+	return !ok || enabled
+}
+
+func defaultFlags() Flags {
+	return Flags{
+		IsLabelerJobEnabled:    true,
+		IsAnalyzerJobEnabled:   true,
+		IsSummarizerJobEnabled: true,
+		IsSenderJobEnabled:     true,
+	}
+}
+
+// UnmarshalYAML reads the flags by the names of the stages, like:
+//
+//	features:
+//	  summarizer: false
+//	  tracker: false
+func (f *Flags) UnmarshalYAML(value *yaml.Node) error {
+	var m map[string]bool
+	if err := value.Decode(&m); err != nil {
+		return err
+	}
+
+	for name, enabled := range m {
+		// the custom stages aren't declared yet, while the config is read.
+		if err := f.set(name, enabled); err != nil {
+			f.Stages = withStage(f.Stages, name, enabled)
+		}
+	}
+
+	return nil
+}
+
+func (f Flags) MarshalYAML() (any, error) {
+	return f.Map(), nil
+}
+
+// InitFeatures replaces the flags with the ones read from the config.
+func InitFeatures(f Flags) {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+
+	FeatureFlags = f
+}
+
+// Features returns the current flags, safe to call, while
+// they are toggled by SetFeature.
+func Features() Flags {
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+
+	return FeatureFlags
+}
+
+// SetFeature enables or disables the job by its name, the change isn't
+// persisted and lasts until the restart or the reload of the config.
+func SetFeature(name string, enabled bool) error {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+
+	return FeatureFlags.set(name, enabled)
 }
 
-func NewFeatureFlags() error {
-	return cleanenv.ReadEnv(&FeatureFlags)
+// setFeatures toggles the flags at once, so they aren't seen half-applied.
+func setFeatures(features map[string]bool) error {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+
+	for name, enabled := range features {
+		if err := FeatureFlags.set(name, enabled); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// set toggles the job or the declared custom stage.
+func (f *Flags) set(name string, enabled bool) error {
+	switch name {
+	case "labeler":
+		f.IsLabelerJobEnabled = enabled
+	case "analyzer":
+		f.IsAnalyzerJobEnabled = enabled
+	case "summarizer":
+		f.IsSummarizerJobEnabled = enabled
+	case "sender":
+		f.IsSenderJobEnabled = enabled
+	default:
+		if _, ok := f.Stages[name]; !ok {
+			return fmt.Errorf("unknown feature: %s", name)
+		}
+
+		f.Stages = withStage(f.Stages, name, enabled)
+	}
+
+	return nil
+}
+
+// DeclareFeature makes the custom stage toggleable by SetFeature,
+// the flag from the environment is kept, when it's given.
+func DeclareFeature(name string) {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+
+	if _, ok := FeatureFlags.Stages[name]; !ok {
+		FeatureFlags.Stages = withStage(FeatureFlags.Stages, name, true)
+	}
+}
+
+// withStage copies the flags, because the returned ones
+// by Features are read without the lock.
+func withStage(stages map[string]bool, name string, enabled bool) map[string]bool {
+	updated := make(map[string]bool, len(stages)+1)
+	for k, v := range stages {
+		updated[k] = v
+	}
+
+	updated[name] = enabled
+	return updated
 }
@@ -1,7 +1,10 @@
 package config
 
 import (
-	"github.com/ilyakaznacheev/cleanenv"
+	"errors"
+	"github.com/fadyat/i4u/internal/secret"
+	"os"
+	"path/filepath"
 )
 
 type Gmail struct {
@@ -11,33 +14,53 @@ type Gmail struct {
 	//
 	// https://console.cloud.google.com/apis/credentials
 	//
-	CredentialsFile string `env:"GMAIL_CREDENTIALS_FILE" env-description:"Path to your credentials file" env-default:"credentials.json"`
+	CredentialsFile string `yaml:"credentials_file" env:"GMAIL_CREDENTIALS_FILE" env-description:"Path to your credentials file" env-default:"credentials.json"`
 
 	// TokenFile is a path to your token file for performing OAuth2
 	// authentication. Will be used to store token after authentication and
 	// refreshing access token automatically, when it expires.
-	TokenFile string `env:"GMAIL_TOKEN_FILE" env-description:"Path to your token file" env-default:"token.json"`
+	TokenFile string `yaml:"token_file" env:"GMAIL_TOKEN_FILE" env-description:"Path to your token file" env-default:"token.json"`
+
+	// TokenPassphrase encrypts the token files, when it's set, the
+	// plain tokens are encrypted on the next save.
+	TokenPassphrase string `yaml:"token_passphrase" env:"GMAIL_TOKEN_PASSPHRASE" env-description:"Passphrase for encrypting token files"`
+
+	// TokenKeyFile is a file with the random key, used instead of the
+	// passphrase, like the one made by `head -c 32 /dev/urandom`.
+	TokenKeyFile string `yaml:"token_key_file" env:"GMAIL_TOKEN_KEY_FILE" env-description:"Key file for encrypting token files"`
 
 	// LabelsLst is a list of labels that will be created in your Gmail account,
 	// used for marking processed messages to avoid processing them again.
-	LabelsLst []string `env:"GMAIL_LABELS" env-default:"i4u,intern:true,intern:false"`
+	LabelsLst []string `yaml:"labels" env:"GMAIL_LABELS" env-default:"i4u,intern:true,intern:false"`
 
-	// L is a labels parsed after setup from yaml config file.
-	L *LabelsMapper `yaml:"labels"`
+	// L is a labels parsed after setup from the top-level labels
+	// section of the yaml config file, or from the account's one.
+	L *LabelsMapper `yaml:"-"`
+
+	// Query is a Gmail search query, which selects the messages for
+	// processing, the processed ones are excluded by the i4u label.
+	Query string `yaml:"query" env:"GMAIL_QUERY" env-description:"Search query for fetching messages" env-default:"in:inbox -label:i4u"`
 
 	// MessagesLimit is a batch size for fetching messages from Gmail.
-	MessagesLimit int64 `env:"GMAIL_MESSAGES_LIMIT" env-description:"Batch size for fetching messages" env-default:"2"`
+	MessagesLimit int64 `yaml:"messages_limit" env:"GMAIL_MESSAGES_LIMIT" env-description:"Batch size for fetching messages" env-default:"2"`
 }
 
-func NewGmail() (*Gmail, error) {
-	var c Gmail
-	if err := cleanenv.ReadEnv(&c); err != nil {
-		return nil, err
-	}
+// TokenKey returns the key of the token files, it's nil,
+// when the tokens are kept unencrypted.
+func (g *Gmail) TokenKey() (*secret.Key, error) {
+	switch {
+	case g.TokenPassphrase != "" && g.TokenKeyFile != "":
+		return nil, errors.New("either token_passphrase or token_key_file must be set, not both")
+	case g.TokenPassphrase != "":
+		return secret.NewKey([]byte(g.TokenPassphrase))
+	case g.TokenKeyFile != "":
+		material, err := os.ReadFile(filepath.Clean(g.TokenKeyFile))
+		if err != nil {
+			return nil, err
+		}
 
-	if err := cleanenv.ReadConfig(".i4u/config.yaml", &c); err != nil {
-		return nil, err
+		return secret.NewKey(material)
 	}
 
-	return &c, nil
+	return nil, nil
 }
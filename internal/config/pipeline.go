@@ -0,0 +1,136 @@
+package config
+
+import (
+	"time"
+)
+
+// Pipeline configures processing of the messages by the stages,
+// it's read from the `pipeline` section of the yaml config file.
+type Pipeline struct {
+	Retries    Retries    `yaml:"retries"`
+	Workers    Workers    `yaml:"workers"`
+	Timeouts   Timeouts   `yaml:"timeouts"`
+	RateLimits RateLimits `yaml:"rate_limits"`
+	Topology   Topology   `yaml:"topology"`
+
+	// FetchInterval is a period of checking the mailbox for new messages.
+	FetchInterval time.Duration `yaml:"fetch_interval"`
+
+	// Buffer is a size of the channel between the fetcher
+	// and the pipeline stages.
+	Buffer int `yaml:"buffer"`
+
+	// MaxQueued is a number of messages waiting in the queue of the
+	// first stage, after which the fetched messages aren't accepted,
+	// until the stage catches up.
+	MaxQueued int `yaml:"max_queued"`
+}
+
+const (
+	defaultBuffer        = 16
+	defaultMaxQueued     = 100
+	defaultWorkers       = 4
+	defaultTimeout       = 5 * time.Second
+	defaultFetchInterval = 10 * time.Second
+)
+
+// Stage is the configuration of the single pipeline stage,
+// gathered from all sections of the pipeline config.
+type Stage struct {
+	Retry   RetryPolicy
+	Workers int
+
+	// Timeout is given for a single attempt to process the message.
+	Timeout time.Duration
+
+	// Deadline is a time given for the message to pass the whole
+	// pipeline since it was fetched, zero means there is no deadline.
+	Deadline time.Duration
+}
+
+// Stage returns the configuration of the stage.
+func (p *Pipeline) Stage(name string) Stage {
+	return Stage{
+		Retry:    p.Retries.For(name),
+		Workers:  p.Workers.For(name),
+		Timeout:  p.Timeouts.For(name),
+		Deadline: p.Timeouts.Total,
+	}
+}
+
+// Timeouts limit the time of processing the messages, configured like:
+//
+//	pipeline:
+//	  timeouts:
+//	    default: 5s
+//	    total: 1h
+//	    stages:
+//	      fetcher: 30s
+//	      summarizer: 1m
+type Timeouts struct {
+	Default time.Duration            `yaml:"default"`
+	Stages  map[string]time.Duration `yaml:"stages"`
+
+	// Total is a time given for the message to pass the whole pipeline,
+	// after it the message goes to the dead-letter queue.
+	Total time.Duration `yaml:"total"`
+}
+
+// For returns the timeout of the stage, the default
+// is used, when the stage isn't configured.
+func (t *Timeouts) For(stage string) time.Duration {
+	if d := t.Stages[stage]; d > 0 {
+		return d
+	}
+
+	if t.Default > 0 {
+		return t.Default
+	}
+
+	return defaultTimeout
+}
+
+// Workers are the numbers of messages processed by the pipeline stages
+// at once, configured like:
+//
+//	pipeline:
+//	  workers:
+//	    default: 4
+//	    stages:
+//	      summarizer: 2
+type Workers struct {
+	Default int            `yaml:"default"`
+	Stages  map[string]int `yaml:"stages"`
+}
+
+// For returns the number of workers of the stage, the default
+// is used, when the stage isn't configured.
+func (w *Workers) For(stage string) int {
+	if n := w.Stages[stage]; n > 0 {
+		return n
+	}
+
+	if w.Default > 0 {
+		return w.Default
+	}
+
+	return defaultWorkers
+}
+
+func (p *Pipeline) withDefaults() *Pipeline {
+	if p.Buffer <= 0 {
+		p.Buffer = defaultBuffer
+	}
+
+	if p.MaxQueued <= 0 {
+		p.MaxQueued = defaultMaxQueued
+	}
+
+	if p.FetchInterval <= 0 {
+		p.FetchInterval = defaultFetchInterval
+	}
+
+	p.Topology.withDefaults()
+
+	return p
+}
@@ -0,0 +1,79 @@
+package config
+
+import "time"
+
+// Rate is a quota of the provider: Limit units are allowed per the period,
+// Burst units can be spent at once, when the budget was saved up.
+type Rate struct {
+	Limit float64       `yaml:"limit"`
+	Per   time.Duration `yaml:"per"`
+	Burst float64       `yaml:"burst"`
+}
+
+// PerSecond returns the number of units restored every second,
+// zero means there is no limit.
+func (r Rate) PerSecond() float64 {
+	if r.Limit <= 0 || r.Per <= 0 {
+		return 0
+	}
+
+	return r.Limit / r.Per.Seconds()
+}
+
+func (r Rate) withDefaults(def Rate) Rate {
+	if r.Limit == 0 {
+		r.Limit = def.Limit
+	}
+
+	if r.Per == 0 {
+		r.Per = def.Per
+	}
+
+	if r.Burst == 0 {
+		r.Burst = def.Burst
+	}
+
+	if r.Burst == 0 {
+		r.Burst = r.Limit
+	}
+
+	return r
+}
+
+// Names of the rate limits, each of them is a separate budget.
+const (
+	// RateGmail is measured in Gmail API quota units per user,
+	// each account has its own budget, like `gmail:alice`.
+	RateGmail = "gmail"
+
+	RateOpenAIRequests = "openai_requests"
+	RateOpenAITokens   = "openai_tokens"
+
+	// RateTelegram is shared by all chats of the bot,
+	// RateTelegramChat is applied to every chat separately.
+	RateTelegram     = "telegram"
+	RateTelegramChat = "telegram_chat"
+)
+
+var defaultRates = map[string]Rate{
+	RateGmail:          {Limit: 250, Per: time.Second},
+	RateOpenAIRequests: {Limit: 500, Per: time.Minute, Burst: 10},
+	RateOpenAITokens:   {Limit: 30000, Per: time.Minute, Burst: 5000},
+	RateTelegram:       {Limit: 30, Per: time.Second},
+	RateTelegramChat:   {Limit: 1, Per: time.Second, Burst: 3},
+}
+
+// RateLimits override the default quotas of the providers, configured like:
+//
+//	pipeline:
+//	  rate_limits:
+//	    openai_requests:
+//	      limit: 3
+//	      per: 1m
+type RateLimits map[string]Rate
+
+// For returns the rate of the provider, missing fields are
+// taken from the default rate.
+func (r RateLimits) For(name string) Rate {
+	return r[name].withDefaults(defaultRates[name])
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// watchInterval is a period of checking the config file for changes.
+const watchInterval = 2 * time.Second
+
+// Reloadable is the part of the config, which is applied to the running
+// application on SIGHUP or when the file is changed: the keywords of the
+// app, the prompts of gpt, the features and the routes. The rest of the
+// config, like the pipeline, is applied after the restart.
+type Reloadable struct {
+	Keywords []string
+	Prompts  Prompts
+
+	// Features are the flags by the names of the stages, the flags
+	// toggled by SetFeature are overridden by them.
+	Features map[string]bool
+
+	Routing
+}
+
+// NewReloadable takes the reloadable part of the config.
+func NewReloadable(c *Config) *Reloadable {
+	return &Reloadable{
+		Keywords: c.App.Keywords,
+		Prompts:  c.GPT.FeedPrompts,
+		Features: c.Features.Map(),
+		Routing:  c.Routing,
+	}
+}
+
+// Applier prepares the part of the application for the new config and
+// returns the function, which switches it to the prepared state.
+//
+// Nothing is switched, when any of the appliers fails, so the new config
+// is applied entirely or not at all.
+type Applier func(c *Reloadable) (apply func(), err error)
+
+// ApplyFeatures replaces the flags by the ones from the config.
+func ApplyFeatures(c *Reloadable) (func(), error) {
+	known := Features().Map()
+	for name := range c.Features {
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("unknown feature: %s", name)
+		}
+	}
+
+	// the features are known, so they can't fail.
+	return func() { _ = setFeatures(c.Features) }, nil
+}
+
+// Watcher reloads the config and applies it to the subscribers.
+type Watcher struct {
+	path string
+	load func() (*Reloadable, error)
+
+	// mu serializes the reloads, modTime is the time of the file change,
+	// which is already applied.
+	mu       sync.Mutex
+	appliers []Applier
+	modTime  time.Time
+
+	current atomic.Pointer[Reloadable]
+}
+
+// NewWatcher creates the watcher of the file, the config is read
+// by load, nothing is loaded until the first Reload.
+func NewWatcher(path string, load func() (*Reloadable, error)) *Watcher {
+	return &Watcher{path: path, load: load}
+}
+
+// Subscribe adds the appliers of the next reloads.
+func (w *Watcher) Subscribe(appliers ...Applier) *Watcher {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.appliers = append(w.appliers, appliers...)
+	return w
+}
+
+// Current returns the last applied config, nil before the first Reload.
+func (w *Watcher) Current() *Reloadable {
+	return w.current.Load()
+}
+
+// Reload reads the config and applies it, the previous
+// config stays in place, when the new one is invalid.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// the time is taken before reading, so the change made
+	// during the reading is picked up by the next check.
+	w.modTime = w.stat()
+
+	c, err := w.load()
+	if err != nil {
+		return err
+	}
+
+	applies := make([]func(), 0, len(w.appliers))
+	for _, applier := range w.appliers {
+		apply, e := applier(c)
+		if e != nil {
+			return e
+		}
+
+		applies = append(applies, apply)
+	}
+
+	for _, apply := range applies {
+		apply()
+	}
+
+	w.current.Store(c)
+	return nil
+}
+
+// Run reloads the config on SIGHUP or when the file is changed,
+// until the context is done.
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.reload("SIGHUP")
+		case <-ticker.C:
+			if w.changed() {
+				w.reload("file change")
+			}
+		}
+	}
+}
+
+func (w *Watcher) reload(reason string) {
+	if err := w.Reload(); err != nil {
+		zap.L().Error("failed to reload config, keeping the previous one", zap.String("reason", reason), zap.Error(err))
+		return
+	}
+
+	zap.L().Info("config reloaded", zap.String("reason", reason))
+}
+
+func (w *Watcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return !w.stat().Equal(w.modTime)
+}
+
+// stat returns the modification time of the file, zero when it's missing.
+func (w *Watcher) stat() time.Time {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher_Reload(t *testing.T) {
+	summarizer := Features().IsSummarizerJobEnabled
+	t.Cleanup(func() { require.NoError(t, SetFeature("summarizer", summarizer)) })
+	require.NoError(t, SetFeature("summarizer", true))
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	t.Setenv("FEED_PROMPTS_AFTER_MSG", "env after")
+
+	var keywords []string
+	watcher := NewWatcher(path, func() (*Reloadable, error) {
+		c, err := Read(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewReloadable(c), nil
+	}).Subscribe(
+		ApplyFeatures,
+		func(c *Reloadable) (func(), error) {
+			return func() { keywords = c.Keywords }, nil
+		},
+	)
+
+	// nothing is configured in the file, the defaults are used.
+	require.NoError(t, watcher.Reload())
+	assert.Equal(t, []string{"internship", "opportunity", "training", "intern"}, keywords)
+	assert.Equal(t, []Route{{Name: "tg", Sender: "tg"}}, watcher.Current().Routes)
+
+	write(`
+app:
+  keywords: [offer]
+gpt:
+  prompts:
+    before_msg: file before
+features:
+  summarizer: false
+routes:
+  - sender: slack
+    verdicts: [offer]
+`)
+	require.NoError(t, watcher.Reload())
+	assert.Equal(t, []string{"offer"}, keywords)
+	assert.Equal(t, "file before", watcher.Current().Prompts.BeforeMsg)
+	assert.Equal(t, "env after", watcher.Current().Prompts.AfterMsg)
+	assert.Equal(t, []Route{{Name: "slack", Sender: "slack", Verdicts: []string{"offer"}}}, watcher.Current().Routes)
+	assert.False(t, Features().IsSummarizerJobEnabled)
+
+	// the invalid config isn't applied at all.
+	write(`
+app:
+  keywords: [test task]
+features:
+  kek: true
+`)
+	assert.EqualError(t, watcher.Reload(), "unknown feature: kek")
+	assert.Equal(t, []string{"offer"}, keywords)
+	assert.Equal(t, []string{"offer"}, watcher.Current().Keywords)
+
+	write(`
+app:
+  keywords: [test task]
+features:
+  summarizer: true
+`)
+	watcher.Subscribe(func(*Reloadable) (func(), error) {
+		return nil, errors.New("sender is down")
+	})
+	assert.EqualError(t, watcher.Reload(), "sender is down")
+	assert.Equal(t, []string{"offer"}, keywords)
+	assert.False(t, Features().IsSummarizerJobEnabled)
+}
+
+func TestWatcher_Changed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	watcher := NewWatcher(path, func() (*Reloadable, error) {
+		return &Reloadable{}, nil
+	})
+
+	// the missing file isn't a change, until it's created.
+	require.NoError(t, watcher.Reload())
+	assert.False(t, watcher.changed())
+
+	require.NoError(t, os.WriteFile(path, []byte("app: {keywords: [offer]}"), 0o600))
+	assert.True(t, watcher.changed())
+
+	require.NoError(t, watcher.Reload())
+	assert.False(t, watcher.changed())
+
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.True(t, watcher.changed())
+}
@@ -0,0 +1,70 @@
+package config
+
+import "time"
+
+// RetryPolicy describes how failed messages of the pipeline stage are retried,
+// when all attempts are exhausted, the message goes to the dead-letter queue.
+type RetryPolicy struct {
+
+	// MaxAttempts is a total number of attempts, including the first one.
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// Backoff is a delay before the first retry, it's doubled
+	// with every next attempt, but not more than MaxBackoff.
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+
+	// Jitter is a fraction of the delay, which is randomly added or
+	// subtracted, so retries of many messages don't happen at once.
+	Jitter float64 `yaml:"jitter"`
+}
+
+// withDefaults fills the missing fields from the default policy.
+func (p RetryPolicy) withDefaults(def RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+
+	if p.Backoff == 0 {
+		p.Backoff = def.Backoff
+	}
+
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = def.MaxBackoff
+	}
+
+	if p.Jitter == 0 {
+		p.Jitter = def.Jitter
+	}
+
+	return p
+}
+
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     time.Second,
+	MaxBackoff:  5 * time.Minute,
+	Jitter:      0.2,
+}
+
+// Retries are the retry policies of the pipeline stages, configured like:
+//
+//	pipeline:
+//	  retries:
+//	    default:
+//	      max_attempts: 5
+//	      backoff: 1s
+//	    stages:
+//	      summarizer:
+//	        max_attempts: 3
+type Retries struct {
+	Default RetryPolicy            `yaml:"default"`
+	Stages  map[string]RetryPolicy `yaml:"stages"`
+}
+
+// For returns the policy of the stage, missing fields are
+// taken from the default policy.
+func (r *Retries) For(stage string) RetryPolicy {
+	def := r.Default.withDefaults(defaultRetryPolicy)
+	return r.Stages[stage].withDefaults(def)
+}
@@ -0,0 +1,39 @@
+package config
+
+// Route describes a destination for the summaries and
+// the rules, which summaries should be delivered there.
+type Route struct {
+
+	// Name is used for distinguishing routes in logs and errors,
+	// sender name is used when empty.
+	Name string `yaml:"name"`
+
+	// Sender is one of: tg, slack, discord, webhook, email, digest.
+	Sender string `yaml:"sender"`
+
+	// Verdicts limits delivered summaries by their verdict, one of:
+	// offer, interview, test_task, reject, unknown.
+	// All summaries are delivered when empty.
+	Verdicts []string `yaml:"verdicts"`
+
+	// ChatID overrides the Telegram chat, used only by tg sender.
+	ChatID int64 `yaml:"chat_id"`
+}
+
+type Routing struct {
+	Routes []Route `yaml:"routes"`
+}
+
+// withDefaults delivers everything to the sender from app config,
+// when no routes are provided.
+func (r *Routing) withDefaults(appConfig *AppConfig) {
+	if len(r.Routes) == 0 {
+		r.Routes = []Route{{Sender: appConfig.Sender}}
+	}
+
+	for i := range r.Routes {
+		if r.Routes[i].Name == "" {
+			r.Routes[i].Name = r.Routes[i].Sender
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/fadyat/i4u/internal/secret"
+	"net/http"
+	"os"
+	"time"
+)
+
+// vaultTimeout limits reading all secrets from the Vault.
+const vaultTimeout = 10 * time.Second
+
+// secretField is a value, which may be read from the file or from the
+// secret backend, see Config.readSecrets.
+type secretField struct {
+	name  string
+	env   string
+	value *string
+}
+
+// secretFields are the values, which shouldn't be kept in the config
+// file and the environment as is.
+func (c *Config) secretFields() []secretField {
+	return []secretField{
+		{"gmail.token_passphrase", "GMAIL_TOKEN_PASSPHRASE", &c.Gmail.TokenPassphrase},
+		{"gpt.openai_key", "OPENAI_KEY", &c.GPT.OpenAIKey},
+		{"telegram.token", "TG_TOKEN", &c.Telegram.Token},
+		{"senders.slack.webhook_url", "SLACK_WEBHOOK_URL", &c.Senders.Slack.WebhookURL},
+		{"senders.slack.token", "SLACK_TOKEN", &c.Senders.Slack.Token},
+		{"senders.discord.webhook_url", "DISCORD_WEBHOOK_URL", &c.Senders.Discord.WebhookURL},
+		{"senders.webhook.secret", "WEBHOOK_SECRET", &c.Senders.Webhook.Secret},
+		{"senders.smtp.password", "SMTP_PASSWORD", &c.Senders.SMTP.Password},
+		{"app.admin_token", "APP_ADMIN_TOKEN", &c.App.AdminToken},
+	}
+}
+
+// readSecrets replaces the secrets with the content of the files from the
+// environment variables with _FILE suffix, or with the values, which the
+// references point to, like file:/run/secrets/tg_token or vault:i4u#tg_token.
+func (c *Config) readSecrets() error {
+	ctx, cancel := context.WithTimeout(context.Background(), vaultTimeout)
+	defer cancel()
+
+	// the token of the vault can't be kept in the vault itself.
+	resolver := secret.NewResolver()
+	if err := (secretField{"vault.token", "VAULT_TOKEN", &c.Vault.Token}).read(ctx, resolver); err != nil {
+		return err
+	}
+
+	if c.Vault.Addr != "" {
+		resolver.WithVault(secret.NewVault(http.DefaultClient, c.Vault.Addr, c.Vault.Token, c.Vault.Mount))
+	}
+
+	for _, f := range c.secretFields() {
+		if err := f.read(ctx, resolver); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f secretField) read(ctx context.Context, r *secret.Resolver) error {
+	fileEnv := f.env + "_FILE"
+	if path, ok := os.LookupEnv(fileEnv); ok {
+		if _, set := os.LookupEnv(f.env); set {
+			return fmt.Errorf("%s: either %s or %s must be set, not both", f.name, f.env, fileEnv)
+		}
+
+		value, err := secret.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+
+		*f.value = value
+		return nil
+	}
+
+	value, err := r.Resolve(ctx, *f.value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.name, err)
+	}
+
+	*f.value = value
+	return nil
+}
@@ -0,0 +1,30 @@
+package config
+
+// Senders groups configs of the optional destinations for the summaries,
+// Telegram is configured separately, because it's used for alerts too.
+type Senders struct {
+	Slack   *Slack   `yaml:"slack"`
+	Discord *Discord `yaml:"discord"`
+	Webhook *Webhook `yaml:"webhook"`
+	SMTP    *SMTP    `yaml:"smtp"`
+}
+
+// withDefaults creates the configs of the senders, which
+// aren't given in the file, so they're read from the environment.
+func (s *Senders) withDefaults() {
+	if s.Slack == nil {
+		s.Slack = &Slack{}
+	}
+
+	if s.Discord == nil {
+		s.Discord = &Discord{}
+	}
+
+	if s.Webhook == nil {
+		s.Webhook = &Webhook{}
+	}
+
+	if s.SMTP == nil {
+		s.SMTP = &SMTP{}
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+type Slack struct {
+
+	// WebhookURL is an incoming webhook, the simplest way to post messages,
+	// channel is chosen when the webhook is created.
+	//
+	// https://api.slack.com/messaging/webhooks
+	WebhookURL string `yaml:"webhook_url" env:"SLACK_WEBHOOK_URL" env-description:"Slack incoming webhook URL"`
+
+	// Token is a bot token, used for posting via chat.postMessage, when
+	// the webhook isn't provided. Requires the Channel to be set.
+	//
+	// https://api.slack.com/methods/chat.postMessage
+	Token   string `yaml:"token" env:"SLACK_TOKEN" env-description:"Slack bot token"`
+	Channel string `yaml:"channel" env:"SLACK_CHANNEL" env-description:"Slack channel ID for chat.postMessage"`
+
+	// APIURL is a base url of the Slack Web API, can be replaced for testing.
+	APIURL string `yaml:"api_url" env:"SLACK_API_URL" env-description:"Slack Web API base url" env-default:"https://slack.com/api"`
+}
+
+// IsEnabled reports whether any of the delivery methods is configured.
+func (s *Slack) IsEnabled() bool {
+	return s.WebhookURL != "" || s.Token != ""
+}
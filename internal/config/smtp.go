@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+type SMTP struct {
+	Host     string `yaml:"host" env:"SMTP_HOST" env-description:"SMTP server host"`
+	Port     int    `yaml:"port" env:"SMTP_PORT" env-description:"SMTP server port" env-default:"587"`
+	Username string `yaml:"username" env:"SMTP_USERNAME" env-description:"SMTP username, auth is skipped when empty"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" env-description:"SMTP password"`
+
+	From string   `yaml:"from" env:"SMTP_FROM" env-description:"Sender address"`
+	To   []string `yaml:"to" env:"SMTP_TO" env-description:"Comma separated list of recipients"`
+
+	// DigestAt is a local time of the day, when the collected summaries
+	// will be sent as a single email, format: "15:04".
+	DigestAt string `yaml:"digest_at" env:"SMTP_DIGEST_AT" env-description:"Time of the daily digest" env-default:"18:00"`
+}
+
+// Addr returns the address of the SMTP server in the host:port format.
+func (s *SMTP) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// DigestTime parses DigestAt and returns the offset from the start of the day.
+func (s *SMTP) DigestTime() (time.Duration, error) {
+	t, err := time.Parse("15:04", s.DigestAt)
+	if err != nil {
+		return 0, fmt.Errorf("invalid digest time %q: %w", s.DigestAt, err)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -1,18 +1,14 @@
 package config
 
-import "github.com/ilyakaznacheev/cleanenv"
-
 type Telegram struct {
-	ChatID       int64  `env:"TG_CHAT_ID" env-description:"Telegram chat ID" env-required:"true"`
-	AlertsChatID int64  `env:"TG_ALERTS_CHAT_ID" env-description:"Telegram alerts chat ID" env-required:"true"`
-	Token        string `env:"TG_TOKEN" env-description:"Telegram bot token" env-required:"true"`
-}
-
-func NewTelegram() (*Telegram, error) {
-	var c Telegram
-	if err := cleanenv.ReadEnv(&c); err != nil {
-		return nil, err
-	}
-
-	return &c, nil
+	ChatID       int64  `yaml:"chat_id" env:"TG_CHAT_ID" env-description:"Telegram chat ID"`
+	AlertsChatID int64  `yaml:"alerts_chat_id" env:"TG_ALERTS_CHAT_ID" env-description:"Telegram alerts chat ID"`
+	Token        string `yaml:"token" env:"TG_TOKEN" env-description:"Telegram bot token"`
+
+	// CardMode defines what happens, when the application, which was already
+	// sent to the chat, gets a new summary, one of:
+	//  - edit: the existing message is edited in place
+	//  - reply: a new message is sent as a reply to the first one
+	//  - off: a new unrelated message is sent
+	CardMode string `yaml:"card_mode" env:"TG_CARD_MODE" env-description:"How application updates are shown" env-default:"edit"`
 }
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+)
+
+// finalKind is the kind of the final stage, which labels the message as processed.
+const finalKind = "labeler"
+
+// Topology describes the stages of the pipeline and how the messages flow
+// between them, configured like:
+//
+//	pipeline:
+//	  topology:
+//	    entry: [analyzer]
+//	    final: labeler
+//	    stages:
+//	      - name: analyzer
+//	        next: [summarizer, labeler]
+//	      - name: summarizer
+//	        next:
+//	          - stage: sender
+//	            verdicts: [offer, interview]
+//	      - name: labeler
+//	      - name: sender
+//
+// Fetched messages go to the entry stages, the final stage receives the
+// fetched message, when all messages derived from it are processed; it
+// must be a labeler, so the processed message isn't fetched again.
+type Topology struct {
+	Entry  []string    `yaml:"entry"`
+	Final  string      `yaml:"final"`
+	Stages []StageSpec `yaml:"stages"`
+}
+
+// StageSpec is a single stage of the topology.
+type StageSpec struct {
+	// Name identifies the stage in the edges, queues, metrics and
+	// the rest of the pipeline config, like retries and timeouts.
+	Name string `yaml:"name"`
+
+	// Kind is an implementation of the stage, one of: analyzer, summarizer,
+	// labeler, sender, subject, redact, exec or the custom registered one;
+	// the name is used when empty.
+	Kind string `yaml:"kind"`
+
+	// Options are passed to the custom stages, like the command of exec.
+	Options map[string]string `yaml:"options"`
+
+	// Next are the stages, which receive the result of this one.
+	Next []Edge `yaml:"next"`
+}
+
+// Edge passes the result of the stage to the next one, the edge
+// can be given by the name of the next stage only.
+type Edge struct {
+	Stage string `yaml:"stage"`
+
+	// Verdicts limits passed messages to the summaries with the
+	// given verdicts, everything is passed when empty.
+	Verdicts []string `yaml:"verdicts"`
+}
+
+func (e *Edge) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&e.Stage)
+	}
+
+	type plain Edge
+	return value.Decode((*plain)(e))
+}
+
+// DefaultTopology analyzes the fetched messages, internship requests are
+// summarized and sent, all messages are labeled with the analysis result.
+func DefaultTopology() Topology {
+	return Topology{
+		Entry: []string{"analyzer"},
+		Final: "labeler",
+		Stages: []StageSpec{
+			{Name: "analyzer", Next: []Edge{{Stage: "summarizer"}, {Stage: "labeler"}}},
+			{Name: "summarizer", Next: []Edge{{Stage: "sender"}}},
+			{Name: "labeler"},
+			{Name: "sender"},
+		},
+	}
+}
+
+// Names returns the names of all stages.
+func (t *Topology) Names() []string {
+	names := make([]string, 0, len(t.Stages))
+	for _, s := range t.Stages {
+		names = append(names, s.Name)
+	}
+
+	return names
+}
+
+func (t *Topology) withDefaults() {
+	if len(t.Stages) == 0 {
+		*t = DefaultTopology()
+	}
+
+	for i := range t.Stages {
+		if t.Stages[i].Kind == "" {
+			t.Stages[i].Kind = t.Stages[i].Name
+		}
+	}
+
+	if len(t.Entry) == 0 {
+		t.Entry = []string{t.Stages[0].Name}
+	}
+
+	if t.Final != "" {
+		return
+	}
+
+	for _, s := range t.Stages {
+		if s.Kind == finalKind {
+			t.Final = s.Name
+			return
+		}
+	}
+}
+
+// Validate checks, that all referenced stages exist,
+// and the messages can't go around in circles.
+func (t *Topology) Validate() error {
+	stages := make(map[string]*StageSpec, len(t.Stages))
+	for i := range t.Stages {
+		s := &t.Stages[i]
+		switch {
+		case s.Name == "":
+			return fmt.Errorf("stage %d: name is required", i)
+		case s.Name == "fetcher":
+			return errors.New("stage fetcher: name is reserved")
+		case stages[s.Name] != nil:
+			return fmt.Errorf("stage %s: duplicate name", s.Name)
+		}
+
+		stages[s.Name] = s
+	}
+
+	for _, s := range t.Stages {
+		for _, e := range s.Next {
+			if stages[e.Stage] == nil {
+				return fmt.Errorf("stage %s: unknown next stage: %s", s.Name, e.Stage)
+			}
+		}
+	}
+
+	if len(t.Entry) == 0 {
+		return errors.New("no entry stages")
+	}
+
+	for _, name := range t.Entry {
+		if stages[name] == nil {
+			return fmt.Errorf("unknown entry stage: %s", name)
+		}
+	}
+
+	switch final := stages[t.Final]; {
+	case t.Final == "":
+		return fmt.Errorf("no final stage, add a stage of kind %s", finalKind)
+	case final == nil:
+		return fmt.Errorf("unknown final stage: %s", t.Final)
+	case final.Kind != finalKind:
+		return fmt.Errorf("final stage %s: must be of kind %s, got %s", t.Final, finalKind, final.Kind)
+	}
+
+	// depth-first search, the stage on the current path means a cycle.
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	marks := make(map[string]int, len(stages))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case visiting:
+			return fmt.Errorf("stage %s: cycle in the pipeline", name)
+		case visited:
+			return nil
+		}
+
+		marks[name] = visiting
+		for _, e := range stages[name].Next {
+			if err := visit(e.Stage); err != nil {
+				return err
+			}
+		}
+
+		marks[name] = visited
+		return nil
+	}
+
+	for _, s := range t.Stages {
+		if err := visit(s.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
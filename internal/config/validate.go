@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	"os"
+	"regexp"
+)
+
+var (
+	// tgTokenRe matches the tokens of the bots, like "123456:ABC-DEF1234".
+	tgTokenRe = regexp.MustCompile(`^\d+:[\w-]+$`)
+
+	// labelIDRe matches the ids of the user labels, like "Label_9",
+	// and the system ones, like "INBOX".
+	labelIDRe = regexp.MustCompile(`^(Label_\d+|[A-Z_]+)$`)
+)
+
+// Validate checks the config needed for running the pipeline, all
+// problems are reported at once, so they can be fixed in one go.
+func (c *Config) Validate() error {
+	v := validator{}
+
+	v.check(isFile(c.Gmail.CredentialsFile), "gmail.credentials_file: %s isn't found", c.Gmail.CredentialsFile)
+	v.check(c.Gmail.MessagesLimit > 0, "gmail.messages_limit: must be positive")
+	v.check(c.Gmail.TokenPassphrase == "" || c.Gmail.TokenKeyFile == "", "gmail.token_key_file: can't be used with token_passphrase")
+	v.check(c.Gmail.TokenKeyFile == "" || isFile(c.Gmail.TokenKeyFile), "gmail.token_key_file: %s isn't found", c.Gmail.TokenKeyFile)
+	c.validateAccounts(&v)
+
+	v.check(c.GPT.OpenAIKey != "", "gpt.openai_key: is required")
+	v.check(c.GPT.MaxTokens > 0, "gpt.max_tokens: must be positive")
+
+	v.check(tgTokenRe.MatchString(c.Telegram.Token), "telegram.token: invalid bot token")
+	if len(c.Accounts) == 0 {
+		v.check(c.Telegram.ChatID != 0, "telegram.chat_id: is required")
+	}
+
+	v.check(c.Telegram.AlertsChatID != 0, "telegram.alerts_chat_id: is required")
+	switch c.Telegram.CardMode {
+	case "edit", "reply", "off":
+	default:
+		v.fail("telegram.card_mode: unknown mode %q", c.Telegram.CardMode)
+	}
+
+	v.check(len(c.App.Keywords) > 0, "app.keywords: at least one is required")
+	v.check(!isDir(c.App.StoragePath), "app.storage_path: %s is a directory", c.App.StoragePath)
+	v.check(!isFile(c.App.TemplatesDir), "app.templates_dir: %s isn't a directory", c.App.TemplatesDir)
+
+	for i, r := range c.Routes {
+		for _, verdict := range r.Verdicts {
+			v.check(entity.Verdict(verdict).IsValid(), "routes[%d]: unknown verdict %q", i, verdict)
+		}
+
+		if err := c.Senders.validate(r.Sender); err != nil {
+			v.fail("routes[%d]: %s", i, err)
+		}
+	}
+
+	if err := c.Pipeline.Topology.Validate(); err != nil {
+		v.fail("pipeline.topology: %s", err)
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// validate checks, that the sender is configured.
+func (s *Senders) validate(sender string) error {
+	switch sender {
+	case "tg":
+		return nil
+	case "slack":
+		if !s.Slack.IsEnabled() {
+			return errors.New("senders.slack: neither webhook_url nor token is provided")
+		}
+
+		if s.Slack.WebhookURL == "" && s.Slack.Channel == "" {
+			return errors.New("senders.slack: channel is required with the token")
+		}
+	case "discord":
+		if s.Discord.WebhookURL == "" {
+			return errors.New("senders.discord: webhook_url is required")
+		}
+	case "webhook":
+		if s.Webhook.URL == "" {
+			return errors.New("senders.webhook: url is required")
+		}
+	case "email", "digest":
+		if s.SMTP.Host == "" || len(s.SMTP.To) == 0 {
+			return errors.New("senders.smtp: host and recipients are required")
+		}
+
+		if _, err := s.SMTP.DigestTime(); err != nil && sender == "digest" {
+			return fmt.Errorf("senders.smtp: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown sender %q", sender)
+	}
+
+	return nil
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.fail(format, args...)
+	}
+}
+
+// labels checks the labels, written by the setup command.
+func (v *validator) labels(field string, l *LabelsMapper, setup string) {
+	if l == nil {
+		v.fail("%s: not found, run %s first", field, setup)
+		return
+	}
+
+	labels := []struct{ name, id string }{
+		{"i4u", l.I4U}, {"intern:true", l.IsIntern}, {"intern:false", l.NotIntern},
+	}
+
+	for _, label := range labels {
+		v.check(labelIDRe.MatchString(label.id), "%s.%s: invalid label id %q, run %s again", field, label.name, label.id, setup)
+	}
+}
+
+func (v *validator) fail(format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,9 @@
+package config
+
+// Vault is a HashiCorp Vault compatible backend of the secrets, it's used
+// by the values like vault:i4u#openai_key, only the KV v2 engine is supported.
+type Vault struct {
+	Addr  string `yaml:"addr" env:"VAULT_ADDR" env-description:"Vault address"`
+	Token string `yaml:"token" env:"VAULT_TOKEN" env-description:"Vault token"`
+	Mount string `yaml:"mount" env:"VAULT_MOUNT" env-description:"Mount path of the KV engine" env-default:"secret"`
+}
@@ -0,0 +1,15 @@
+package config
+
+type Webhook struct {
+
+	// URL is an endpoint, which will receive summaries via POST requests.
+	URL string `yaml:"url" env:"WEBHOOK_URL" env-description:"Generic webhook URL"`
+
+	// Headers are added to each request, useful for authentication,
+	// format: "Authorization:Bearer token,X-Source:i4u".
+	Headers map[string]string `yaml:"headers" env:"WEBHOOK_HEADERS" env-description:"Additional request headers"`
+
+	// Secret is used for signing the request body with HMAC-SHA256,
+	// signature isn't added when the secret is empty.
+	Secret string `yaml:"secret" env:"WEBHOOK_SECRET" env-description:"Secret for HMAC signature"`
+}
@@ -1,8 +1,10 @@
 package entity
 
 import (
+	"encoding/json"
 	"github.com/fadyat/i4u/pkg/parser"
 	"google.golang.org/api/gmail/v1"
+	"strings"
 )
 
 type MessageForLabeler interface {
@@ -33,6 +35,9 @@ type Msg struct {
 	// Usable for getting short description of the message.
 	body string
 
+	// subject is a subject of the email, empty when it's missing.
+	subject string
+
 	// isInternshipRequest is a flag that indicates
 	// whether the message is related to internship request.
 	//
@@ -46,16 +51,28 @@ type Msg struct {
 	// For another cases, like, second labeling, you can provide custom value.
 	label string
 
+	// threadID is an identifier of the conversation, the message belongs to,
+	// in case of gmail it is a gmail thread id, empty when it's unknown.
+	threadID string
+
 	// link is a fast-access link to the message.
 	//
 	// For gmail, it's done via `https://mail.google.com/mail/u/0/#inbox/` + id.
 	link string
+
+	// traceParent is a W3C trace context of the message, all stages
+	// continue the trace started, when the message was fetched.
+	traceParent string
 }
 
 func (m *Msg) Body() string {
 	return m.body
 }
 
+func (m *Msg) Subject() string {
+	return m.subject
+}
+
 func (m *Msg) IsInternshipRequest() bool {
 	return m.isInternshipRequest
 }
@@ -64,13 +81,20 @@ func (m *Msg) Link() string {
 	return m.link
 }
 
+func (m *Msg) ThreadID() string {
+	return m.threadID
+}
+
 func (m *Msg) Copy() *Msg {
 	return &Msg{
 		id:                  m.id,
 		body:                m.body,
+		subject:             m.subject,
 		isInternshipRequest: m.isInternshipRequest,
 		label:               m.label,
+		threadID:            m.threadID,
 		link:                m.link,
+		traceParent:         m.traceParent,
 	}
 }
 
@@ -79,21 +103,57 @@ func (m *Msg) WithLabel(v string) *Msg {
 	return m
 }
 
+func (m *Msg) WithBody(v string) *Msg {
+	m.body = v
+	return m
+}
+
+func (m *Msg) WithSubject(v string) *Msg {
+	m.subject = v
+	return m
+}
+
 func (m *Msg) WithIsIntern(v bool) *Msg {
 	m.isInternshipRequest = v
 	return m
 }
 
+func (m *Msg) WithThreadID(v string) *Msg {
+	m.threadID = v
+	return m
+}
+
+func (m *Msg) WithTraceParent(v string) *Msg {
+	m.traceParent = v
+	return m
+}
+
+func (m *Msg) TraceParent() string {
+	return m.traceParent
+}
+
 func NewMsgFromGmailMessage(msg *gmail.Message) (*Msg, error) {
 	content, err := parser.CleanMsg(msg, parser.PlainText)
 	if err != nil {
 		return nil, err
 	}
 
+	var subject string
+	if msg.Payload != nil {
+		for _, h := range msg.Payload.Headers {
+			if strings.EqualFold(h.Name, "Subject") {
+				subject = h.Value
+				break
+			}
+		}
+	}
+
 	return &Msg{
 		id:                  msg.Id,
 		body:                content,
+		subject:             subject,
 		isInternshipRequest: false,
+		threadID:            msg.ThreadId,
 		link:                "https://mail.google.com/mail/u/0/#inbox/" + msg.Id,
 	}, nil
 }
@@ -117,3 +177,49 @@ func (m *Msg) ID() string {
 func (m *Msg) Label() string {
 	return m.label
 }
+
+// msgJSON is a serialized form of the message, used for
+// keeping the message in the persistent queue.
+type msgJSON struct {
+	ID                  string `json:"id"`
+	Body                string `json:"body"`
+	Subject             string `json:"subject,omitempty"`
+	IsInternshipRequest bool   `json:"is_internship_request"`
+	Label               string `json:"label,omitempty"`
+	ThreadID            string `json:"thread_id,omitempty"`
+	Link                string `json:"link,omitempty"`
+	TraceParent         string `json:"trace_parent,omitempty"`
+}
+
+func (m *Msg) MarshalJSON() ([]byte, error) {
+	return json.Marshal(msgJSON{
+		ID:                  m.id,
+		Body:                m.body,
+		Subject:             m.subject,
+		IsInternshipRequest: m.isInternshipRequest,
+		Label:               m.label,
+		ThreadID:            m.threadID,
+		Link:                m.link,
+		TraceParent:         m.traceParent,
+	})
+}
+
+func (m *Msg) UnmarshalJSON(data []byte) error {
+	var v msgJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*m = Msg{
+		id:                  v.ID,
+		body:                v.Body,
+		subject:             v.Subject,
+		isInternshipRequest: v.IsInternshipRequest,
+		label:               v.Label,
+		threadID:            v.ThreadID,
+		link:                v.Link,
+		traceParent:         v.TraceParent,
+	}
+
+	return nil
+}
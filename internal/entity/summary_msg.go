@@ -31,3 +31,37 @@ func NewSummaryMsg(msg Message, summary string) *SummaryMsg {
 func (s *SummaryMsg) Summary() string {
 	return s.summary + "\n\n" + s.Message.Link()
 }
+
+// TraceParent returns the trace context of the summarized message.
+func (s *SummaryMsg) TraceParent() string {
+	if traced, ok := s.Message.(interface{ TraceParent() string }); ok {
+		return traced.TraceParent()
+	}
+
+	return ""
+}
+
+// ThreadID returns the conversation of the summarized message.
+func (s *SummaryMsg) ThreadID() string {
+	if threaded, ok := s.Message.(interface{ ThreadID() string }); ok {
+		return threaded.ThreadID()
+	}
+
+	return ""
+}
+
+// Text returns the summary as it was returned by the summarizer,
+// without any additional decorations.
+func (s *SummaryMsg) Text() string {
+	return s.summary
+}
+
+// Fields returns the structured representation of the summary.
+func (s *SummaryMsg) Fields() SummaryFields {
+	return ParseSummaryFields(s.summary)
+}
+
+// Verdict returns the normalized verdict of the summary.
+func (s *SummaryMsg) Verdict() Verdict {
+	return ParseVerdict(s.Fields().Verdict)
+}
@@ -0,0 +1,200 @@
+package entity
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Verdict is a normalized outcome of the internship request,
+// parsed from the free-form summary returned by the summarizer.
+type Verdict string
+
+const (
+	VerdictUnknown   Verdict = "unknown"
+	VerdictOffer     Verdict = "offer"
+	VerdictInterview Verdict = "interview"
+	VerdictTestTask  Verdict = "test_task"
+	VerdictReject    Verdict = "reject"
+)
+
+// Verdicts is a list of all known verdicts.
+var Verdicts = []Verdict{
+	VerdictOffer, VerdictInterview, VerdictTestTask, VerdictReject, VerdictUnknown,
+}
+
+func (v Verdict) IsValid() bool {
+	for _, known := range Verdicts {
+		if v == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verdictKeywords is ordered, because some of the summaries contain
+// several keywords at once, like "reject, no offer for now".
+//
+// Keywords are matched from the beginning of the words, like "reject"
+// matches "rejected", but "call" doesn't match "recall". The negated
+// keyword, like "no offer", means the negated verdict, it's skipped,
+// when the negated verdict is empty, like "no test task".
+var verdictKeywords = []struct {
+	verdict  Verdict
+	negated  Verdict
+	keywords []string
+}{
+	{VerdictReject, VerdictReject, []string{"reject", "declin", "not progress", "unfortunately", "not moving forward"}},
+	{VerdictOffer, VerdictReject, []string{"offer", "congratulat"}},
+	{VerdictTestTask, "", []string{"test task", "assignment", "assessment", "coding challenge", "home task"}},
+	{VerdictInterview, "", []string{"interview", "call", "next stage", "next step"}},
+}
+
+// negations are the words, which negate the keyword after them,
+// like "can't offer", up to negationWindow words before it.
+var negations = map[string]bool{
+	"no": true, "not": true, "cannot": true, "can't": true, "cant": true,
+	"won't": true, "unable": true, "don't": true, "didn't": true,
+}
+
+const negationWindow = 3
+
+// ParseVerdict maps the free-form verdict text to the known Verdict.
+func ParseVerdict(s string) Verdict {
+	words := verdictWords(s)
+	for _, v := range verdictKeywords {
+		for _, kw := range v.keywords {
+			i := indexKeyword(words, strings.Fields(kw))
+			if i < 0 {
+				continue
+			}
+
+			if !negated(words, i) {
+				return v.verdict
+			}
+
+			if v.negated != "" {
+				return v.negated
+			}
+		}
+	}
+
+	return VerdictUnknown
+}
+
+// verdictWords splits the text into lowercase words, the apostrophes
+// are kept, so the negations like "can't" are single words.
+func verdictWords(s string) []string {
+	s = strings.ToLower(strings.ReplaceAll(s, "’", "'"))
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+// indexKeyword returns the index of the first word of the keyword,
+// each word of the keyword matches the beginning of the word.
+func indexKeyword(words, keyword []string) int {
+	for i := 0; i+len(keyword) <= len(words); i++ {
+		matched := true
+		for j, kw := range keyword {
+			if !strings.HasPrefix(words[i+j], kw) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func negated(words []string, i int) bool {
+	for j := max(0, i-negationWindow); j < i; j++ {
+		if negations[words[j]] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SummaryFields is a structured representation of the summary,
+// the summarizer is asked to respond in the format like:
+//
+//	🏢 Company: TikTok
+//	📝 Vacancy: Software Engineer Working Student, 2023 start
+//	⛔ Verdict: Reject
+//	🔎 Reason: Not progressing the application at this time
+//
+// Missing fields are left empty.
+type SummaryFields struct {
+	Company string
+	Vacancy string
+	Verdict string
+	Reason  string
+}
+
+// SummaryLine is a single line of the summary, Key is a normalized name
+// of the field, like "company", it's empty for lines in the unknown format.
+type SummaryLine struct {
+	Key   string
+	Value string
+	Raw   string
+}
+
+// ParseSummaryLines splits the summary into lines, recognizing the fields.
+func ParseSummaryLines(summary string) []SummaryLine {
+	raw := strings.Split(strings.TrimSpace(summary), "\n")
+	lines := make([]SummaryLine, 0, len(raw))
+
+	for _, line := range raw {
+		l := SummaryLine{Raw: line}
+
+		key, value, ok := strings.Cut(line, ":")
+		if ok {
+			// dropping emojis and other decorations before the key.
+			key = strings.ToLower(strings.TrimLeftFunc(key, func(r rune) bool {
+				return !unicode.IsLetter(r)
+			}))
+
+			l.Key, l.Value = summaryKeys[strings.TrimSpace(key)], strings.TrimSpace(value)
+		}
+
+		lines = append(lines, l)
+	}
+
+	return lines
+}
+
+var summaryKeys = map[string]string{
+	"company":  "company",
+	"vacancy":  "vacancy",
+	"position": "vacancy",
+	"role":     "vacancy",
+	"verdict":  "verdict",
+	"status":   "verdict",
+	"reason":   "reason",
+}
+
+// ParseSummaryFields extracts known fields from the summary,
+// lines in the unknown format are ignored.
+func ParseSummaryFields(summary string) SummaryFields {
+	var f SummaryFields
+	for _, l := range ParseSummaryLines(summary) {
+		switch l.Key {
+		case "company":
+			f.Company = l.Value
+		case "vacancy":
+			f.Vacancy = l.Value
+		case "verdict":
+			f.Verdict = l.Value
+		case "reason":
+			f.Reason = l.Value
+		}
+	}
+
+	return f
+}
@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseVerdict(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected Verdict
+	}{
+		{in: "Offer", expected: VerdictOffer},
+		{in: "Congratulations, you've been accepted", expected: VerdictOffer},
+		{in: "Rejected, no offer for now", expected: VerdictReject},
+		{in: "No offer", expected: VerdictReject},
+		{in: "We can't offer you a position", expected: VerdictReject},
+		{in: "We can’t offer you a position", expected: VerdictReject},
+		{in: "Your application was accepted for review", expected: VerdictUnknown},
+		{in: "Invitation to the interview", expected: VerdictInterview},
+		{in: "Interview, no test task", expected: VerdictInterview},
+		{in: "Phone call next week", expected: VerdictInterview},
+		{in: "Please recall your password", expected: VerdictUnknown},
+		{in: "Team meeting notes", expected: VerdictUnknown},
+		{in: "Coding challenge", expected: VerdictTestTask},
+		{in: "Not progressing the application at this time", expected: VerdictReject},
+		{in: "", expected: VerdictUnknown},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, ParseVerdict(tc.in))
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"github.com/fadyat/i4u/api/ratelimit"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/metrics"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/fadyat/i4u/internal/tracing"
+	"github.com/fadyat/i4u/pkg/syncs"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Names of the pipeline stages, used as names of the persistent queues,
+// the fetcher isn't backed by the queue, but has its own timeout.
+const (
+	StageFetcher    = "fetcher"
+	StageLabeler    = "labeler"
+	StageAnalyzer   = "analyzer"
+	StageSummarizer = "summarizer"
+	StageSender     = "sender"
+)
+
+// stageFunc processes a single message of the stage, the returned message
+// is passed to the next stages, nil means there is nothing to pass further.
+type stageFunc func(ctx context.Context, msg entity.Message) (entity.Message, error)
+
+// fanoutFunc is the stageFunc, which passes any number of messages further.
+type fanoutFunc func(ctx context.Context, msg entity.Message) ([]entity.Message, error)
+
+func (fn stageFunc) fanout() fanoutFunc {
+	return func(ctx context.Context, msg entity.Message) ([]entity.Message, error) {
+		result, err := fn(ctx, msg)
+		if result == nil {
+			return nil, err
+		}
+
+		return []entity.Message{result}, err
+	}
+}
+
+// queueStore is the part of the storage, which keeps the queues of the stages.
+type queueStore interface {
+	Queued(stage string) ([]storage.QueueItem, error)
+	QueueLen(stage string) (int, error)
+	Advance(stage, key string, results ...storage.Forward) (bool, error)
+	Postpone(stage, key string, attempts int, next time.Time, lastErr string) error
+	DeadLetter(stage, key string, attempts int, lastErr string) (bool, error)
+}
+
+// durableStage processes messages from the persistent queue of the stage.
+//
+// The message is removed from the queue only after it was processed
+// successfully, in the same transaction its result is enqueued to the
+// next stages, so the message is delivered at least once, even when
+// the process dies in the middle of the pipeline.
+//
+// Failed messages are retried according to the policy, with growing delays,
+// after all attempts, or when the error is permanent, the message goes to the
+// dead-letter queue. Messages, which were in flight during the shutdown,
+// are processed again after the restart.
+//
+// No more than workers messages are processed at once, the rest wait
+// in the queue, which holds back the ingestion, when it grows too long.
+type durableStage struct {
+	name  string
+	store queueStore
+	fn    fanoutFunc
+	cfg   config.Stage
+	next  []edge
+
+	// verdicts tells, that the stage produces the summaries,
+	// their verdicts are counted, when the message leaves the queue.
+	verdicts bool
+
+	// final is the stage, which receives the ingested message,
+	// when all messages derived from it are processed.
+	final *durableStage
+
+	errsCh chan<- error
+
+	// wakeup is signaled, when new messages are enqueued,
+	// or the worker is freed.
+	wakeup chan struct{}
+
+	// drained is signaled, when the message leaves the queue.
+	drained chan struct{}
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+
+	// retryTimer wakes up the stage, when the earliest postponed
+	// message is ready for the next attempt.
+	retryTimer *time.Timer
+}
+
+func newDurableStage(
+	name string,
+	store *storage.Storage,
+	fn stageFunc,
+	cfg config.Stage,
+	errsCh chan<- error,
+	next ...*durableStage,
+) *durableStage {
+	return newFanoutStage(name, store, fn.fanout(), cfg, errsCh, next...)
+}
+
+// newFanoutStage creates the stage, which may pass several messages further.
+func newFanoutStage(
+	name string,
+	store *storage.Storage,
+	fn fanoutFunc,
+	cfg config.Stage,
+	errsCh chan<- error,
+	next ...*durableStage,
+) *durableStage {
+	d := &durableStage{
+		name:     name,
+		store:    store,
+		fn:       observed(name, fn),
+		cfg:      cfg,
+		errsCh:   errsCh,
+		wakeup:   make(chan struct{}, 1),
+		drained:  make(chan struct{}, 1),
+		inFlight: make(map[string]bool),
+	}
+
+	for _, n := range next {
+		d.connect(n)
+	}
+
+	return d
+}
+
+// edge passes the results of the stage to the next one.
+type edge struct {
+	to *durableStage
+
+	// verdicts limit passed messages to the summaries with the
+	// given verdicts, everything is passed when empty.
+	verdicts []entity.Verdict
+}
+
+func (e *edge) matches(msg entity.Message) bool {
+	if len(e.verdicts) == 0 {
+		return true
+	}
+
+	summary, ok := msg.(*entity.SummaryMsg)
+	if !ok {
+		return false
+	}
+
+	verdict := summary.Verdict()
+	for _, v := range e.verdicts {
+		if v == verdict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// connect passes the results of the stage to the next one.
+func (d *durableStage) connect(to *durableStage, verdicts ...entity.Verdict) {
+	d.next = append(d.next, edge{to: to, verdicts: verdicts})
+}
+
+// signal sends to the channel without blocking, a single pending
+// signal is enough, because the receiver rereads the whole queue.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// notify wakes up the stage without blocking.
+func (d *durableStage) notify() {
+	signal(d.wakeup)
+}
+
+// accept waits, until the stage queue is shorter than the limit,
+// or the context is done.
+func (d *durableStage) accept(ctx context.Context, limit int) error {
+	for {
+		n, err := d.store.QueueLen(d.name)
+		if err != nil || n < limit {
+			return err
+		}
+
+		select {
+		case <-d.drained:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (d *durableStage) Run(ctx context.Context) {
+	var wg syncs.WaitGroup
+
+	// picking up the messages left from the previous run.
+	d.notify()
+
+	for {
+		select {
+		case <-d.wakeup:
+			d.dispatch(ctx, &wg)
+		case <-ctx.Done():
+			d.mu.Lock()
+			if d.retryTimer != nil {
+				d.retryTimer.Stop()
+			}
+			d.mu.Unlock()
+
+			wg.Wait()
+			return
+		}
+	}
+}
+
+func (d *durableStage) dispatch(ctx context.Context, wg *syncs.WaitGroup) {
+	items, err := d.store.Queued(d.name)
+	if err != nil {
+		d.errsCh <- storageError(d.name, "", fmt.Errorf("failed to read %s queue: %w", d.name, err))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var (
+		now      = time.Now()
+		earliest time.Time
+	)
+
+	for i := range items {
+		item := items[i]
+		if d.inFlight[item.Key] {
+			continue
+		}
+
+		// the rest is dispatched, when the worker is freed.
+		if len(d.inFlight) >= d.cfg.Workers {
+			break
+		}
+
+		if item.NextAttemptAt.After(now) {
+			if earliest.IsZero() || item.NextAttemptAt.Before(earliest) {
+				earliest = item.NextAttemptAt
+			}
+
+			continue
+		}
+
+		d.inFlight[item.Key] = true
+		wg.Go(func() {
+			d.process(ctx, &item)
+		})
+	}
+
+	if !earliest.IsZero() {
+		if d.retryTimer != nil {
+			d.retryTimer.Stop()
+		}
+
+		d.retryTimer = time.AfterFunc(earliest.Sub(now), d.notify)
+	}
+}
+
+func (d *durableStage) process(ctx context.Context, item *storage.QueueItem) {
+	var deadline time.Time
+	if d.cfg.Deadline > 0 && !item.IngestedAt.IsZero() {
+		deadline = item.IngestedAt.Add(d.cfg.Deadline)
+		if time.Now().After(deadline) {
+			err := Permanent(fmt.Errorf("message didn't pass the pipeline in %s", d.cfg.Deadline))
+			metrics.Errors.WithLabelValues(d.name, "deadline").Inc()
+			d.fail(ctx, item, err)
+			return
+		}
+	}
+
+	// the attempt can't outlive the deadline of the whole pipeline.
+	timeout, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	if !deadline.IsZero() {
+		var cancelDeadline context.CancelFunc
+		timeout, cancelDeadline = context.WithDeadline(timeout, deadline)
+		defer cancelDeadline()
+	}
+
+	results, err := d.fn(timeout, item.Message())
+	if err != nil {
+		d.fail(ctx, item, err)
+		return
+	}
+
+	var (
+		forwards = make([]storage.Forward, 0, len(results))
+		next     = make(map[*durableStage]bool, len(d.next))
+	)
+
+	for _, result := range results {
+		forward := storage.Forward{Msg: result}
+		for _, e := range d.next {
+			if e.matches(result) {
+				forward.Stages = append(forward.Stages, e.to.name)
+				next[e.to] = true
+			}
+		}
+
+		forwards = append(forwards, forward)
+	}
+
+	released, e := d.store.Advance(d.name, item.Key, forwards...)
+	if e != nil {
+		d.errsCh <- storageError(d.name, item.Msg.ID(), fmt.Errorf("failed to advance message %s from %s: %w", item.Msg.ID(), d.name, e))
+		d.releaseLater(item.Key)
+		return
+	}
+
+	if d.verdicts {
+		countVerdicts(results)
+	}
+
+	d.release(item.Key)
+	signal(d.drained)
+	for n := range next {
+		n.notify()
+	}
+
+	if released && d.final != nil {
+		d.final.notify()
+	}
+
+	zap.S().Debugf("message %s passed %s stage", item.Msg.ID(), d.name)
+}
+
+// fail postpones the message for the next attempt, or moves it to the
+// dead-letter queue, when the error can't be fixed by retrying.
+func (d *durableStage) fail(ctx context.Context, item *storage.QueueItem, err error) {
+	// the stage is shutting down, the message stays in flight
+	// and will be picked up after the restart.
+	if ctx.Err() != nil {
+		return
+	}
+
+	attempts := item.Attempts + 1
+	if !isRetryable(err) || attempts >= d.cfg.Retry.MaxAttempts {
+		released, e := d.store.DeadLetter(d.name, item.Key, attempts, err.Error())
+		if e != nil {
+			d.errsCh <- storageError(d.name, item.Msg.ID(), fmt.Errorf("failed to move message %s to dead-letter queue: %w", item.Msg.ID(), e))
+			d.releaseLater(item.Key)
+			return
+		}
+
+		// the end of the message trace, unless it's retried manually.
+		_, span := tracing.Start(tracing.WithMessage(context.Background(), item.Message()), "dead_letter",
+			trace.WithAttributes(tracing.AttrStage.String(d.name), tracing.AttrMessageID.String(item.Msg.ID())))
+		tracing.Fail(span, err)
+		span.End()
+
+		d.release(item.Key)
+		signal(d.drained)
+		if released && d.final != nil {
+			d.final.notify()
+		}
+
+		d.errsCh <- newPipelineError(d.name, item.Msg.ID(), fmt.Errorf(
+			"message %s failed at %s after %d attempt(s), moved to dead-letter queue: %w",
+			item.Msg.ID(), d.name, attempts, err,
+		))
+		return
+	}
+
+	// the provider knows better, when it's ready for the next call.
+	delay := backoff(d.cfg.Retry, attempts)
+	if retryAfter, ok := ratelimit.RetryAfter(err); ok && retryAfter > delay {
+		delay = retryAfter
+	}
+	if e := d.store.Postpone(d.name, item.Key, attempts, time.Now().Add(delay), err.Error()); e != nil {
+		d.errsCh <- storageError(d.name, item.Msg.ID(), fmt.Errorf("failed to postpone message %s: %w", item.Msg.ID(), e))
+		d.releaseLater(item.Key)
+		return
+	}
+
+	zap.S().Warnf("message %s failed at %s, retrying in %s: %s", item.Msg.ID(), d.name, delay, err)
+	d.release(item.Key)
+}
+
+func (d *durableStage) release(key string) {
+	d.mu.Lock()
+	delete(d.inFlight, key)
+	d.mu.Unlock()
+
+	d.notify()
+}
+
+// releaseLater frees the worker after the failure of the storage, the
+// message stays in the queue and is dispatched again after the delay,
+// so the broken storage isn't hammered by the same message.
+func (d *durableStage) releaseLater(key string) {
+	d.mu.Lock()
+	delete(d.inFlight, key)
+	d.mu.Unlock()
+
+	time.AfterFunc(backoff(d.cfg.Retry, 1), d.notify)
+}
+
+// ingest persists the messages from the source to the queues of the
+// first stages, until the source is closed. The message itself is passed
+// to the final stage only after the whole pipeline is completed, so it's
+// applied once and can't hide the message, which wasn't processed.
+//
+// Messages, which are already in the pipeline, are skipped, because
+// the source returns them again, until the final stage is done.
+//
+// New messages aren't accepted, while any of the stage queues has
+// maxQueued messages, so the source is held back by the slow stage.
+// After the context is done, the rest messages are persisted as is.
+func ingest(
+	ctx context.Context,
+	store *storage.Storage,
+	errsCh chan<- error,
+	in <-chan entity.Message,
+	maxQueued int,
+	final *durableStage,
+	stages ...*durableStage,
+) {
+	names := make([]string, 0, len(stages))
+	for _, s := range stages {
+		names = append(names, s.name)
+	}
+
+	for msg := range in {
+		for _, s := range stages {
+			if err := s.accept(ctx, maxQueued); err != nil {
+				errsCh <- storageError(s.name, "", fmt.Errorf("failed to read %s queue: %w", s.name, err))
+			}
+		}
+
+		ingested, err := store.Ingest(msg, final.name, names...)
+		if err != nil {
+			errsCh <- storageError(StageFetcher, msg.ID(), fmt.Errorf("failed to enqueue message %s: %w", msg.ID(), err))
+			continue
+		}
+
+		if !ingested {
+			continue
+		}
+
+		for _, s := range stages {
+			s.notify()
+		}
+	}
+}
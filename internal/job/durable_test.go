@@ -0,0 +1,563 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/fadyat/i4u/pkg/syncs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) *storage.Storage {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "i4u.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+var testStage = config.Stage{
+	Retry:   config.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	Workers: 4,
+	Timeout: time.Second,
+}
+
+// runStages runs the stages until the context is done.
+func runStages(ctx context.Context, stages ...*durableStage) *syncs.WaitGroup {
+	var wg syncs.WaitGroup
+	for _, s := range stages {
+		stage := s
+		wg.Go(func() { stage.Run(ctx) })
+	}
+
+	return &wg
+}
+
+func TestDurableStage_Run(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	var (
+		delivered = make(chan entity.Message, 10)
+		labeled   = make(chan entity.Message, 10)
+	)
+	final := newDurableStage(StageLabeler, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		labeled <- msg
+		return nil, nil
+	}, testStage, errsCh)
+	last := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		delivered <- msg
+		return nil, nil
+	}, testStage, errsCh)
+	first := newDurableStage(StageSummarizer, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		if !msg.IsInternshipRequest() {
+			return nil, nil
+		}
+
+		return entity.NewSummaryMsg(msg, "summary"), nil
+	}, testStage, errsCh, last)
+
+	for _, s := range []*durableStage{first, last, final} {
+		s.final = final
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, first, last, final)
+
+	in := make(chan entity.Message, 2)
+	in <- entity.NewMsg("0", "i4u", "kek", true)
+	in <- entity.NewMsg("1", "i4u", "kek", false)
+	close(in)
+	ingest(context.Background(), store, errsCh, in, 10, final, first)
+
+	msg := waitDelivered(t, delivered)
+	summary, ok := msg.(*entity.SummaryMsg)
+	require.True(t, ok)
+	assert.Equal(t, "0", summary.ID())
+	assert.Equal(t, "summary", summary.Text())
+
+	// both messages are labeled as processed, after they passed the pipeline.
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		msg = waitDelivered(t, labeled)
+		assert.Equal(t, "i4u", msg.Label())
+		ids[msg.ID()] = true
+	}
+	assert.Equal(t, map[string]bool{"0": true, "1": true}, ids)
+
+	cancel()
+	wg.Wait()
+	assert.Empty(t, errsCh)
+
+	for _, stage := range []string{StageSummarizer, StageSender, StageLabeler} {
+		assertQueueLen(t, store, stage, 0)
+	}
+}
+
+func TestDurableStage_FinalAfterCompletion(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	labeled := make(chan entity.Message, 10)
+	final := newDurableStage(StageLabeler, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		labeled <- msg
+		return nil, nil
+	}, testStage, errsCh)
+	sender := newDurableStage(StageSender, store, func(context.Context, entity.Message) (entity.Message, error) {
+		return nil, statusErr(http.StatusBadRequest)
+	}, testStage, errsCh)
+	sender.final, final.final = final, final
+
+	msg := entity.NewMsg("0", "i4u", "kek", true)
+	ingested, err := store.Ingest(msg, StageLabeler, StageSender)
+	require.NoError(t, err)
+	require.True(t, ingested)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, sender, final)
+
+	// the failed message isn't pending anymore, so it's labeled as processed.
+	assert.ErrorContains(t, <-errsCh, "moved to dead-letter queue")
+	assert.Equal(t, "0", waitDelivered(t, labeled).ID())
+	cancel()
+	wg.Wait()
+
+	assertQueueLen(t, store, StageLabeler, 0)
+	ingested, err = store.Ingest(msg, StageLabeler, StageSender)
+	require.NoError(t, err)
+	assert.True(t, ingested, "tracking is finished with the final stage")
+	assertQueueLen(t, store, StageSender, 1)
+
+	// purged message isn't labeled twice.
+	letters, err := store.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.NoError(t, store.PurgeDeadLetter(letters[0].ID))
+	assertQueueLen(t, store, StageLabeler, 0)
+}
+
+func TestIngest_DeadLetteredNotFetchedAgain(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	// the inbox returns up to 2 messages without the processed label.
+	var (
+		mu      sync.Mutex
+		labeled = make(map[string]bool)
+	)
+	fetch := func() <-chan entity.Message {
+		mu.Lock()
+		defer mu.Unlock()
+
+		out := make(chan entity.Message, 2)
+		defer close(out)
+		for _, id := range []string{"0", "1", "2"} {
+			if !labeled[id] && len(out) < cap(out) {
+				out <- entity.NewMsg(id, "i4u", "kek", true)
+			}
+		}
+
+		return out
+	}
+
+	done := make(chan entity.Message, 10)
+	final := newDurableStage(StageLabeler, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		mu.Lock()
+		labeled[msg.ID()] = true
+		mu.Unlock()
+
+		done <- msg
+		return nil, nil
+	}, testStage, errsCh)
+	delivered := make(chan entity.Message, 10)
+	stage := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		if msg.ID() != "2" {
+			return nil, statusErr(http.StatusBadRequest)
+		}
+
+		delivered <- msg
+		return nil, nil
+	}, testStage, errsCh)
+	stage.final, final.final = final, final
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, stage, final)
+
+	ingest(ctx, store, errsCh, fetch(), 10, final, stage)
+	for i := 0; i < 2; i++ {
+		assert.ErrorContains(t, <-errsCh, "moved to dead-letter queue")
+		waitDelivered(t, done)
+	}
+
+	// the dead-lettered messages don't take the whole limit of the next fetch.
+	ingest(ctx, store, errsCh, fetch(), 10, final, stage)
+	assert.Equal(t, "2", waitDelivered(t, delivered).ID())
+	assert.Equal(t, "2", waitDelivered(t, done).ID())
+	cancel()
+	wg.Wait()
+
+	assert.Empty(t, errsCh)
+	letters, err := store.DeadLetters()
+	require.NoError(t, err)
+	assert.Len(t, letters, 2)
+}
+
+func TestDurableStage_ResumeAfterRestart(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	require.NoError(t, store.Enqueue(
+		entity.NewSummaryMsg(entity.NewMsg("0", "is_intern", "kek", true), "summary"),
+		StageSender,
+	))
+
+	// the process is stopped, while the message is in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	interrupted := newDurableStage(StageSender, store, func(ctx context.Context, _ entity.Message) (entity.Message, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, testStage, errsCh)
+	wg := runStages(ctx, interrupted)
+
+	<-started
+	cancel()
+	wg.Wait()
+	assert.Empty(t, errsCh)
+	assertQueueLen(t, store, StageSender, 1)
+
+	// after the restart, the message is processed again.
+	delivered := make(chan entity.Message, 1)
+	ctx, cancel = context.WithCancel(context.Background())
+	resumed := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		delivered <- msg
+		return nil, nil
+	}, testStage, errsCh)
+	wg = runStages(ctx, resumed)
+
+	msg := waitDelivered(t, delivered)
+	assert.Equal(t, "0", msg.ID())
+	assert.Equal(t, "is_intern", msg.Label())
+
+	cancel()
+	wg.Wait()
+	assertQueueLen(t, store, StageSender, 0)
+}
+
+func TestDurableStage_Retry(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	var (
+		attempts  int
+		delivered = make(chan entity.Message, 1)
+	)
+	stage := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, statusErr(http.StatusServiceUnavailable)
+		}
+
+		delivered <- msg
+		return nil, nil
+	}, testStage, errsCh)
+
+	require.NoError(t, store.Enqueue(entity.NewMsg("0", "i4u", "kek", true), StageSender))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, stage)
+
+	waitDelivered(t, delivered)
+	cancel()
+	wg.Wait()
+
+	assert.Equal(t, 3, attempts)
+	assert.Empty(t, errsCh)
+	assertQueueLen(t, store, StageSender, 0)
+}
+
+// flakyStore fails the first call of Postpone and Advance.
+type flakyStore struct {
+	*storage.Storage
+
+	mu                  sync.Mutex
+	postponed, advanced bool
+}
+
+func (s *flakyStore) Postpone(stage, key string, attempts int, next time.Time, lastErr string) error {
+	s.mu.Lock()
+	failed := !s.postponed
+	s.postponed = true
+	s.mu.Unlock()
+
+	if failed {
+		return errors.New("disk is full")
+	}
+
+	return s.Storage.Postpone(stage, key, attempts, next, lastErr)
+}
+
+func (s *flakyStore) Advance(stage, key string, results ...storage.Forward) (bool, error) {
+	s.mu.Lock()
+	failed := !s.advanced
+	s.advanced = true
+	s.mu.Unlock()
+
+	if failed {
+		return false, errors.New("disk is full")
+	}
+
+	return s.Storage.Advance(stage, key, results...)
+}
+
+func TestDurableStage_StorageFailure(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	cfg := testStage
+	cfg.Workers = 1
+
+	var (
+		attempts  int
+		delivered = make(chan entity.Message, 3)
+	)
+	stage := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, statusErr(http.StatusServiceUnavailable)
+		}
+
+		delivered <- msg
+		return nil, nil
+	}, cfg, errsCh)
+	stage.store = &flakyStore{Storage: store}
+
+	require.NoError(t, store.Enqueue(entity.NewMsg("0", "i4u", "kek", true), StageSender))
+
+	// the message isn't stuck in flight, when the storage fails to
+	// postpone or advance it, so the only worker isn't taken forever.
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, stage)
+
+	waitDelivered(t, delivered)
+	waitDelivered(t, delivered)
+	assert.ErrorContains(t, <-errsCh, "failed to postpone message 0: disk is full")
+	assert.ErrorContains(t, <-errsCh, "failed to advance message 0 from sender: disk is full")
+
+	require.Eventually(t, func() bool {
+		n, err := store.QueueLen(StageSender)
+		return err == nil && n == 0
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	assert.Equal(t, 3, attempts)
+	assert.Empty(t, errsCh)
+}
+
+func TestDurableStage_DeadLetter(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	stage := newDurableStage(StageSender, store, func(context.Context, entity.Message) (entity.Message, error) {
+		return nil, statusErr(http.StatusBadRequest)
+	}, testStage, errsCh)
+
+	require.NoError(t, store.Enqueue(entity.NewMsg("0", "i4u", "kek", true), StageSender))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, stage)
+
+	// client errors aren't retried.
+	assert.ErrorContains(t, <-errsCh, "after 1 attempt(s), moved to dead-letter queue")
+	cancel()
+	wg.Wait()
+
+	assertQueueLen(t, store, StageSender, 0)
+	letters, err := store.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, StageSender, letters[0].Stage)
+	assert.Equal(t, "0", letters[0].Item.Msg.ID())
+
+	require.NoError(t, store.RetryDeadLetter(letters[0].ID))
+	assertQueueLen(t, store, StageSender, 1)
+
+	letters, err = store.DeadLetters()
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestDurableStage_Timeouts(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	cfg := testStage
+	cfg.Timeout, cfg.Deadline = 20*time.Millisecond, time.Hour
+
+	deadlines := make(chan time.Time, 1)
+	stage := newDurableStage(StageSummarizer, store, func(ctx context.Context, msg entity.Message) (entity.Message, error) {
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		return nil, nil
+	}, cfg, errsCh)
+
+	require.NoError(t, store.Enqueue(entity.NewMsg("0", "i4u", "kek", true), StageSummarizer))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, stage)
+
+	select {
+	case deadline := <-deadlines:
+		assert.WithinDuration(t, time.Now().Add(cfg.Timeout), deadline, cfg.Timeout)
+	case <-time.After(time.Second):
+		require.Fail(t, "message wasn't processed")
+	}
+
+	cancel()
+	wg.Wait()
+	assert.Empty(t, errsCh)
+}
+
+func TestDurableStage_Deadline(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	cfg := testStage
+	cfg.Deadline = time.Nanosecond
+
+	stage := newDurableStage(StageSummarizer, store, func(context.Context, entity.Message) (entity.Message, error) {
+		require.Fail(t, "expired message is processed")
+		return nil, nil
+	}, cfg, errsCh)
+
+	require.NoError(t, store.Enqueue(entity.NewMsg("0", "i4u", "kek", true), StageSummarizer))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, stage)
+
+	assert.ErrorContains(t, <-errsCh, "didn't pass the pipeline in 1ns")
+	cancel()
+	wg.Wait()
+
+	assertQueueLen(t, store, StageSummarizer, 0)
+	letters, err := store.DeadLetters()
+	require.NoError(t, err)
+	assert.Len(t, letters, 1)
+}
+
+func TestDurableStage_Workers(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	var (
+		mu              sync.Mutex
+		running, peak   int
+		delivered       = make(chan entity.Message, 10)
+		release         = make(chan struct{})
+		workers, queued = 2, 5
+	)
+	stage := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		delivered <- msg
+		return nil, nil
+	}, config.Stage{Retry: testStage.Retry, Workers: workers, Timeout: time.Second}, errsCh)
+
+	for i := 0; i < queued; i++ {
+		require.NoError(t, store.Enqueue(entity.NewMsg(strconv.Itoa(i), "i4u", "kek", true), StageSender))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, stage)
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < queued; i++ {
+		waitDelivered(t, delivered)
+	}
+
+	cancel()
+	wg.Wait()
+	assert.Equal(t, workers, peak)
+	assertQueueLen(t, store, StageSender, 0)
+}
+
+func TestIngest_Backpressure(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	delivered := make(chan entity.Message, 10)
+	final := newDurableStage(StageLabeler, store, func(context.Context, entity.Message) (entity.Message, error) {
+		return nil, nil
+	}, testStage, errsCh)
+	stage := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		delivered <- msg
+		return nil, nil
+	}, testStage, errsCh)
+
+	in := make(chan entity.Message)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ingest(context.Background(), store, errsCh, in, 1, final, stage)
+	}()
+
+	// the second message waits, until the stage takes the first one.
+	in <- entity.NewMsg("0", "i4u", "kek", true)
+	in <- entity.NewMsg("1", "i4u", "kek", true)
+	close(in)
+
+	time.Sleep(50 * time.Millisecond)
+	assertQueueLen(t, store, StageSender, 1)
+	select {
+	case <-done:
+		assert.Fail(t, "message ingested, while the queue is full")
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, stage)
+	<-done
+
+	assert.Equal(t, "0", waitDelivered(t, delivered).ID())
+	assert.Equal(t, "1", waitDelivered(t, delivered).ID())
+	cancel()
+	wg.Wait()
+	assert.Empty(t, errsCh)
+}
+
+func waitDelivered(t *testing.T, delivered <-chan entity.Message) entity.Message {
+	select {
+	case msg := <-delivered:
+		return msg
+	case <-time.After(time.Second):
+		require.Fail(t, "message wasn't delivered")
+		return nil
+	}
+}
+
+func assertQueueLen(t *testing.T, store *storage.Storage, stage string, expected int) {
+	items, err := store.Queued(stage)
+	require.NoError(t, err)
+	assert.Len(t, items, expected, stage)
+}
@@ -0,0 +1,105 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+)
+
+// Providers are the external services called by the stages.
+const (
+	ProviderGmail    = "Gmail"
+	ProviderOpenAI   = "OpenAI"
+	ProviderTelegram = "Telegram"
+)
+
+// stageProviders are the services, which are called by the stage,
+// when the error doesn't tell it by itself.
+var stageProviders = map[string]string{
+	StageFetcher:    ProviderGmail,
+	StageLabeler:    ProviderGmail,
+	StageSummarizer: ProviderOpenAI,
+}
+
+// PipelineError is an error of the pipeline stage, sent to the errors channel
+// of the producer, it describes the failure well enough to group the same
+// failures of the different messages together.
+type PipelineError struct {
+	Stage string
+
+	// MsgID is empty, when the error isn't related to a single message,
+	// like a failed read of the queue.
+	MsgID string
+
+	// Provider is an external service, which failed,
+	// empty when the failure is internal.
+	Provider string
+
+	// Retryable tells, whether the cause goes away after retrying,
+	// like rate limits or timeouts.
+	Retryable bool
+
+	Err error
+}
+
+func newPipelineError(stage, msgID string, err error) *PipelineError {
+	return &PipelineError{
+		Stage:     stage,
+		MsgID:     msgID,
+		Provider:  errorProvider(stage, err),
+		Retryable: isRetryable(err),
+		Err:       err,
+	}
+}
+
+// storageError is a failure of the persistent queues, it isn't related
+// to any provider, the message stays in the queue and is dispatched again.
+func storageError(stage, msgID string, err error) *PipelineError {
+	return &PipelineError{Stage: stage, MsgID: msgID, Retryable: true, Err: err}
+}
+
+func (e *PipelineError) Error() string { return e.Err.Error() }
+func (e *PipelineError) Unwrap() error { return e.Err }
+
+// AccountError tells the Gmail account, whose pipeline failed, when
+// several accounts are served by the same process.
+type AccountError struct {
+	Account string
+	Err     error
+}
+
+func (e *AccountError) Error() string { return "account " + e.Account + ": " + e.Err.Error() }
+func (e *AccountError) Unwrap() error { return e.Err }
+
+// Cause is a short description of the failure, like "429" or "timeout",
+// the status code is preferred, because it's what providers document.
+func (e *PipelineError) Cause() string {
+	if code, ok := statusCode(e.Err); ok {
+		return fmt.Sprint(code)
+	}
+
+	return errorCause(e.Err)
+}
+
+// errorProvider finds out, which of the services failed.
+func errorProvider(stage string, err error) string {
+	var (
+		gmailErr  *googleapi.Error
+		openaiErr *openai.APIError
+		reqErr    *openai.RequestError
+		tgErr     *tgbotapi.Error
+	)
+
+	switch {
+	case errors.As(err, &gmailErr):
+		return ProviderGmail
+	case errors.As(err, &openaiErr), errors.As(err, &reqErr):
+		return ProviderOpenAI
+	case errors.As(err, &tgErr):
+		return ProviderTelegram
+	}
+
+	return stageProviders[stage]
+}
@@ -0,0 +1,353 @@
+package job
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KindExec runs the stage as an external process.
+const KindExec = "exec"
+
+// execStopTimeout is given to the process to exit after its stdin
+// is closed, the process is killed after it.
+const execStopTimeout = 5 * time.Second
+
+// execMaxLine limits the size of the single response of the process.
+const execMaxLine = 16 << 20
+
+func init() {
+	RegisterStage(KindExec, newExecStage)
+}
+
+// execMessage is the message in the protocol of the external stages.
+type execMessage struct {
+	Msg *entity.Msg `json:"msg"`
+
+	// Summary is set only for the summarized messages.
+	Summary    string `json:"summary,omitempty"`
+	Summarized bool   `json:"summarized,omitempty"`
+}
+
+func newExecMessage(msg entity.Message) (*execMessage, error) {
+	switch m := msg.(type) {
+	case *entity.Msg:
+		return &execMessage{Msg: m}, nil
+	case *entity.SummaryMsg:
+		if inner, ok := m.Message.(*entity.Msg); ok {
+			return &execMessage{Msg: inner, Summary: m.Text(), Summarized: true}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown message type: %T", msg)
+}
+
+func (m *execMessage) message() (entity.Message, error) {
+	if m.Msg == nil {
+		return nil, errors.New("message is missing")
+	}
+
+	if m.Summarized {
+		return entity.NewSummaryMsg(m.Msg, m.Summary), nil
+	}
+
+	return m.Msg, nil
+}
+
+type execRequest struct {
+	ID      uint64       `json:"id"`
+	Message *execMessage `json:"message"`
+}
+
+type execResponse struct {
+	ID       uint64        `json:"id"`
+	Messages []execMessage `json:"messages"`
+
+	// Error fails the attempt, it's retried, unless it's permanent.
+	Error     string `json:"error,omitempty"`
+	Permanent bool   `json:"permanent,omitempty"`
+}
+
+// execStage passes the messages to the external process, which speaks
+// JSON lines over stdin and stdout, configured like:
+//
+//	stages:
+//	  - name: tracker
+//	    kind: exec
+//	    options:
+//	      command: python3 plugins/tracker.py
+//
+// For every message the process gets the request:
+//
+//	{"id": 1, "message": {"msg": {"id": "...", "body": "...", ...}, "summary": "...", "summarized": true}}
+//
+// and answers with the same id, the answers may come in any order,
+// because the stage sends the messages of all its workers at once:
+//
+//	{"id": 1, "messages": [{"msg": {...}}], "error": "...", "permanent": false}
+//
+// The process is started with the first message and restarted with the
+// next one, when it exits; its stderr is passed through to the logs.
+type execStage struct {
+	command []string
+	seq     atomic.Uint64
+
+	mu   sync.Mutex
+	proc *execProcess
+}
+
+func newExecStage(options map[string]string) (Stage, error) {
+	command := strings.Fields(options["command"])
+	if len(command) == 0 {
+		return nil, errors.New("command is required")
+	}
+
+	return &execStage{command: command}, nil
+}
+
+func (s *execStage) Process(ctx context.Context, msg entity.Message) ([]entity.Message, error) {
+	req, err := newExecMessage(msg)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+
+	proc, err := s.process()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := proc.call(ctx, &execRequest{ID: s.seq.Add(1), Message: req})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		err = errors.New(resp.Error)
+		if resp.Permanent {
+			err = Permanent(err)
+		}
+
+		return nil, err
+	}
+
+	results := make([]entity.Message, 0, len(resp.Messages))
+	for i := range resp.Messages {
+		result, e := resp.Messages[i].message()
+		if e != nil {
+			return nil, Permanent(fmt.Errorf("invalid response: %w", e))
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// process returns the running process, starting the new one, when needed.
+func (s *execStage) process() (*execProcess, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.proc != nil && !s.proc.exited() {
+		return s.proc, nil
+	}
+
+	proc, err := startExecProcess(s.command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", s.command[0], err)
+	}
+
+	s.proc = proc
+	return proc, nil
+}
+
+// Close stops the process, giving it a chance to exit by itself.
+func (s *execStage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.proc == nil {
+		return nil
+	}
+
+	return s.proc.stop()
+}
+
+type execProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	// writing holds the token, while the request is written to stdin,
+	// so the lines of the concurrent calls aren't mixed.
+	writing chan struct{}
+
+	mu      sync.Mutex
+	pending map[uint64]chan *execResponse
+
+	// done is closed, when the process exits, err tells why.
+	done chan struct{}
+	err  error
+}
+
+func startExecProcess(command []string) (*execProcess, error) {
+	// #nosec G204 -- the command is given by the owner of the config.
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &execProcess{
+		cmd:     cmd,
+		stdin:   stdin,
+		writing: make(chan struct{}, 1),
+		pending: make(map[uint64]chan *execResponse),
+		done:    make(chan struct{}),
+	}
+
+	go p.read(stdout)
+	return p, nil
+}
+
+// read delivers the responses to the waiting calls, until the process exits.
+func (p *execProcess) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64<<10), execMaxLine)
+
+	for scanner.Scan() {
+		var resp execResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			zap.S().Warnf("%s answered with invalid line %q: %s", p.cmd.Path, scanner.Text(), err)
+			continue
+		}
+
+		p.mu.Lock()
+		ch, ok := p.pending[resp.ID]
+		delete(p.pending, resp.ID)
+		p.mu.Unlock()
+
+		if ok {
+			ch <- &resp
+		}
+	}
+
+	err := p.cmd.Wait()
+	if err == nil {
+		err = scanner.Err()
+	}
+
+	if err == nil {
+		err = errors.New("process exited")
+	}
+
+	p.err = err
+	close(p.done)
+}
+
+func (p *execProcess) call(ctx context.Context, req *execRequest) (*execResponse, error) {
+	content, err := json.Marshal(req)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+
+	ch := make(chan *execResponse, 1)
+	p.mu.Lock()
+	p.pending[req.ID] = ch
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, req.ID)
+		p.mu.Unlock()
+	}()
+
+	if err = p.write(ctx, append(content, '\n')); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-p.done:
+		return nil, fmt.Errorf("stage process failed: %w", p.err)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// write sends the line to the process, without waiting for it past the ctx,
+// when the process doesn't read its stdin. The started write isn't
+// interrupted, so the next requests don't get the part of the line.
+func (p *execProcess) write(ctx context.Context, line []byte) error {
+	select {
+	case p.writing <- struct{}{}:
+	case <-p.done:
+		return fmt.Errorf("stage process failed: %w", p.err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := p.stdin.Write(line)
+		<-p.writing
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		if err != nil {
+			return fmt.Errorf("failed to write request: %w", err)
+		}
+
+		return nil
+	case <-p.done:
+		return fmt.Errorf("stage process failed: %w", p.err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *execProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *execProcess) stop() error {
+	if err := p.stdin.Close(); err != nil && !p.exited() {
+		return err
+	}
+
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(execStopTimeout):
+		return p.cmd.Process.Kill()
+	}
+}
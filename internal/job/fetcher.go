@@ -5,14 +5,16 @@ import (
 	"fmt"
 	"github.com/fadyat/i4u/api"
 	"github.com/fadyat/i4u/internal/entity"
-	"github.com/fadyat/i4u/pkg/syncs"
+	"github.com/fadyat/i4u/internal/metrics"
 	"go.uber.org/zap"
 	"time"
 )
 
 type MessageFetcherJob struct {
-	client api.Mail
-	period time.Duration
+	client  api.Mail
+	period  time.Duration
+	timeout time.Duration
+	state   *State
 
 	out    []chan<- entity.Message
 	errsCh chan<- error
@@ -21,14 +23,18 @@ type MessageFetcherJob struct {
 func NewFetcherJob(
 	mailClient api.Mail,
 	period time.Duration,
+	timeout time.Duration,
+	state *State,
 	errsCh chan<- error,
 	out []chan<- entity.Message,
 ) Job {
 	return &MessageFetcherJob{
-		client: mailClient,
-		period: period,
-		errsCh: errsCh,
-		out:    out,
+		client:  mailClient,
+		period:  period,
+		timeout: timeout,
+		state:   state,
+		errsCh:  errsCh,
+		out:     out,
 	}
 }
 
@@ -36,24 +42,21 @@ func (m *MessageFetcherJob) Run(ctx context.Context) {
 	ticker := time.NewTicker(m.period)
 	defer ticker.Stop()
 
-	var wg syncs.WaitGroup
 	for {
 		select {
 		case <-ticker.C:
-			wg.Go(func() {
-				timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
-				defer cancel()
+			if m.state.IsPaused() {
+				m.state.tick(time.Now(), false)
+				zap.S().Debug("fetching is paused, skipping")
+				continue
+			}
 
-				m.fetch(timeout, &wg)
-			})
+			// fetching in the same goroutine, so the next fetch doesn't
+			// start, until the next stages accept all fetched messages.
+			m.fetch(ctx)
+		case <-m.state.fetchNow:
+			m.fetch(ctx)
 		case <-ctx.Done():
-
-			// stopping the ticker right now to prevent a new
-			// stage of fetching messages from the mail provider.
-			// deferring with stopping the ticker won't
-			// break the logic, because the next stops aren't panicking.
-			ticker.Stop()
-			wg.Wait()
 			return
 		}
 	}
@@ -61,20 +64,32 @@ func (m *MessageFetcherJob) Run(ctx context.Context) {
 
 // fetch getting unread messages from mail provider and push them to the next stage
 // with parsing to the internal message format.
-func (m *MessageFetcherJob) fetch(ctx context.Context, wg *syncs.WaitGroup) {
-	for wrap := range m.client.GetUnreadMsgs(ctx) {
+func (m *MessageFetcherJob) fetch(ctx context.Context) {
+	timeout, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	start := time.Now()
+	m.state.tick(start, true)
+	defer func() {
+		metrics.Duration.WithLabelValues(StageFetcher).Observe(time.Since(start).Seconds())
+	}()
+
+	var lastErr error
+	for wrap := range m.client.GetUnreadMsgs(timeout) {
+		record(StageFetcher, wrap.Err)
 		if wrap.Err != nil {
-			m.errsCh <- fmt.Errorf("failed to fetch message: %w", wrap.Err)
+			lastErr = fmt.Errorf("failed to fetch message: %w", wrap.Err)
+			m.errsCh <- newPipelineError(StageFetcher, "", lastErr)
 			continue
 		}
 
-		for _, o := range m.out {
-			out := o
-			wg.Go(func() { out <- wrap.Msg })
+		for _, out := range m.out {
+			out <- wrap.Msg
 		}
 
 		zap.S().Debugf("message %s pushed to the next stage", wrap.Msg.ID())
 	}
 
+	m.state.fetched(time.Now(), lastErr)
 	zap.S().Debug("message fetcher job finished")
 }
@@ -163,7 +163,7 @@ func TestMessageFetcherJob_Run(t *testing.T) {
 				}()
 				defer close(errsCh)
 
-				NewFetcherJob(fetcher, 300*time.Millisecond, errsCh, outW).Run(jobContext)
+				NewFetcherJob(fetcher, 300*time.Millisecond, time.Second, NewState(), errsCh, outW).Run(jobContext)
 			})
 
 			for _, c := range out {
@@ -232,3 +232,39 @@ func TestMessageFetcherJob_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestMessageFetcherJob_FetchNow(t *testing.T) {
+	setup()
+
+	outCh := make(chan entity.MessageWithError, 1)
+	outCh <- entity.MessageWithError{Msg: entity.NewMsg("0", "i4u", "kek", false)}
+	close(outCh)
+
+	client := mocks.NewMail(t)
+	client.On("GetUnreadMsgs", mock.Anything).Return((<-chan entity.MessageWithError)(outCh)).Once()
+
+	var (
+		state       = NewState()
+		out         = make(chan entity.Message, 1)
+		ctx, cancel = context.WithCancel(context.Background())
+		wg          syncs.WaitGroup
+	)
+
+	// fetching on demand, even when paused, without waiting for the tick.
+	state.Pause()
+	state.FetchNow()
+	wg.Go(func() {
+		NewFetcherJob(client, time.Hour, time.Second, state, make(chan error), []chan<- entity.Message{out}).Run(ctx)
+	})
+
+	select {
+	case msg := <-out:
+		assert.Equal(t, "0", msg.ID())
+	case <-time.After(time.Second):
+		assert.Fail(t, "message wasn't fetched")
+	}
+
+	cancel()
+	wg.Wait()
+	assert.False(t, state.Status().LastFetch.IsZero())
+}
@@ -0,0 +1,122 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/pkg/parser"
+)
+
+// Kinds of the stages, which aren't the part of the default pipeline.
+const (
+	KindSubject = "subject"
+	KindRedact  = "redact"
+)
+
+// stageKinds create the implementations of the stages by their kind,
+// the kinds are referenced by the pipeline topology from the config.
+var stageKinds = map[string]func(p *producer) stageFunc{
+	StageAnalyzer: func(p *producer) stageFunc {
+		analyzer := &MessageAnalyzerJob{client: p.analyzerClient, labelsMapper: p.labelsMapper}
+		return analyzer.analyze
+	},
+	StageSummarizer: func(p *producer) stageFunc {
+		summarizer := &SummarizerJob{client: p.summarizer}
+		return func(ctx context.Context, msg entity.Message) (entity.Message, error) {
+			summary, err := summarizer.summary(ctx, msg)
+			if summary == nil {
+				return nil, err
+			}
+
+			return summary, err
+		}
+	},
+	StageLabeler: func(p *producer) stageFunc {
+		labeler := &LabelerJob{client: p.mailClient}
+		return func(ctx context.Context, msg entity.Message) (entity.Message, error) {
+			return nil, labeler.labeling(ctx, msg)
+		}
+	},
+	StageSender: func(p *producer) stageFunc {
+		sender := &SenderJob{client: p.sender}
+		return func(ctx context.Context, msg entity.Message) (entity.Message, error) {
+			summary, ok := msg.(*entity.SummaryMsg)
+			if !ok {
+				return nil, Permanent(fmt.Errorf("unknown message type: %T", msg))
+			}
+
+			return nil, sender.send(ctx, summary)
+		}
+	},
+	KindSubject: func(*producer) stageFunc { return subject },
+	KindRedact:  func(*producer) stageFunc { return redact },
+}
+
+// subject passes the subject of the message as its summary,
+// so the message can be sent without summarizing.
+func subject(_ context.Context, msg entity.Message) (entity.Message, error) {
+	m, ok := msg.(*entity.Msg)
+	if !ok {
+		return nil, Permanent(fmt.Errorf("unknown message type: %T", msg))
+	}
+
+	text := m.Subject()
+	if text == "" {
+		text = "(no subject)"
+	}
+
+	return entity.NewSummaryMsg(m, text), nil
+}
+
+// redact masks the personal data of the message, so it isn't
+// passed to the next stages, like the third-party summarizer.
+func redact(_ context.Context, msg entity.Message) (entity.Message, error) {
+	switch m := msg.(type) {
+	case *entity.Msg:
+		return m.Copy().WithBody(parser.Redact(m.Body())).WithSubject(parser.Redact(m.Subject())), nil
+	case *entity.SummaryMsg:
+		inner, err := redact(context.Background(), m.Message)
+		if err != nil {
+			return nil, err
+		}
+
+		return entity.NewSummaryMsg(inner, parser.Redact(m.Text())), nil
+	}
+
+	return nil, Permanent(fmt.Errorf("unknown message type: %T", msg))
+}
+
+// validateTopology checks, that all stages of the topology
+// are implemented and the edge filters are known verdicts.
+func validateTopology(t *config.Topology) error {
+	for _, s := range t.Stages {
+		if _, ok := stageKinds[s.Kind]; !ok {
+			if _, ok = pluginFactory(s.Kind); !ok {
+				return fmt.Errorf("stage %s: unknown kind: %s", s.Name, s.Kind)
+			}
+		}
+
+		for _, e := range s.Next {
+			if _, err := parseVerdicts(e.Verdicts); err != nil {
+				return fmt.Errorf("stage %s: edge to %s: %w", s.Name, e.Stage, err)
+			}
+		}
+	}
+
+	return t.Validate()
+}
+
+func parseVerdicts(values []string) ([]entity.Verdict, error) {
+	verdicts := make([]entity.Verdict, 0, len(values))
+	for _, v := range values {
+		verdict := entity.Verdict(v)
+		if !verdict.IsValid() {
+			return nil, fmt.Errorf("unknown verdict: %s", v)
+		}
+
+		verdicts = append(verdicts, verdict)
+	}
+
+	return verdicts, nil
+}
@@ -0,0 +1,198 @@
+package job
+
+import (
+	"context"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestValidateTopology(t *testing.T) {
+	testCases := []struct {
+		name        string
+		topology    config.Topology
+		expectedErr string
+	}{
+		{
+			name:     "default",
+			topology: config.DefaultTopology(),
+		},
+		{
+			name: "raw subjects",
+			topology: config.Topology{
+				Entry: []string{"redact"},
+				Final: "labeler",
+				Stages: []config.StageSpec{
+					{Name: "redact", Kind: KindRedact, Next: []config.Edge{{Stage: "subject"}}},
+					{Name: "subject", Kind: KindSubject, Next: []config.Edge{{Stage: "sender"}}},
+					{Name: "labeler", Kind: StageLabeler},
+					{Name: "sender", Kind: StageSender},
+				},
+			},
+		},
+		{
+			name: "unknown kind",
+			topology: config.Topology{
+				Entry: []string{"storage"}, Final: "storage",
+				Stages: []config.StageSpec{{Name: "storage", Kind: "storage"}},
+			},
+			expectedErr: "stage storage: unknown kind: storage",
+		},
+		{
+			name: "unknown verdict",
+			topology: config.Topology{
+				Entry: []string{"summarizer"}, Final: "sender",
+				Stages: []config.StageSpec{
+					{Name: "summarizer", Kind: StageSummarizer, Next: []config.Edge{{Stage: "sender", Verdicts: []string{"ghosted"}}}},
+					{Name: "sender", Kind: StageSender},
+				},
+			},
+			expectedErr: "stage summarizer: edge to sender: unknown verdict: ghosted",
+		},
+		{
+			name: "unknown next stage",
+			topology: config.Topology{
+				Entry: []string{"summarizer"}, Final: "summarizer",
+				Stages: []config.StageSpec{
+					{Name: "summarizer", Kind: StageSummarizer, Next: []config.Edge{{Stage: "sender"}}},
+				},
+			},
+			expectedErr: "stage summarizer: unknown next stage: sender",
+		},
+		{
+			name: "cycle",
+			topology: config.Topology{
+				Entry: []string{"a"}, Final: "labeler",
+				Stages: []config.StageSpec{
+					{Name: "a", Kind: KindRedact, Next: []config.Edge{{Stage: "b"}}},
+					{Name: "b", Kind: KindRedact, Next: []config.Edge{{Stage: "a"}}},
+					{Name: "labeler", Kind: StageLabeler},
+				},
+			},
+			expectedErr: "stage a: cycle in the pipeline",
+		},
+		{
+			name: "unknown final stage",
+			topology: config.Topology{
+				Entry: []string{"sender"}, Final: "labeler",
+				Stages: []config.StageSpec{{Name: "sender", Kind: StageSender}},
+			},
+			expectedErr: "unknown final stage: labeler",
+		},
+		{
+			name: "final stage isn't labeler",
+			topology: config.Topology{
+				Entry: []string{"subject"}, Final: "sender",
+				Stages: []config.StageSpec{
+					{Name: "subject", Kind: KindSubject, Next: []config.Edge{{Stage: "sender"}}},
+					{Name: "sender", Kind: StageSender},
+				},
+			},
+			expectedErr: "final stage sender: must be of kind labeler, got sender",
+		},
+		{
+			name: "no final stage",
+			topology: config.Topology{
+				Entry:  []string{"sender"},
+				Stages: []config.StageSpec{{Name: "sender", Kind: StageSender}},
+			},
+			expectedErr: "no final stage, add a stage of kind labeler",
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			for i := range tc.topology.Stages {
+				if tc.topology.Stages[i].Kind == "" {
+					tc.topology.Stages[i].Kind = tc.topology.Stages[i].Name
+				}
+			}
+
+			err := validateTopology(&tc.topology)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestTopology_UnmarshalYAML(t *testing.T) {
+	var topology config.Topology
+	require.NoError(t, yaml.Unmarshal([]byte(`
+stages:
+  - name: analyzer
+    next: [summarizer, labeler]
+  - name: summarizer
+    next:
+      - stage: sender
+        verdicts: [offer, interview]
+`), &topology))
+
+	assert.Equal(t, []config.Edge{{Stage: "summarizer"}, {Stage: "labeler"}}, topology.Stages[0].Next)
+	assert.Equal(t, []config.Edge{{Stage: "sender", Verdicts: []string{"offer", "interview"}}}, topology.Stages[1].Next)
+}
+
+func TestDurableStage_VerdictFilter(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	delivered := make(chan entity.Message, 10)
+	final := newDurableStage(StageLabeler, store, func(context.Context, entity.Message) (entity.Message, error) {
+		return nil, nil
+	}, testStage, errsCh)
+	last := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		delivered <- msg
+		return nil, nil
+	}, testStage, errsCh)
+	first := newDurableStage(StageSummarizer, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		return entity.NewSummaryMsg(msg, "Verdict: "+msg.Body()), nil
+	}, testStage, errsCh)
+	first.connect(last, entity.VerdictOffer, entity.VerdictInterview)
+
+	for _, s := range []*durableStage{first, last, final} {
+		s.final = final
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, first, last, final)
+
+	in := make(chan entity.Message, 3)
+	in <- entity.NewMsg("0", "i4u", "reject", true)
+	in <- entity.NewMsg("1", "i4u", "offer", true)
+	in <- entity.NewMsg("2", "i4u", "unfortunately", true)
+	close(in)
+	ingest(context.Background(), store, errsCh, in, 10, final, first)
+
+	assert.Equal(t, "1", waitDelivered(t, delivered).ID())
+
+	cancel()
+	wg.Wait()
+	assert.Empty(t, delivered)
+	assert.Empty(t, errsCh)
+}
+
+func TestSubjectAndRedact(t *testing.T) {
+	msg := entity.NewMsg("0", "i4u", "write to john.doe@example.com or +1 (555) 123-4567", true).
+		WithSubject("Offer for jane@example.com")
+
+	redacted, err := redact(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, "write to [email] or [phone]", redacted.Body())
+
+	summary, err := subject(context.Background(), redacted)
+	require.NoError(t, err)
+	assert.Equal(t, "Offer for [email]", summary.(*entity.SummaryMsg).Text())
+
+	// the original message isn't changed.
+	assert.Equal(t, "Offer for jane@example.com", msg.Subject())
+}
@@ -6,85 +6,55 @@ import (
 	"github.com/fadyat/i4u/api"
 	"github.com/fadyat/i4u/internal/entity"
 	"github.com/fadyat/i4u/mocks"
-	"github.com/fadyat/i4u/pkg/syncs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 type labelerJobTestcase struct {
 	name          string
-	in            []entity.Message
+	in            entity.Message
 	pre           func(t *testing.T, c api.Mail, tc labelerJobTestcase)
-	expectedError error
+	expectedError string
 }
 
-func TestLabelerJob_Run(t *testing.T) {
+func TestLabelerJob_labeling(t *testing.T) {
 	testCases := []labelerJobTestcase{
 		{
 			name: "context deadline",
-			in: []entity.Message{
-				entity.NewMsg(
-					"1", "i4u", "kek", true,
-				),
-			},
+			in: entity.NewMsg(
+				"1", "i4u", "kek", true,
+			),
 			pre: func(t *testing.T, c api.Mail, tc labelerJobTestcase) {
 				c.(*mocks.Mail).On("LabelMsg", mock.Anything, mock.Anything).
-					Run(func(args mock.Arguments) {
-						ctx := args.Get(0).(context.Context)
+					Return(func(ctx context.Context, _ entity.MessageForLabeler) error {
 						<-ctx.Done()
-					}).Return(tc.expectedError)
+						return ctx.Err()
+					})
 			},
-			expectedError: context.DeadlineExceeded,
+			expectedError: "labeling failed with: context deadline exceeded",
 		},
 		{
 			name: "label success",
-			in: []entity.Message{
-				entity.NewMsg(
-					"1", "i4u", "kek", true,
-				),
-			},
+			in: entity.NewMsg(
+				"1", "i4u", "kek", true,
+			),
 			pre: func(t *testing.T, c api.Mail, tc labelerJobTestcase) {
-				for _, msg := range tc.in {
-					c.(*mocks.Mail).On("LabelMsg", mock.Anything, msg).
-						Return(nil)
-				}
+				c.(*mocks.Mail).On("LabelMsg", mock.Anything, tc.in).
+					Return(nil)
 			},
 		},
 		{
 			name: "label error",
-			in: []entity.Message{
-				entity.NewMsg(
-					"1", "i4u", "kek", true,
-				),
-			},
-			pre: func(t *testing.T, c api.Mail, tc labelerJobTestcase) {
-				for _, msg := range tc.in {
-					c.(*mocks.Mail).On("LabelMsg", mock.Anything, msg).
-						Return(tc.expectedError)
-				}
-			},
-			expectedError: errors.New("labeling failed with: label error"),
-		},
-		{
-			name: "labeling multiple messages",
-			in: func() []entity.Message {
-				size := 100
-				msgs := make([]entity.Message, size)
-				for i := 0; i < size; i++ {
-					msgs[i] = entity.NewMsg(
-						"1", "i4u", "kek", true,
-					)
-				}
-
-				return msgs
-			}(),
+			in: entity.NewMsg(
+				"1", "i4u", "kek", true,
+			),
 			pre: func(t *testing.T, c api.Mail, tc labelerJobTestcase) {
-				for _, msg := range tc.in {
-					c.(*mocks.Mail).On("LabelMsg", mock.Anything, msg).
-						Return(nil)
-				}
+				c.(*mocks.Mail).On("LabelMsg", mock.Anything, tc.in).
+					Return(errors.New("label error"))
 			},
+			expectedError: "labeling failed with: label error",
 		},
 	}
 
@@ -97,37 +67,16 @@ func TestLabelerJob_Run(t *testing.T) {
 			labeler := mocks.NewMail(t)
 			tc.pre(t, labeler, tc)
 
-			errCh, in := make(chan error), make(chan entity.Message)
-			defer close(in)
-
-			var (
-				jobWg              syncs.WaitGroup
-				inputWg            syncs.WaitGroup
-				jobContext, cancel = context.WithCancel(context.Background())
-			)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
 
-			jobWg.Go(func() {
-				defer close(errCh)
-
-				NewLabelerJob(labeler, errCh, in).Run(jobContext)
-			})
-
-			for _, msg := range tc.in {
-				m := msg
-				inputWg.Go(func() { in <- m })
+			err := (&LabelerJob{client: labeler}).labeling(ctx, tc.in)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
 			}
 
-			go func() {
-				for err := range errCh {
-					if !errors.Is(err, tc.expectedError) {
-						assert.NoError(t, err)
-					}
-				}
-			}()
-
-			inputWg.Wait()
-			cancel()
-			jobWg.Wait()
+			assert.NoError(t, err)
 		})
 	}
 }
@@ -0,0 +1,72 @@
+package job
+
+import (
+	"context"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/metrics"
+	"github.com/fadyat/i4u/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// observed instruments the stage function, so every stage reports the same
+// metrics: processed messages, failed attempts by the cause and latency.
+//
+// Every attempt is a span in the trace of the message, the span tells,
+// when the stage didn't pass the message further.
+func observed(stage string, fn fanoutFunc) fanoutFunc {
+	return func(ctx context.Context, msg entity.Message) ([]entity.Message, error) {
+		ctx, span := tracing.Start(tracing.WithMessage(ctx, msg), "stage."+stage, trace.WithAttributes(
+			tracing.AttrStage.String(stage),
+			tracing.AttrMessageID.String(msg.ID()),
+		))
+		defer span.End()
+
+		start := time.Now()
+		results, err := fn(ctx, msg)
+		metrics.Duration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+
+		record(stage, err)
+		if err != nil {
+			tracing.Fail(span, err)
+			return results, err
+		}
+
+		if len(results) == 0 {
+			span.AddEvent("message isn't passed to the next stages")
+		}
+
+		for _, result := range results {
+			if summary, ok := result.(entity.SummaryMessage); ok {
+				span.SetAttributes(tracing.AttrVerdict.String(string(verdictOf(summary))))
+			}
+		}
+
+		return results, nil
+	}
+}
+
+// countVerdicts counts the verdicts of the summaries, it's called only by
+// the summarizer stage, after the results are passed further, so neither the
+// stages after it, nor the retries of the same message count them again.
+func countVerdicts(results []entity.Message) {
+	for _, result := range results {
+		if summary, ok := result.(entity.SummaryMessage); ok {
+			metrics.Verdicts.WithLabelValues(string(verdictOf(summary))).Inc()
+		}
+	}
+}
+
+func verdictOf(summary entity.SummaryMessage) entity.Verdict {
+	return entity.ParseVerdict(entity.ParseSummaryFields(summary.Summary()).Verdict)
+}
+
+// record counts the outcome of the single message at the stage.
+func record(stage string, err error) {
+	if err != nil {
+		metrics.Errors.WithLabelValues(stage, errorCause(err)).Inc()
+		return
+	}
+
+	metrics.Processed.WithLabelValues(stage).Inc()
+}
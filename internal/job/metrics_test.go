@@ -0,0 +1,102 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestErrorCause(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected string
+	}{
+		{err: fmt.Errorf("summary: %w", context.DeadlineExceeded), expected: "timeout"},
+		{err: context.Canceled, expected: "canceled"},
+		{err: Permanent(errors.New("empty body")), expected: "permanent"},
+		{err: statusErr(http.StatusTooManyRequests), expected: "rate_limited"},
+		{err: statusErr(http.StatusBadGateway), expected: "server"},
+		{err: statusErr(http.StatusForbidden), expected: "client"},
+		{err: errors.New("connection reset"), expected: "other"},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.expected, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, errorCause(tc.err))
+		})
+	}
+}
+
+func TestObserved(t *testing.T) {
+	const stage = "test_observed"
+
+	fn := observed(stage, stageFunc(func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		if !msg.IsInternshipRequest() {
+			return nil, statusErr(http.StatusBadGateway)
+		}
+
+		return entity.NewSummaryMsg(msg, "⛔ Verdict: Reject"), nil
+	}).fanout())
+
+	rejects := testutil.ToFloat64(metrics.Verdicts.WithLabelValues(string(entity.VerdictReject)))
+
+	_, err := fn(context.Background(), entity.NewMsg("0", "i4u", "kek", true))
+	require.NoError(t, err)
+	_, err = fn(context.Background(), entity.NewMsg("1", "i4u", "kek", false))
+	require.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Processed.WithLabelValues(stage)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.Errors.WithLabelValues(stage, "server")))
+
+	// the verdicts are counted by the summarizer stage, not by every stage,
+	// which passes the summary further.
+	assert.Equal(t, rejects, testutil.ToFloat64(metrics.Verdicts.WithLabelValues(string(entity.VerdictReject))))
+}
+
+func TestDurableStage_CountVerdicts(t *testing.T) {
+	store := newTestStorage(t)
+	errsCh := make(chan error, 10)
+
+	cfg := testStage
+	cfg.Workers = 1
+
+	delivered := make(chan entity.Message, 1)
+	last := newDurableStage(StageSender, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		delivered <- msg
+		return nil, nil
+	}, cfg, errsCh)
+	redact := newDurableStage("redact", store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		return msg, nil
+	}, cfg, errsCh, last)
+	first := newDurableStage(StageSummarizer, store, func(_ context.Context, msg entity.Message) (entity.Message, error) {
+		return entity.NewSummaryMsg(msg, "Verdict: Test task"), nil
+	}, cfg, errsCh, redact)
+	first.verdicts = true
+	first.store = &flakyStore{Storage: store}
+
+	tasks := testutil.ToFloat64(metrics.Verdicts.WithLabelValues(string(entity.VerdictTestTask)))
+	require.NoError(t, store.Enqueue(entity.NewMsg("0", "i4u", "kek", true), StageSummarizer))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := runStages(ctx, first, redact, last)
+
+	waitDelivered(t, delivered)
+	cancel()
+	wg.Wait()
+
+	// the summary is produced twice, the storage fails to advance the first
+	// one, but the verdict is counted once, the redact stage doesn't count it.
+	assert.ErrorContains(t, <-errsCh, "failed to advance message 0 from summarizer: disk is full")
+	assert.Equal(t, tasks+1, testutil.ToFloat64(metrics.Verdicts.WithLabelValues(string(entity.VerdictTestTask))))
+}
@@ -0,0 +1,81 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// Stage is a custom step of the pipeline, it processes the message and
+// returns the messages for the next stages, none to stop the message here.
+//
+// Stages, which hold resources, like the external processes, may
+// implement io.Closer, they are closed after the pipeline is stopped.
+type Stage interface {
+	Process(ctx context.Context, msg entity.Message) ([]entity.Message, error)
+}
+
+// StageFunc is an adapter of the function to the Stage.
+type StageFunc func(ctx context.Context, msg entity.Message) ([]entity.Message, error)
+
+func (fn StageFunc) Process(ctx context.Context, msg entity.Message) ([]entity.Message, error) {
+	return fn(ctx, msg)
+}
+
+// StageFactory creates the stage with the options from the topology.
+type StageFactory func(options map[string]string) (Stage, error)
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   = make(map[string]StageFactory)
+)
+
+// RegisterStage makes the stage available as the kind in the pipeline
+// topology, it's usually called from the init function of the package
+// with the stage. Panics, when the kind is already taken.
+func RegisterStage(kind string, factory StageFactory) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	if _, ok := stageKinds[kind]; ok {
+		panic("job: stage kind is built-in: " + kind)
+	}
+
+	if _, ok := plugins[kind]; ok {
+		panic("job: stage kind is already registered: " + kind)
+	}
+
+	plugins[kind] = factory
+}
+
+func pluginFactory(kind string) (StageFactory, bool) {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+
+	factory, ok := plugins[kind]
+	return factory, ok
+}
+
+// pluginStage runs the custom stage like the built-in ones: the durable
+// stage gives it workers, retries, timeouts and metrics, and the stage
+// is skipped, while it's disabled by the feature flags.
+func pluginStage(name string, stage Stage) fanoutFunc {
+	config.DeclareFeature(name)
+
+	return func(ctx context.Context, msg entity.Message) ([]entity.Message, error) {
+		if !config.Features().IsStageEnabled(name) {
+			zap.S().Debugf("got message %s, but %s stage is disabled", msg.ID(), name)
+			return nil, nil
+		}
+
+		results, err := stage.Process(ctx, msg)
+		if err != nil {
+			return nil, fmt.Errorf("%s stage failed: %w", name, err)
+		}
+
+		return results, nil
+	}
+}
@@ -0,0 +1,145 @@
+package job
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterStage(t *testing.T) {
+	setup()
+
+	RegisterStage("test_tracker", func(map[string]string) (Stage, error) {
+		return StageFunc(func(_ context.Context, msg entity.Message) ([]entity.Message, error) {
+			return []entity.Message{msg}, nil
+		}), nil
+	})
+
+	assert.Panics(t, func() { RegisterStage("test_tracker", nil) })
+	assert.Panics(t, func() { RegisterStage(StageSummarizer, nil) })
+
+	topology := config.Topology{
+		Entry: []string{"tracker"}, Final: "labeler",
+		Stages: []config.StageSpec{
+			{Name: "tracker", Kind: "test_tracker", Next: []config.Edge{{Stage: "labeler"}}},
+			{Name: "labeler", Kind: StageLabeler},
+		},
+	}
+	require.NoError(t, validateTopology(&topology))
+
+	factory, ok := pluginFactory("test_tracker")
+	require.True(t, ok)
+	stage, err := factory(nil)
+	require.NoError(t, err)
+
+	fn := pluginStage("test_tracker_stage", stage)
+	msg := entity.NewMsg("0", "i4u", "kek", true)
+
+	results, err := fn(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Message{msg}, results)
+
+	// disabled stages drop the messages, like the built-in ones.
+	require.NoError(t, config.SetFeature("test_tracker_stage", false))
+	results, err = fn(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+// TestExecHelperProcess is the external stage, started by TestExecStage.
+func TestExecHelperProcess(t *testing.T) {
+	if os.Getenv("I4U_EXEC_HELPER") != "1" {
+		t.Skip("helper process")
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	encoder := json.NewEncoder(os.Stdout)
+	for scanner.Scan() {
+		var req execRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+
+		resp := execResponse{ID: req.ID}
+		switch req.Message.Msg.Body() {
+		case "exit":
+			os.Exit(1)
+		case "stuck":
+			// stops reading the requests, for a while.
+			time.Sleep(500 * time.Millisecond)
+			os.Exit(0)
+		case "fail":
+			resp.Error, resp.Permanent = "bad body", true
+		default:
+			tracked := req.Message.Msg.Copy().WithLabel("tracked")
+			resp.Messages = []execMessage{*req.Message, {Msg: tracked}}
+		}
+
+		_ = encoder.Encode(&resp)
+	}
+
+	os.Exit(0)
+}
+
+func TestExecStage(t *testing.T) {
+	t.Setenv("I4U_EXEC_HELPER", "1")
+
+	_, err := newExecStage(nil)
+	require.EqualError(t, err, "command is required")
+
+	stage, err := newExecStage(map[string]string{"command": os.Args[0] + " -test.run=^TestExecHelperProcess$"})
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, stage.(*execStage).Close()) })
+
+	summary := entity.NewSummaryMsg(entity.NewMsg("0", "i4u", "kek", true), "summary")
+	results, err := stage.Process(context.Background(), summary)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "summary", results[0].(*entity.SummaryMsg).Text())
+	assert.Equal(t, "tracked", results[1].Label())
+
+	_, err = stage.Process(context.Background(), entity.NewMsg("1", "i4u", "fail", true))
+	assert.EqualError(t, err, "bad body")
+	assert.False(t, isRetryable(err))
+
+	// the crashed process is restarted with the next message.
+	_, err = stage.Process(context.Background(), entity.NewMsg("2", "i4u", "exit", true))
+	assert.ErrorContains(t, err, "stage process failed")
+	assert.True(t, isRetryable(err))
+
+	results, err = stage.Process(context.Background(), entity.NewMsg("3", "i4u", "kek", true))
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+}
+
+func TestExecStage_StuckProcess(t *testing.T) {
+	t.Setenv("I4U_EXEC_HELPER", "1")
+
+	stage, err := newExecStage(map[string]string{"command": os.Args[0] + " -test.run=^TestExecHelperProcess$"})
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, stage.(*execStage).Close()) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = stage.Process(ctx, entity.NewMsg("0", "i4u", "stuck", true))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the request doesn't fit into the pipe, which isn't read,
+	// but the call doesn't outlive its timeout.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = stage.Process(ctx, entity.NewMsg("1", "i4u", strings.Repeat("kek", 1<<20), true))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+}
@@ -2,12 +2,14 @@ package job
 
 import (
 	"context"
+	"fmt"
 	"github.com/fadyat/i4u/api"
 	"github.com/fadyat/i4u/internal/config"
 	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
 	"github.com/fadyat/i4u/pkg/syncs"
 	"go.uber.org/zap"
-	"time"
+	"io"
 )
 
 type producer struct {
@@ -17,6 +19,12 @@ type producer struct {
 	sender         api.Sender
 
 	labelsMapper *config.LabelsMapper
+	state        *State
+	store        *storage.Storage
+	pipeline     *config.Pipeline
+
+	// custom are the stages of the registered kinds by their names.
+	custom map[string]Stage
 }
 
 func NewProducer(
@@ -25,68 +33,134 @@ func NewProducer(
 	summarizer api.Summarizer,
 	sender api.Sender,
 	labelsMapper *config.LabelsMapper,
-) Producer {
+	state *State,
+	store *storage.Storage,
+	pipeline *config.Pipeline,
+) (Producer, error) {
+	if err := validateTopology(&pipeline.Topology); err != nil {
+		return nil, fmt.Errorf("invalid pipeline topology: %w", err)
+	}
+
+	custom := make(map[string]Stage)
+	for _, spec := range pipeline.Topology.Stages {
+		factory, ok := pluginFactory(spec.Kind)
+		if !ok {
+			continue
+		}
+
+		stage, err := factory(spec.Options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s stage: %w", spec.Name, err)
+		}
+
+		custom[spec.Name] = stage
+	}
+
 	return &producer{
 		mailClient:     mailClient,
 		analyzerClient: analyzerClient,
 		summarizer:     summarizer,
 		sender:         sender,
 		labelsMapper:   labelsMapper,
-	}
+		state:          state,
+		store:          store,
+		pipeline:       pipeline,
+		custom:         custom,
+	}, nil
 }
 
+// Produce starts the pipeline, where stages are connected via persistent
+// queues, fetched messages are processed even after the restart.
 func (p *producer) Produce(ctx context.Context) <-chan error {
 	errsCh := make(chan error)
-	labelerChan := make(chan entity.Message)
-	analyzerChan := make(chan entity.Message)
-	summarizerChan := make(chan entity.Message)
-	senderChan := make(chan entity.SummaryMsg)
+	fetchedChan := make(chan entity.Message, p.pipeline.Buffer)
 
 	fetcherJob := NewFetcherJob(
 		p.mailClient,
-		10*time.Second,
+		p.pipeline.FetchInterval,
+		p.pipeline.Timeouts.For(StageFetcher),
+		p.state,
 		errsCh,
-		[]chan<- entity.Message{labelerChan, analyzerChan},
+		[]chan<- entity.Message{fetchedChan},
 	)
-	labelerJob := NewLabelerJob(p.mailClient, errsCh, labelerChan)
-	analyzerJob := NewAnalyzerJob(
-		p.analyzerClient,
-		p.labelsMapper,
-		errsCh,
-		analyzerChan,
-		[]chan<- entity.Message{summarizerChan, labelerChan},
-	)
-	summarizerJob := NewSummarizerJob(p.summarizer, errsCh, summarizerChan, senderChan)
-	senderJob := NewSenderJob(p.sender, errsCh, senderChan)
 
 	var (
-		jobsWg syncs.WaitGroup
-		jobs   = []Job{
-			fetcherJob, labelerJob, analyzerJob, summarizerJob, senderJob,
-		}
+		jobsWg   syncs.WaitGroup
+		ingestWg syncs.WaitGroup
+		topology = p.pipeline.Topology
+		byName   = make(map[string]*durableStage, len(topology.Stages))
+		stages   = make([]*durableStage, 0, len(topology.Stages))
+		entry    = make([]*durableStage, 0, len(topology.Entry))
 	)
 
-	for _, j := range jobs {
-		job := j
+	for _, spec := range topology.Stages {
+		var s *durableStage
+		if custom, ok := p.custom[spec.Name]; ok {
+			s = newFanoutStage(spec.Name, p.store, pluginStage(spec.Name, custom), p.pipeline.Stage(spec.Name), errsCh)
+		} else {
+			s = newDurableStage(spec.Name, p.store, stageKinds[spec.Kind](p), p.pipeline.Stage(spec.Name), errsCh)
+			s.verdicts = spec.Kind == StageSummarizer
+		}
+
+		byName[spec.Name] = s
+		stages = append(stages, s)
+	}
+
+	// verdicts are checked by NewProducer.
+	for _, spec := range topology.Stages {
+		for _, e := range spec.Next {
+			verdicts, _ := parseVerdicts(e.Verdicts)
+			byName[spec.Name].connect(byName[e.Stage], verdicts...)
+		}
+	}
+
+	for _, name := range topology.Entry {
+		entry = append(entry, byName[name])
+	}
+
+	// the processed label is applied by the final stage, after the
+	// message passed all stages, any of them can complete the pipeline.
+	final := byName[topology.Final]
+	for _, s := range stages {
+		s.final = final
+	}
+
+	for _, s := range stages {
+		stage := s
 
 		jobsWg.Go(func() {
-			zap.S().Infof("starting %T", job)
-			job.Run(ctx)
+			zap.S().Infof("starting %s stage", stage.name)
+			stage.Run(ctx)
 		})
 	}
 
+	// fetched messages are persisted before any processing,
+	// they're labeled as processed, when the pipeline is completed.
+	ingestWg.Go(func() { ingest(ctx, p.store, errsCh, fetchedChan, p.pipeline.MaxQueued, final, entry...) })
+	jobsWg.Go(func() {
+		defer close(fetchedChan)
+
+		zap.S().Infof("starting %T", fetcherJob)
+		fetcherJob.Run(ctx)
+	})
+
 	go func() {
 		defer func() {
 			zap.S().Info("stopping producer and all channels")
 			close(errsCh)
-			close(labelerChan)
-			close(analyzerChan)
-			close(summarizerChan)
-			close(senderChan)
 		}()
 
 		<-ctx.Done()
 		jobsWg.Wait()
+		ingestWg.Wait()
+
+		for name, s := range p.custom {
+			if closer, ok := s.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					zap.L().Error("failed to close stage", zap.String("stage", name), zap.Error(err))
+				}
+			}
+		}
 	}()
 
 	return errsCh
@@ -0,0 +1,118 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"github.com/fadyat/i4u/internal/config"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// permanentError is an error, which won't go away after retrying,
+// like an empty message body.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks the error as non-retryable, the message goes
+// to the dead-letter queue right away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// statusCoder is implemented by the errors of the senders.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// isRetryable classifies the error of the stage: rate limits, server errors
+// and timeouts are retried, client errors aren't, because the same request
+// will fail again; unknown errors are retried, they are usually network ones.
+func isRetryable(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	if code, ok := statusCode(err); ok {
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+// errorCause is a coarse kind of the error, used as a metric label.
+func errorCause(err error) string {
+	var permanent *permanentError
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &permanent):
+		return "permanent"
+	}
+
+	code, ok := statusCode(err)
+	switch {
+	case !ok:
+		return "other"
+	case code == http.StatusTooManyRequests:
+		return "rate_limited"
+	case code >= http.StatusInternalServerError:
+		return "server"
+	default:
+		return "client"
+	}
+}
+
+// statusCode extracts the HTTP status code of the APIs used by the stages.
+func statusCode(err error) (int, bool) {
+	var (
+		sc        statusCoder
+		gmailErr  *googleapi.Error
+		openaiErr *openai.APIError
+		reqErr    *openai.RequestError
+		tgErr     *tgbotapi.Error
+	)
+
+	switch {
+	case errors.As(err, &sc):
+		return sc.StatusCode(), true
+	case errors.As(err, &gmailErr):
+		return gmailErr.Code, true
+	case errors.As(err, &openaiErr):
+		return openaiErr.HTTPStatusCode, openaiErr.HTTPStatusCode != 0
+	case errors.As(err, &reqErr):
+		return reqErr.HTTPStatusCode, reqErr.HTTPStatusCode != 0
+	case errors.As(err, &tgErr):
+		return tgErr.Code, tgErr.Code != 0
+	}
+
+	return 0, false
+}
+
+// backoff returns a delay before the next attempt, attempt starts from 1.
+func backoff(p config.RetryPolicy, attempt int) time.Duration {
+	delay := float64(p.Backoff) * math.Pow(2, float64(attempt-1))
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	// #nosec G404 -- jitter doesn't need to be cryptographically secure.
+	delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
@@ -0,0 +1,76 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type statusErr int
+
+func (e statusErr) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusErr) StatusCode() int { return int(e) }
+
+func TestIsRetryable(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "rate limited", err: statusErr(http.StatusTooManyRequests), expected: true},
+		{name: "server error", err: fmt.Errorf("send: %w", statusErr(http.StatusBadGateway)), expected: true},
+		{name: "client error", err: statusErr(http.StatusBadRequest), expected: false},
+		{name: "timeout", err: fmt.Errorf("summary: %w", context.DeadlineExceeded), expected: true},
+		{name: "permanent", err: Permanent(errors.New("empty body")), expected: false},
+		{name: "unknown", err: errors.New("connection reset"), expected: true},
+		{
+			name:     "one of the routes failed",
+			err:      errors.Join(errors.New("route tg"), statusErr(http.StatusForbidden)),
+			expected: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, isRetryable(tc.err))
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := config.RetryPolicy{Backoff: time.Second, MaxBackoff: 10 * time.Second, Jitter: 0.5}
+
+	for attempt, expected := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		5: 10 * time.Second,
+	} {
+		delay := backoff(p, attempt)
+		assert.GreaterOrEqual(t, delay, expected/2, attempt)
+		assert.LessOrEqual(t, delay, expected*3/2, attempt)
+	}
+}
+
+func TestNewPipelineError(t *testing.T) {
+	err := newPipelineError(StageSummarizer, "0", fmt.Errorf("summary: %w", statusErr(http.StatusTooManyRequests)))
+
+	assert.Equal(t, ProviderOpenAI, err.Provider)
+	assert.True(t, err.Retryable)
+	assert.Equal(t, "429", err.Cause())
+	assert.Equal(t, "summary: status 429", err.Error())
+
+	err = newPipelineError(StageAnalyzer, "0", Permanent(errors.New("empty body")))
+	assert.Empty(t, err.Provider)
+	assert.False(t, err.Retryable)
+	assert.Equal(t, "permanent", err.Cause())
+}
@@ -3,91 +3,61 @@ package job
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/fadyat/i4u/api"
 	"github.com/fadyat/i4u/internal/entity"
 	"github.com/fadyat/i4u/mocks"
-	"github.com/fadyat/i4u/pkg/syncs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 type senderJobTestcase struct {
 	name          string
-	in            []entity.SummaryMsg
+	in            *entity.SummaryMsg
 	pre           func(t *testing.T, c api.Sender, tc senderJobTestcase)
-	expectedError error
+	expectedError string
 }
 
-func TestSenderJob_Run(t *testing.T) {
+func TestSenderJob_send(t *testing.T) {
 	testCases := []senderJobTestcase{
 		{
 			name: "context deadline",
-			in: []entity.SummaryMsg{
-				*entity.NewSummaryMsg(
-					entity.NewMsg("0", "i4u", "kek", true),
-					"summary",
-				),
-			},
+			in: entity.NewSummaryMsg(
+				entity.NewMsg("0", "i4u", "kek", true),
+				"summary",
+			),
 			pre: func(t *testing.T, c api.Sender, tc senderJobTestcase) {
 				c.(*mocks.Sender).On("Send", mock.Anything, mock.Anything).
-					Run(func(args mock.Arguments) {
-						ctx := args.Get(0).(context.Context)
+					Return(func(ctx context.Context, _ entity.SummaryMessage) error {
 						<-ctx.Done()
-					}).Return(tc.expectedError)
+						return ctx.Err()
+					})
 			},
-			expectedError: context.DeadlineExceeded,
+			expectedError: "failed to send message: context deadline exceeded",
 		},
 		{
 			name: "send success",
-			in: []entity.SummaryMsg{
-				*entity.NewSummaryMsg(
-					entity.NewMsg("0", "i4u", "kek", true),
-					"summary",
-				),
-			},
+			in: entity.NewSummaryMsg(
+				entity.NewMsg("0", "i4u", "kek", true),
+				"summary",
+			),
 			pre: func(t *testing.T, c api.Sender, tc senderJobTestcase) {
-				for _, msg := range tc.in {
-					c.(*mocks.Sender).On("Send", mock.Anything, &msg).
-						Return(nil)
-				}
+				c.(*mocks.Sender).On("Send", mock.Anything, tc.in).
+					Return(nil)
 			},
 		},
 		{
 			name: "send error",
-			in: []entity.SummaryMsg{
-				*entity.NewSummaryMsg(
-					entity.NewMsg("0", "i4u", "kek", true),
-					"summary",
-				),
-			},
-			pre: func(t *testing.T, c api.Sender, tc senderJobTestcase) {
-				for _, msg := range tc.in {
-					c.(*mocks.Sender).On("Send", mock.Anything, &msg).
-						Return(tc.expectedError)
-				}
-			},
-			expectedError: fmt.Errorf("send error"),
-		},
-		{
-			name: "send for multiple messages",
-			in: func() []entity.SummaryMsg {
-				size := 100
-				msgs := make([]entity.SummaryMsg, size)
-				for i := 0; i < size; i++ {
-					msgs[i] = *entity.NewSummaryMsg(
-						entity.NewMsg("0", "i4u", "kek", true),
-						"summary",
-					)
-				}
-
-				return msgs
-			}(),
+			in: entity.NewSummaryMsg(
+				entity.NewMsg("0", "i4u", "kek", true),
+				"summary",
+			),
 			pre: func(t *testing.T, c api.Sender, tc senderJobTestcase) {
-				c.(*mocks.Sender).On("Send", mock.Anything, mock.Anything).
-					Return(nil)
+				c.(*mocks.Sender).On("Send", mock.Anything, tc.in).
+					Return(errors.New("send error"))
 			},
+			expectedError: "failed to send message: send error",
 		},
 	}
 
@@ -100,36 +70,16 @@ func TestSenderJob_Run(t *testing.T) {
 			sender := mocks.NewSender(t)
 			tc.pre(t, sender, tc)
 
-			errCh, in := make(chan error), make(chan entity.SummaryMsg)
-			defer close(in)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
 
-			var (
-				jobWg              syncs.WaitGroup
-				inputWg            syncs.WaitGroup
-				jobContext, cancel = context.WithCancel(context.Background())
-			)
-			jobWg.Go(func() {
-				defer close(errCh)
-
-				NewSenderJob(sender, errCh, in).Run(jobContext)
-			})
-
-			for _, msg := range tc.in {
-				m := msg
-				inputWg.Go(func() { in <- m })
+			err := (&SenderJob{client: sender}).send(ctx, tc.in)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
 			}
 
-			go func() {
-				for err := range errCh {
-					if !errors.Is(err, tc.expectedError) {
-						assert.NoError(t, err)
-					}
-				}
-			}()
-
-			inputWg.Wait()
-			cancel()
-			jobWg.Wait()
+			assert.NoError(t, err)
 		})
 	}
 }
@@ -0,0 +1,102 @@
+package job
+
+import (
+	"github.com/fadyat/i4u/internal/metrics"
+	"sync"
+	"time"
+)
+
+// Status is a snapshot of the pipeline State.
+type Status struct {
+	StartedAt time.Time
+	Paused    bool
+
+	// LastFetch is a time of the last fetch without errors,
+	// zero if there were no successful fetches yet.
+	LastFetch time.Time
+
+	// LastError is an error of the last failed fetch, it's
+	// cleared after the next successful fetch.
+	LastError string
+
+	// LastTick is a time, when the fetcher woke up the last time,
+	// Fetching is set, while the fetched messages are being ingested.
+	LastTick time.Time
+	Fetching bool
+}
+
+// State is a runtime state of the pipeline, shared between the jobs
+// and the outside world, allowing to observe and control the pipeline.
+type State struct {
+	mu     sync.RWMutex
+	status Status
+
+	// fetchNow asks the fetcher to fetch without waiting for the next tick.
+	fetchNow chan struct{}
+}
+
+func NewState() *State {
+	return &State{
+		status:   Status{StartedAt: time.Now()},
+		fetchNow: make(chan struct{}, 1),
+	}
+}
+
+// FetchNow asks the fetcher to fetch the messages immediately, even when
+// it's paused; the request is merged with the one, which isn't started yet.
+func (s *State) FetchNow() {
+	signal(s.fetchNow)
+}
+
+// Pause stops fetching new messages, messages already
+// in the pipeline are processed as usual.
+func (s *State) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Paused = true
+}
+
+func (s *State) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Paused = false
+}
+
+func (s *State) IsPaused() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.status.Paused
+}
+
+func (s *State) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.status
+}
+
+func (s *State) tick(at time.Time, fetching bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.LastTick, s.status.Fetching = at, fetching
+}
+
+func (s *State) fetched(at time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Fetching = false
+
+	if err != nil {
+		s.status.LastError = err.Error()
+		return
+	}
+
+	s.status.LastFetch = at
+	s.status.LastError = ""
+	metrics.LastFetch.Set(float64(at.Unix()))
+}
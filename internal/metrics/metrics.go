@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "i4u"
+
+// Registry keeps all metrics of the application, it's separate from the
+// global one, so only the metrics below are exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Processed counts messages, which passed the stage successfully.
+	Processed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stage_messages_total",
+		Help:      "Messages processed by the pipeline stage.",
+	}, []string{"stage"})
+
+	// Errors counts failed attempts, the cause is a coarse
+	// kind of the error, like timeout or rate_limited.
+	Errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stage_errors_total",
+		Help:      "Failed attempts of the pipeline stage by the cause.",
+	}, []string{"stage", "cause"})
+
+	Duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stage_duration_seconds",
+		Help:      "Time of a single attempt of the pipeline stage.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"stage"})
+
+	Verdicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "verdicts_total",
+		Help:      "Summarized messages by the verdict.",
+	}, []string{"verdict"})
+
+	// LLMTokens counts tokens reported by the LLM provider,
+	// kind is either prompt or completion.
+	LLMTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens used by the LLM calls.",
+	}, []string{"kind"})
+
+	LastFetch = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_fetch_timestamp_seconds",
+		Help:      "Time of the last fetch without errors.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		Processed, Errors, Duration, Verdicts, LLMTokens, LastFetch,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the Prometheus format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
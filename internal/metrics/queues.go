@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var queueDepthDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "queue_depth"),
+	"Messages waiting in the queue of the pipeline stage, dlq is the dead-letter queue.",
+	[]string{"account", "queue"}, nil,
+)
+
+// queues reads the depths of the persistent queues on every scrape,
+// so they are never out of sync with the storage.
+type queues struct {
+	// stores of the accounts, each account has its own queues.
+	stores map[string]*storage.Storage
+	stages []string
+}
+
+// RegisterQueues exposes the depths of the stage queues and the
+// dead-letter queue of every account.
+func RegisterQueues(stores map[string]*storage.Storage, stages ...string) error {
+	return Registry.Register(&queues{stores: stores, stages: stages})
+}
+
+func (q *queues) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (q *queues) Collect(ch chan<- prometheus.Metric) {
+	for account, store := range q.stores {
+		for _, stage := range q.stages {
+			if n, err := store.QueueLen(stage); err == nil {
+				ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(n), account, stage)
+			}
+		}
+
+		if n, err := store.DeadLettersLen(); err == nil {
+			ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(n), account, "dlq")
+		}
+	}
+}
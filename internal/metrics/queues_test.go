@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/fadyat/i4u/internal/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQueues_Collect(t *testing.T) {
+	store, err := storage.Open(filepath.Join(t.TempDir(), "i4u.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	require.NoError(t, store.Enqueue(entity.NewMsg("0", "i4u", "kek", true), "analyzer", "labeler"))
+	require.NoError(t, store.Enqueue(entity.NewMsg("1", "i4u", "kek", true), "analyzer"))
+	_, err = store.DeadLetter("analyzer", "1|i4u", 1, "failed")
+	require.NoError(t, err)
+
+	expected := `
+# HELP i4u_queue_depth Messages waiting in the queue of the pipeline stage, dlq is the dead-letter queue.
+# TYPE i4u_queue_depth gauge
+i4u_queue_depth{account="default",queue="analyzer"} 1
+i4u_queue_depth{account="default",queue="dlq"} 1
+i4u_queue_depth{account="default",queue="labeler"} 1
+i4u_queue_depth{account="default",queue="sender"} 0
+`
+
+	q := &queues{stores: map[string]*storage.Storage{"default": store}, stages: []string{"analyzer", "labeler", "sender"}}
+	require.NoError(t, testutil.CollectAndCompare(q, strings.NewReader(expected)))
+}
@@ -0,0 +1,41 @@
+package render
+
+import (
+	"strings"
+	"text/template"
+)
+
+// funcs are available in all templates, in addition to the built-in ones.
+var funcs = template.FuncMap{
+	"default":  orDefault,
+	"truncate": truncate,
+	"slack":    EscapeSlack,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+}
+
+// orDefault is used as `{{ default "—" .Fields.Vacancy }}`.
+func orDefault(def, s string) string {
+	if s == "" {
+		return def
+	}
+
+	return s
+}
+
+// truncate cuts the string to the limit of runes.
+func truncate(limit int, s string) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+
+	return string(r[:limit-1]) + "…"
+}
+
+// EscapeSlack escapes control characters of Slack markup.
+//
+// https://api.slack.com/reference/surfaces/formatting#escaping
+func EscapeSlack(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
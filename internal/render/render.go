@@ -0,0 +1,157 @@
+package render
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Kind is a kind of the rendered message, each sender has
+// its own template for every kind it supports.
+type Kind string
+
+const (
+	KindSummary  Kind = "summary"
+	KindAlert    Kind = "alert"
+	KindDigest   Kind = "digest"
+	KindReminder Kind = "reminder"
+)
+
+// Format defines the template engine, html templates escape the
+// data automatically, text templates output it as is.
+type Format string
+
+const (
+	FormatText Format = "txt"
+	FormatHTML Format = "html"
+)
+
+//go:embed templates
+var defaults embed.FS
+
+// Message is a data available in the summary, alert and reminder templates.
+type Message struct {
+	ID   string
+	Link string
+
+	// Body is a plain text of the original message.
+	Body string
+
+	// Text is a summary as it was returned by the summarizer.
+	Text    string
+	Fields  entity.SummaryFields
+	Lines   []entity.SummaryLine
+	Verdict entity.Verdict
+
+	// Error is set only for alerts.
+	Error string
+	At    time.Time
+}
+
+func NewMessage(msg entity.SummaryMessage) Message {
+	summary, ok := msg.(*entity.SummaryMsg)
+	if !ok {
+		return Message{Error: msg.Summary(), Verdict: entity.VerdictUnknown, At: time.Now()}
+	}
+
+	return Message{
+		ID:      summary.ID(),
+		Link:    summary.Link(),
+		Body:    summary.Body(),
+		Text:    summary.Text(),
+		Fields:  summary.Fields(),
+		Lines:   entity.ParseSummaryLines(summary.Text()),
+		Verdict: summary.Verdict(),
+		At:      time.Now(),
+	}
+}
+
+// DigestGroup is a group of messages with the same verdict.
+type DigestGroup struct {
+	Title    string
+	Verdict  entity.Verdict
+	Messages []Message
+}
+
+// Digest is a data available in the digest templates.
+type Digest struct {
+	Date   time.Time
+	Total  int
+	Groups []DigestGroup
+}
+
+// Renderer renders messages using the templates, named as
+// <sender>/<kind>.<format>; templates from the directory take
+// precedence over the built-in ones.
+//
+// Templates from the directory are parsed on every render,
+// so the layout can be changed without restarting.
+type Renderer struct {
+	dir string
+}
+
+// New creates a renderer, which looks up templates in the dir first,
+// an empty dir means only built-in templates are used.
+func New(dir string) *Renderer {
+	return &Renderer{dir: dir}
+}
+
+// Default uses only built-in templates.
+var Default = New("")
+
+func (r *Renderer) Render(sender string, kind Kind, format Format, data any) (string, error) {
+	name := fmt.Sprintf("%s/%s.%s", sender, kind, format)
+
+	content, err := r.read(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatHTML:
+		t, e := htmltemplate.New(name).Funcs(funcs).Parse(string(content))
+		if e != nil {
+			return "", fmt.Errorf("failed to parse template %s: %w", name, e)
+		}
+
+		err = t.Execute(&buf, data)
+	default:
+		t, e := texttemplate.New(name).Funcs(funcs).Parse(string(content))
+		if e != nil {
+			return "", fmt.Errorf("failed to parse template %s: %w", name, e)
+		}
+
+		err = t.Execute(&buf, data)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func (r *Renderer) read(name string) ([]byte, error) {
+	if r.dir != "" {
+		content, err := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(name)))
+		if err == nil {
+			return content, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return defaults.ReadFile("templates/" + name)
+}
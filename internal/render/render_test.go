@@ -0,0 +1,100 @@
+package render
+
+import (
+	"errors"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestMessage() Message {
+	return NewMessage(entity.NewSummaryMsg(
+		entity.NewMsg("1", "i4u", "kek", true),
+		"🏢 Company: Acme <Corp>\n📝 Vacancy: Intern & Co\n🤔 Verdict: Reject",
+	))
+}
+
+func TestRenderer_Render(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sender   string
+		kind     Kind
+		format   Format
+		data     any
+		expected string
+	}{
+		{
+			name:     "tg summary",
+			sender:   "tg",
+			kind:     KindSummary,
+			format:   FormatHTML,
+			data:     newTestMessage(),
+			expected: "<b>🏢 Company: Acme &lt;Corp&gt;</b>\n📝 Vacancy: Intern &amp; Co\n🤔 Verdict: Reject",
+		},
+		{
+			name:     "tg alert",
+			sender:   "tg",
+			kind:     KindAlert,
+			format:   FormatHTML,
+			data:     NewMessage(entity.NewAlertMsg(errors.New("a < b"))),
+			expected: "<b>⚠️ Alert</b>\na &lt; b",
+		},
+		{
+			name:     "slack summary",
+			sender:   "slack",
+			kind:     KindSummary,
+			format:   FormatText,
+			data:     NewMessage(entity.NewSummaryMsg(entity.NewMsg("1", "i4u", "kek", true), "Acme <Corp>")),
+			expected: "Acme &lt;Corp&gt;",
+		},
+		{
+			name:   "email digest",
+			sender: "email",
+			kind:   KindDigest,
+			format: FormatText,
+			data: Digest{Total: 1, Groups: []DigestGroup{
+				{Title: "⛔ Rejections", Messages: []Message{newTestMessage()}},
+			}},
+			expected: "⛔ Rejections (1)\n\n- Acme <Corp>, Intern & Co: —",
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			text, err := Default.Render(tc.sender, tc.kind, tc.format, tc.data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, text)
+		})
+	}
+}
+
+func TestRenderer_RenderOverride(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tg"), 0o755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "tg", "summary.html"),
+		[]byte(`{{ upper .Fields.Company }} — {{ default "—" .Fields.Reason }}`),
+		0o600,
+	))
+
+	r := New(dir)
+
+	text, err := r.Render("tg", KindSummary, FormatHTML, newTestMessage())
+	require.NoError(t, err)
+	assert.Equal(t, "ACME &lt;CORP&gt; — —", text)
+
+	// missing templates are taken from the built-in ones.
+	text, err = r.Render("tg", KindReminder, FormatHTML, Message{})
+	require.NoError(t, err)
+	assert.Equal(t, "⏰ Reminder, you asked to come back to this one", text)
+
+	_, err = r.Render("tg", "unknown", FormatHTML, Message{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// sealedMagic starts the sealed data, so it isn't confused with the
+// plain one, like the tokens saved before the encryption was enabled.
+var sealedMagic = []byte("i4u-sealed-v1\n")
+
+// the parameters of scrypt, recommended for the interactive logins,
+// the key is derived once per reading or writing the file.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	keyLen  = 32
+	saltLen = 16
+)
+
+// ErrWrongKey is returned, when the data is sealed by another key,
+// or it's corrupted.
+var ErrWrongKey = errors.New("wrong passphrase or key file, or the data is corrupted")
+
+// Key seals the data with AES-256-GCM, the encryption key is derived
+// by scrypt from the passphrase or the content of the key file, with
+// the random salt, kept with the sealed data.
+type Key struct {
+	material []byte
+}
+
+func NewKey(material []byte) (*Key, error) {
+	if len(bytes.TrimSpace(material)) == 0 {
+		return nil, errors.New("empty passphrase or key file")
+	}
+
+	return &Key{material: material}, nil
+}
+
+// IsSealed tells, whether the data is sealed by any key.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, sealedMagic)
+}
+
+// Seal encrypts the data, the result is: magic, salt, nonce and
+// the ciphertext with the authentication tag.
+func (k *Key) Seal(plain []byte) ([]byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := k.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, 0, len(sealedMagic)+saltLen+len(nonce)+len(plain)+aead.Overhead())
+	sealed = append(sealed, sealedMagic...)
+	sealed = append(sealed, salt...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, plain, sealedMagic), nil
+}
+
+// Open decrypts the data, sealed by the same key.
+func (k *Key) Open(sealed []byte) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, errors.New("data isn't sealed")
+	}
+
+	data := sealed[len(sealedMagic):]
+	if len(data) < saltLen {
+		return nil, ErrWrongKey
+	}
+
+	aead, err := k.aead(data[:saltLen])
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[saltLen:]
+	if len(data) < aead.NonceSize() {
+		return nil, ErrWrongKey
+	}
+
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], sealedMagic)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+
+	return plain, nil
+}
+
+func (k *Key) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(k.material, salt, scryptN, scryptR, scryptP, keyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
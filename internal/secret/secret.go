@@ -0,0 +1,69 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The schemes of the references to the secrets.
+const (
+	schemeFile  = "file:"
+	schemeVault = "vault:"
+)
+
+// Resolver reads the secrets by the references, so they aren't kept
+// in the config file and the environment as is:
+//   - file:/run/secrets/openai_key reads the file, like the secrets,
+//     mounted by Docker and Kubernetes;
+//   - vault:i4u#openai_key reads the key of the secret from the Vault.
+//
+// The values without the scheme aren't references, they are kept as is.
+type Resolver struct {
+	vault *Vault
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+// WithVault enables the vault references.
+func (r *Resolver) WithVault(v *Vault) *Resolver {
+	r.vault = v
+	return r
+}
+
+// Resolve returns the secret by the reference, or the value itself.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, schemeFile):
+		return ReadFile(strings.TrimPrefix(value, schemeFile))
+	case strings.HasPrefix(value, schemeVault):
+		if r.vault == nil {
+			return "", errors.New("vault isn't configured, vault.addr is required")
+		}
+
+		path, key, ok := strings.Cut(strings.TrimPrefix(value, schemeVault), "#")
+		if !ok || path == "" || key == "" {
+			return "", fmt.Errorf("invalid reference %q, expected vault:<path>#<key>", value)
+		}
+
+		return r.vault.Read(ctx, path, key)
+	}
+
+	return value, nil
+}
+
+// ReadFile reads the secret from the file, the trailing line breaks
+// are dropped, as they are usually added by the editors and echo.
+func ReadFile(path string) (string, error) {
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
@@ -0,0 +1,98 @@
+package secret
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	key, err := NewKey([]byte("correct horse"))
+	require.NoError(t, err)
+
+	sealed, err := key.Seal([]byte(`{"access_token":"kek"}`))
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "kek")
+
+	plain, err := key.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"access_token":"kek"}`, string(plain))
+
+	other, err := NewKey([]byte("battery staple"))
+	require.NoError(t, err)
+	_, err = other.Open(sealed)
+	assert.ErrorIs(t, err, ErrWrongKey)
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = key.Open(sealed)
+	assert.ErrorIs(t, err, ErrWrongKey)
+
+	_, err = NewKey([]byte(" \n"))
+	assert.Error(t, err)
+}
+
+func TestResolver(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		if r.URL.Path != "/v1/secret/data/i4u" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"data":{"data":{"openai_key":"sk-vault","tg_token":"1:vault"}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "openai_key")
+	require.NoError(t, os.WriteFile(path, []byte("sk-file\n"), 0o600))
+
+	ctx := context.Background()
+	r := NewResolver()
+
+	value, err := r.Resolve(ctx, "sk-plain")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-plain", value)
+
+	value, err = r.Resolve(ctx, "file:"+path)
+	require.NoError(t, err)
+	assert.Equal(t, "sk-file", value)
+
+	_, err = r.Resolve(ctx, "vault:i4u#openai_key")
+	assert.EqualError(t, err, "vault isn't configured, vault.addr is required")
+
+	r.WithVault(NewVault(srv.Client(), srv.URL, "root", "secret"))
+	value, err = r.Resolve(ctx, "vault:i4u#openai_key")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-vault", value)
+
+	// the keys of the same secret are read with a single request.
+	value, err = r.Resolve(ctx, "vault:i4u#tg_token")
+	require.NoError(t, err)
+	assert.Equal(t, "1:vault", value)
+	assert.Equal(t, 1, requests)
+
+	_, err = r.Resolve(ctx, "vault:i4u#kek")
+	assert.EqualError(t, err, "vault: key kek isn't found in i4u")
+
+	_, err = r.Resolve(ctx, "vault:missing#kek")
+	assert.EqualError(t, err, "vault: missing: 404 Not Found")
+
+	_, err = r.Resolve(ctx, "vault:i4u")
+	assert.EqualError(t, err, `invalid reference "vault:i4u", expected vault:<path>#<key>`)
+
+	_, err = NewResolver().WithVault(NewVault(srv.Client(), srv.URL, "kek", "secret")).Resolve(ctx, "vault:i4u#openai_key")
+	assert.EqualError(t, err, "vault: i4u: 403 Forbidden: permission denied")
+}
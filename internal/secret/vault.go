@@ -0,0 +1,107 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Vault reads the secrets from the KV v2 engine of HashiCorp Vault, or
+// any server with the same API, like a local stub:
+//
+//	GET /v1/<mount>/data/<path>
+//	X-Vault-Token: <token>
+//
+//	{"data": {"data": {"openai_key": "sk-..."}}}
+//
+// The secrets are cached by the path, so the keys of the same secret
+// are read with a single request.
+type Vault struct {
+	c     *http.Client
+	addr  string
+	token string
+	mount string
+
+	mu    sync.Mutex
+	cache map[string]map[string]string
+}
+
+func NewVault(c *http.Client, addr, token, mount string) *Vault {
+	return &Vault{
+		c:     c,
+		addr:  strings.TrimSuffix(addr, "/"),
+		token: token,
+		mount: strings.Trim(mount, "/"),
+		cache: make(map[string]map[string]string),
+	}
+}
+
+// Read returns the value by the key of the secret at the path.
+func (v *Vault) Read(ctx context.Context, path, key string) (string, error) {
+	data, err := v.secret(ctx, strings.Trim(path, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault: key %s isn't found in %s", key, path)
+	}
+
+	return value, nil
+}
+
+func (v *Vault) secret(ctx context.Context, path string) (map[string]string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if data, ok := v.cache[path]; ok {
+		return data, nil
+	}
+
+	u, err := url.JoinPath(v.addr, "v1", v.mount, "data", path)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var body struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+
+	// the errors are optional, the body of the failed request
+	// may be empty, like for the missing secrets.
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusOK {
+		if len(body.Errors) > 0 {
+			return nil, fmt.Errorf("vault: %s: %s: %s", path, resp.Status, strings.Join(body.Errors, "; "))
+		}
+
+		return nil, fmt.Errorf("vault: %s: %s", path, resp.Status)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("vault: failed to decode %s: %w", path, err)
+	}
+
+	v.cache[path] = body.Data.Data
+	return body.Data.Data, nil
+}
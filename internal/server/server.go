@@ -0,0 +1,289 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/job"
+	"github.com/fadyat/i4u/internal/metrics"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// checkTimeout limits a single check of the readiness probe.
+const checkTimeout = 5 * time.Second
+
+// Check is a dependency of the application, which is probed by /readyz.
+type Check struct {
+	Name string
+	Fn   func(context.Context) error
+}
+
+// Server is an embedded HTTP server of the running pipeline, it serves:
+//   - /metrics with the Prometheus metrics;
+//   - /healthz, which fails, when the fetcher is stuck;
+//   - /readyz, which fails, when the checks are failing, or there were
+//     no successful fetches for a while;
+//   - /admin/* to control the pipeline, only with the admin token.
+//
+// When several Gmail accounts are served, the probes check the fetchers
+// of all of them, the admin actions are applied to the account from the
+// query, like /admin/pause?account=alice, or to all accounts.
+type Server struct {
+	// states of the fetchers by the accounts, the only
+	// account is kept by the empty name.
+	states   map[string]*job.State
+	interval time.Duration
+	timeout  time.Duration
+	checks   []Check
+
+	// adminToken is expected in the Authorization header of the
+	// admin requests, admin endpoints are disabled without it.
+	adminToken string
+
+	now func() time.Time
+}
+
+// New creates the server, the fetch interval and timeout tell,
+// when the fetcher is considered stuck.
+func New(state *job.State, interval, timeout time.Duration) *Server {
+	return &Server{
+		states:   map[string]*job.State{"": state},
+		interval: interval,
+		timeout:  timeout,
+		now:      time.Now,
+	}
+}
+
+// WithAccounts replaces the state given to New with the states of the
+// fetchers of the accounts.
+func (s *Server) WithAccounts(states map[string]*job.State) *Server {
+	s.states = states
+	return s
+}
+
+func (s *Server) WithChecks(checks ...Check) *Server {
+	s.checks = append(s.checks, checks...)
+	return s
+}
+
+func (s *Server) WithAdminToken(token string) *Server {
+	s.adminToken = token
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+
+	if s.adminToken != "" {
+		mux.Handle("/admin/pause", s.admin(post(s.each(http.StatusOK, map[string]any{"paused": true}, (*job.State).Pause))))
+		mux.Handle("/admin/resume", s.admin(post(s.each(http.StatusOK, map[string]any{"paused": false}, (*job.State).Resume))))
+		mux.Handle("/admin/fetch", s.admin(post(s.each(http.StatusAccepted, map[string]any{"fetching": true}, (*job.State).FetchNow))))
+		mux.Handle("/admin/flags", s.admin(s.flags))
+	}
+
+	return mux
+}
+
+// Run serves the requests until the context is done.
+func (s *Server) Run(ctx context.Context, addr string) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			zap.L().Error("failed to shutdown http server", zap.Error(err))
+		}
+	}()
+
+	zap.S().Infof("serving metrics, health checks and admin endpoints on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		zap.L().Error("failed to serve http", zap.Error(err))
+	}
+}
+
+// stale is a period, after which the fetcher is considered stuck, the
+// fetch itself may take the whole timeout, a few ticks may be missed.
+func (s *Server) stale() time.Duration {
+	return 3*s.interval + s.timeout
+}
+
+func (s *Server) healthz(w http.ResponseWriter, _ *http.Request) {
+	for name, state := range s.states {
+		st := state.Status()
+
+		// the fetcher may wait for the slow stages as long as needed,
+		// only the fetcher, which stopped ticking at all, is stuck.
+		last := st.LastTick
+		if last.IsZero() {
+			last = st.StartedAt
+		}
+
+		if !st.Fetching && s.now().Sub(last) > s.stale() {
+			reply(w, http.StatusServiceUnavailable, map[string]any{
+				"status": fmt.Sprintf("%s didn't tick for %s", fetcherName(name), s.now().Sub(last).Round(time.Second)),
+			})
+			return
+		}
+	}
+
+	reply(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	var (
+		results = make(map[string]string, len(s.checks)+len(s.states))
+		ready   = true
+	)
+
+	for _, c := range s.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		err := c.Fn(ctx)
+		cancel()
+
+		if err != nil {
+			results[c.Name], ready = err.Error(), false
+			continue
+		}
+
+		results[c.Name] = "ok"
+	}
+
+	for name, state := range s.states {
+		key := "fetch"
+		if name != "" {
+			key += ":" + name
+		}
+
+		results[key] = "ok"
+		if st := state.Status(); !st.Paused {
+			last := st.LastFetch
+			if last.IsZero() {
+				last = st.StartedAt
+			}
+
+			if s.now().Sub(last) > s.stale() {
+				results[key] = fmt.Sprintf("no successful fetch for %s", s.now().Sub(last).Round(time.Second))
+				if st.LastError != "" {
+					results[key] += ": " + st.LastError
+				}
+
+				ready = false
+			}
+		}
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	reply(w, status, map[string]any{"ready": ready, "checks": results})
+}
+
+// each applies the action to the fetcher of the account from the
+// query, or to the fetchers of all accounts without it.
+func (s *Server) each(status int, body map[string]any, action func(*job.State)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account := r.URL.Query().Get("account")
+		if account == "" {
+			for _, state := range s.states {
+				action(state)
+			}
+
+			reply(w, status, body)
+			return
+		}
+
+		state, ok := s.states[account]
+		if !ok {
+			reply(w, http.StatusNotFound, map[string]any{"error": "unknown account: " + account})
+			return
+		}
+
+		action(state)
+		reply(w, status, body)
+	}
+}
+
+func fetcherName(account string) string {
+	if account == "" {
+		return "fetcher"
+	}
+
+	return "fetcher of " + account
+}
+
+// flags shows the feature flags on GET, or toggles the one given in the
+// query on POST: /admin/flags?name=summarizer&enabled=false
+func (s *Server) flags(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		reply(w, http.StatusOK, config.Features().Map())
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		reply(w, http.StatusMethodNotAllowed, map[string]any{"error": "only GET and POST are allowed"})
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+	if err != nil {
+		reply(w, http.StatusBadRequest, map[string]any{"error": "enabled must be a boolean"})
+		return
+	}
+
+	if err = config.SetFeature(name, enabled); err != nil {
+		reply(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	zap.S().Infof("feature %s is toggled to %t", name, enabled)
+	reply(w, http.StatusOK, config.Features().Map())
+}
+
+// admin allows only the requests with the admin token.
+func (s *Server) admin(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := "Bearer " + s.adminToken
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+			reply(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// post allows only POST requests, the actions aren't triggered by crawlers.
+func post(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			reply(w, http.StatusMethodNotAllowed, map[string]any{"error": "only POST is allowed"})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func reply(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		zap.L().Error("failed to write response", zap.Error(err))
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/fadyat/i4u/internal/config"
+	"github.com/fadyat/i4u/internal/job"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func do(t *testing.T, h http.Handler, method, target, token string) (int, map[string]any) {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestServer_Probes(t *testing.T) {
+	gmailErr := errors.New("gmail is unreachable")
+
+	testCases := []struct {
+		name          string
+		elapsed       time.Duration
+		paused        bool
+		checkErr      error
+		expectedAlive int
+		expectedReady int
+	}{
+		{name: "just started", expectedAlive: http.StatusOK, expectedReady: http.StatusOK},
+		{
+			name:          "check failed",
+			checkErr:      gmailErr,
+			expectedAlive: http.StatusOK,
+			expectedReady: http.StatusServiceUnavailable,
+		},
+		{
+			name:          "fetcher is stuck",
+			elapsed:       time.Hour,
+			expectedAlive: http.StatusServiceUnavailable,
+			expectedReady: http.StatusServiceUnavailable,
+		},
+		{
+			name:          "paused",
+			elapsed:       time.Hour,
+			paused:        true,
+			expectedAlive: http.StatusServiceUnavailable,
+			expectedReady: http.StatusOK,
+		},
+	}
+
+	for _, tt := range testCases {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			state := job.NewState()
+			if tc.paused {
+				state.Pause()
+			}
+
+			srv := New(state, time.Minute, 5*time.Second).WithChecks(Check{
+				Name: "gmail",
+				Fn:   func(context.Context) error { return tc.checkErr },
+			})
+			srv.now = func() time.Time { return time.Now().Add(tc.elapsed) }
+			h := srv.Handler()
+
+			code, _ := do(t, h, http.MethodGet, "/healthz", "")
+			assert.Equal(t, tc.expectedAlive, code)
+
+			code, body := do(t, h, http.MethodGet, "/readyz", "")
+			assert.Equal(t, tc.expectedReady, code)
+			if tc.checkErr != nil {
+				assert.Equal(t, tc.checkErr.Error(), body["checks"].(map[string]any)["gmail"])
+			}
+		})
+	}
+}
+
+func TestServer_Admin(t *testing.T) {
+	state := job.NewState()
+	h := New(state, time.Minute, 5*time.Second).WithAdminToken("secret").Handler()
+
+	code, _ := do(t, h, http.MethodPost, "/admin/pause", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.False(t, state.IsPaused())
+
+	code, _ = do(t, h, http.MethodGet, "/admin/pause", "secret")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, _ = do(t, h, http.MethodPost, "/admin/pause", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, state.IsPaused())
+
+	code, _ = do(t, h, http.MethodPost, "/admin/resume", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, state.IsPaused())
+
+	code, _ = do(t, h, http.MethodPost, "/admin/fetch", "secret")
+	assert.Equal(t, http.StatusAccepted, code)
+}
+
+func TestServer_Flags(t *testing.T) {
+	config.FeatureFlags = config.Flags{IsSummarizerJobEnabled: true}
+	h := New(job.NewState(), time.Minute, 5*time.Second).WithAdminToken("secret").Handler()
+
+	code, body := do(t, h, http.MethodPost, "/admin/flags?name=summarizer&enabled=false", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, body["summarizer"])
+	assert.False(t, config.Features().IsSummarizerJobEnabled)
+
+	code, _ = do(t, h, http.MethodPost, "/admin/flags?name=unknown&enabled=false", "secret")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = do(t, h, http.MethodGet, "/admin/flags", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, body, 4)
+}
+
+func TestServer_AdminDisabled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	New(job.NewState(), time.Minute, 5*time.Second).Handler().
+		ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/pause", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_Accounts(t *testing.T) {
+	alice, bob := job.NewState(), job.NewState()
+	bob.Pause()
+
+	srv := New(alice, time.Minute, 5*time.Second).
+		WithAccounts(map[string]*job.State{"alice": alice, "bob": bob}).
+		WithAdminToken("secret")
+	srv.now = func() time.Time { return time.Now().Add(time.Hour) }
+	h := srv.Handler()
+
+	// the stuck fetcher of one account fails the probes.
+	code, body := do(t, h, http.MethodGet, "/readyz", "")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	checks := body["checks"].(map[string]any)
+	assert.Contains(t, checks["fetch:alice"], "no successful fetch")
+	assert.Equal(t, "ok", checks["fetch:bob"])
+
+	code, _ = do(t, h, http.MethodPost, "/admin/resume?account=bob", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, bob.IsPaused())
+
+	code, _ = do(t, h, http.MethodPost, "/admin/pause?account=eve", "secret")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = do(t, h, http.MethodPost, "/admin/pause", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, alice.IsPaused())
+	assert.True(t, bob.IsPaused())
+
+	code, _ = do(t, h, http.MethodGet, "/readyz", "")
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/fadyat/i4u/internal/entity"
+	"go.etcd.io/bbolt"
+	"strings"
+	"time"
+)
+
+var applicationsBucket = []byte("applications")
+
+// ErrNotFound is returned when the requested record doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// ApplicationUpdate is a single summary related to the application.
+type ApplicationUpdate struct {
+	MessageID string         `json:"message_id"`
+	ThreadID  string         `json:"thread_id,omitempty"`
+	Verdict   entity.Verdict `json:"verdict"`
+	Summary   string         `json:"summary"`
+	Link      string         `json:"link"`
+	At        time.Time      `json:"at"`
+}
+
+// Application groups summaries from the same conversation, or the same
+// vacancy of the company, so the history of the application can be tracked.
+type Application struct {
+	Key     string `json:"key"`
+	Company string `json:"company"`
+	Vacancy string `json:"vacancy"`
+
+	// Stage is a verdict of the latest update.
+	Stage   entity.Verdict      `json:"stage"`
+	Updates []ApplicationUpdate `json:"updates"`
+}
+
+// IsOpen reports whether the application is still in progress.
+func (a *Application) IsOpen() bool {
+	return a.Stage != entity.VerdictReject
+}
+
+// LastUpdate returns the latest summary of the application.
+func (a *Application) LastUpdate() ApplicationUpdate {
+	if len(a.Updates) == 0 {
+		return ApplicationUpdate{}
+	}
+
+	return a.Updates[len(a.Updates)-1]
+}
+
+// ApplicationKey groups summaries by the conversation, summaries without it
+// are grouped by the company and the vacancy, like the replies, which are
+// sent as new emails; summaries without company can't be grouped, so each
+// of them is a separate application.
+func ApplicationKey(threadID, company, vacancy, msgID string) string {
+	if threadID != "" {
+		return "thread:" + threadID
+	}
+
+	c := strings.ToLower(strings.TrimSpace(company))
+	if c == "" {
+		return "message:" + msgID
+	}
+
+	key := "company:" + c
+	if v := strings.ToLower(strings.TrimSpace(vacancy)); v != "" {
+		key += "|vacancy:" + v
+	}
+
+	return key
+}
+
+// SaveApplicationUpdate adds the update to the application, creating
+// it when it doesn't exist, the stage is moved to the update verdict.
+func (s *Storage) SaveApplicationUpdate(
+	company, vacancy string, u *ApplicationUpdate,
+) (*Application, error) {
+	key := ApplicationKey(u.ThreadID, company, vacancy, u.MessageID)
+
+	var app Application
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b, e := tx.CreateBucketIfNotExists(applicationsBucket)
+		if e != nil {
+			return e
+		}
+
+		if content := b.Get([]byte(key)); content != nil {
+			if e = json.Unmarshal(content, &app); e != nil {
+				return e
+			}
+		}
+
+		app.Key, app.Stage = key, u.Verdict
+		app.Updates = append(app.Updates, *u)
+		if app.Company == "" {
+			app.Company = company
+		}
+
+		if vacancy != "" {
+			app.Vacancy = vacancy
+		}
+
+		content, e := json.Marshal(&app)
+		if e != nil {
+			return e
+		}
+
+		return b.Put([]byte(key), content)
+	})
+
+	return &app, err
+}
+
+// SetApplicationStage corrects the verdict of the update with the message id,
+// stage of the application is changed, only if it's the latest update.
+func (s *Storage) SetApplicationStage(msgID string, verdict entity.Verdict) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(applicationsBucket)
+		if b == nil {
+			return ErrNotFound
+		}
+
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var app Application
+			if e := json.Unmarshal(v, &app); e != nil {
+				return e
+			}
+
+			for i := range app.Updates {
+				if app.Updates[i].MessageID != msgID {
+					continue
+				}
+
+				app.Updates[i].Verdict = verdict
+				if i == len(app.Updates)-1 {
+					app.Stage = verdict
+				}
+
+				content, e := json.Marshal(&app)
+				if e != nil {
+					return e
+				}
+
+				return b.Put(k, content)
+			}
+		}
+
+		return ErrNotFound
+	})
+}
+
+// Applications returns all known applications.
+func (s *Storage) Applications() ([]Application, error) {
+	var apps []Application
+	err := s.each(applicationsBucket, func(_, v []byte) error {
+		var app Application
+		if e := json.Unmarshal(v, &app); e != nil {
+			return e
+		}
+
+		apps = append(apps, app)
+		return nil
+	})
+
+	return apps, err
+}
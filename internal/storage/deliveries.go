@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"errors"
+	"go.etcd.io/bbolt"
+	"time"
+)
+
+var deliveriesBucket = []byte("deliveries")
+
+// Delivered returns the routes, the message was delivered to, while the
+// other routes failed, so they're skipped, when the message is retried.
+func (s *Storage) Delivered(key string) (map[string]bool, error) {
+	routes := make(map[string]bool)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(deliveriesBucket)
+		if b == nil {
+			return nil
+		}
+
+		msg := b.Bucket([]byte(key))
+		if msg == nil {
+			return nil
+		}
+
+		return msg.ForEach(func(k, _ []byte) error {
+			routes[string(k)] = true
+			return nil
+		})
+	})
+
+	return routes, err
+}
+
+// SaveDelivery records the route, the message was delivered to.
+func (s *Storage) SaveDelivery(key, route string) error {
+	at, err := time.Now().MarshalText()
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b, e := tx.CreateBucketIfNotExists(deliveriesBucket)
+		if e != nil {
+			return e
+		}
+
+		msg, e := b.CreateBucketIfNotExists([]byte(key))
+		if e != nil {
+			return e
+		}
+
+		return msg.Put([]byte(route), at)
+	})
+}
+
+// ClearDeliveries forgets the routes of the message, when it's delivered
+// to all of them, or it's never retried again.
+func (s *Storage) ClearDeliveries(key string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return clearDeliveries(tx, key)
+	})
+}
+
+func clearDeliveries(tx *bbolt.Tx, key string) error {
+	b := tx.Bucket(deliveriesBucket)
+	if b == nil {
+		return nil
+	}
+
+	if err := b.DeleteBucket([]byte(key)); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"go.etcd.io/bbolt"
+)
+
+var digestsBucket = []byte("digests")
+
+// DigestEntry is a summary, collected for the next digest, it's
+// kept until the digest is sent.
+type DigestEntry struct {
+	ID      uint64
+	Content json.RawMessage
+}
+
+// SaveDigestEntry stores the entry as json for the next digest with
+// the name, keeping the insertion order.
+func (s *Storage) SaveDigestEntry(digest string, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b, e := tx.CreateBucketIfNotExists(digestsBucket)
+		if e != nil {
+			return e
+		}
+
+		d, e := b.CreateBucketIfNotExists([]byte(digest))
+		if e != nil {
+			return e
+		}
+
+		seq, e := d.NextSequence()
+		if e != nil {
+			return e
+		}
+
+		return d.Put(itob(seq), content)
+	})
+}
+
+// DigestEntries returns the entries of the digest in the order they were saved.
+func (s *Storage) DigestEntries(digest string) ([]DigestEntry, error) {
+	var entries []DigestEntry
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(digestsBucket)
+		if b == nil {
+			return nil
+		}
+
+		d := b.Bucket([]byte(digest))
+		if d == nil {
+			return nil
+		}
+
+		return d.ForEach(func(k, v []byte) error {
+			entries = append(entries, DigestEntry{
+				ID: binary.BigEndian.Uint64(k), Content: append(json.RawMessage(nil), v...),
+			})
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// DeleteDigestEntries removes the entries of the digest up to the id
+// inclusive, when they're sent; the ones saved after are kept.
+func (s *Storage) DeleteDigestEntries(digest string, upTo uint64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(digestsBucket)
+		if b == nil {
+			return nil
+		}
+
+		d := b.Bucket([]byte(digest))
+		if d == nil {
+			return nil
+		}
+
+		// deleting by the cursor skips the next key.
+		var keys [][]byte
+		c := d.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= upTo; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+
+		for _, k := range keys {
+			if err := d.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
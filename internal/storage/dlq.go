@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"time"
+)
+
+var dlqBucket = []byte("dlq")
+
+// DeadLetter is a message, which failed to pass the stage after all
+// retries, it's kept until it's retried or purged manually.
+type DeadLetter struct {
+	ID       uint64    `json:"id"`
+	Stage    string    `json:"stage"`
+	Item     QueueItem `json:"item"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+
+	// Settled is set, when the message isn't pending anymore, so the
+	// message it was derived from is released to the final stage.
+	Settled bool `json:"settled,omitempty"`
+}
+
+// DeadLetter moves the message from the stage queue to the dead-letter queue,
+// the message isn't pending anymore, so the message it was derived from
+// is labeled as processed and isn't fetched again. Returns true, when
+// the final message was released.
+func (s *Storage) DeadLetter(stage, key string, attempts int, lastErr string) (bool, error) {
+	var released bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		q := tx.Bucket(queueBucket(stage))
+		if q == nil {
+			return ErrNotFound
+		}
+
+		item, err := getQueueItem(q, key)
+		if err != nil {
+			return err
+		}
+
+		item.Attempts, item.NextAttemptAt, item.LastError = attempts, time.Time{}, lastErr
+		dlq, err := tx.CreateBucketIfNotExists(dlqBucket)
+		if err != nil {
+			return err
+		}
+
+		id, err := dlq.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		// the final message itself stays pending, otherwise it's released
+		// again and again, the tracker is removed, when it's purged.
+		dl := &DeadLetter{ID: id, Stage: stage, Item: *item, Error: lastErr, FailedAt: time.Now()}
+		if !isFinal(tx, item) {
+			if released, err = settle(tx, item.Msg.ID(), -1); err != nil {
+				return err
+			}
+
+			dl.Settled = true
+		}
+
+		content, err := json.Marshal(dl)
+		if err != nil {
+			return err
+		}
+
+		if err = dlq.Put(itob(id), content); err != nil {
+			return err
+		}
+
+		return q.Delete([]byte(key))
+	})
+
+	return released, err
+}
+
+// DeadLettersLen returns the number of dead letters.
+func (s *Storage) DeadLettersLen() (int, error) {
+	return s.count(dlqBucket)
+}
+
+// DeadLetters returns all dead letters in the order they failed.
+func (s *Storage) DeadLetters() ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := s.each(dlqBucket, func(_, v []byte) error {
+		var dl DeadLetter
+		if e := json.Unmarshal(v, &dl); e != nil {
+			return e
+		}
+
+		letters = append(letters, dl)
+		return nil
+	})
+
+	return letters, err
+}
+
+// RetryDeadLetter returns the message to the queue of the stage it failed
+// at, attempts are reset, so the message gets the full retry policy again.
+func (s *Storage) RetryDeadLetter(id uint64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		dlq := tx.Bucket(dlqBucket)
+		if dlq == nil {
+			return ErrNotFound
+		}
+
+		content := dlq.Get(itob(id))
+		if content == nil {
+			return ErrNotFound
+		}
+
+		var dl DeadLetter
+		if err := json.Unmarshal(content, &dl); err != nil {
+			return err
+		}
+
+		q, err := tx.CreateBucketIfNotExists(queueBucket(dl.Stage))
+		if err != nil {
+			return err
+		}
+
+		// the message is pending again, until it passes the stage.
+		if dl.Settled {
+			if _, err = settle(tx, dl.Item.Msg.ID(), 1); err != nil {
+				return err
+			}
+		}
+
+		// the retried message is given the whole time again.
+		item := dl.Item
+		item.Attempts, item.NextAttemptAt, item.LastError = 0, time.Time{}, ""
+		item.IngestedAt = time.Now()
+		if err = putQueueItem(q, &item); err != nil {
+			return err
+		}
+
+		return dlq.Delete(itob(id))
+	})
+}
+
+// PurgeDeadLetter removes the dead letter forever, the failed final message
+// is considered processed, the others are already settled.
+func (s *Storage) PurgeDeadLetter(id uint64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		dlq := tx.Bucket(dlqBucket)
+		if dlq == nil {
+			return ErrNotFound
+		}
+
+		content := dlq.Get(itob(id))
+		if content == nil {
+			return ErrNotFound
+		}
+
+		var dl DeadLetter
+		if err := json.Unmarshal(content, &dl); err != nil {
+			return err
+		}
+
+		if err := dlq.Delete(itob(id)); err != nil {
+			return err
+		}
+
+		if err := clearDeliveries(tx, dl.Item.Key); err != nil {
+			return err
+		}
+
+		if dl.Settled {
+			return nil
+		}
+
+		_, err := settle(tx, dl.Item.Msg.ID(), -1)
+		return err
+	})
+}
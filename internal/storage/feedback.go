@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+var feedbackBucket = []byte("feedback")
+
+// Feedback is a user reaction to the summary, collected for
+// improving the analyzer and the summarizer.
+type Feedback struct {
+	MessageID string `json:"message_id"`
+	Action    string `json:"action"`
+
+	// Verdict is set, when user corrects the verdict of the summary.
+	Verdict string `json:"verdict,omitempty"`
+
+	// Summary is a text of the summary at the moment of the feedback.
+	Summary string    `json:"summary,omitempty"`
+	UserID  int64     `json:"user_id"`
+	At      time.Time `json:"at"`
+}
+
+func (s *Storage) SaveFeedback(f *Feedback) error {
+	return s.append(feedbackBucket, f)
+}
+
+// Feedback returns all collected feedback in the order it was saved.
+func (s *Storage) Feedback() ([]Feedback, error) {
+	var feedback []Feedback
+	err := s.each(feedbackBucket, func(_, v []byte) error {
+		var f Feedback
+		if e := json.Unmarshal(v, &f); e != nil {
+			return e
+		}
+
+		feedback = append(feedback, f)
+		return nil
+	})
+
+	return feedback, err
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	"go.etcd.io/bbolt"
+	"strings"
+	"time"
+)
+
+func queueBucket(stage string) []byte {
+	return []byte("queue:" + stage)
+}
+
+// QueueItem is a message waiting to be processed by the pipeline stage.
+type QueueItem struct {
+	Key string      `json:"key"`
+	Msg *entity.Msg `json:"msg"`
+
+	// Summary is set only for the summarized messages.
+	Summary    string `json:"summary,omitempty"`
+	Summarized bool   `json:"summarized,omitempty"`
+
+	EnqueuedAt time.Time `json:"enqueued_at"`
+
+	// IngestedAt is a time, when the original message entered the
+	// pipeline, it's kept by the messages derived from it.
+	IngestedAt time.Time `json:"ingested_at,omitempty"`
+
+	// Attempts is a number of failed attempts, the next one
+	// shouldn't be made before NextAttemptAt.
+	Attempts      int       `json:"attempts,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// QueueKey identifies the message inside the stage queue, the same message
+// may be labeled twice with different labels, so the label is a part of it.
+func QueueKey(msg entity.Message) string {
+	return msg.ID() + "|" + msg.Label()
+}
+
+func msgIDFromKey(key string) string {
+	id, _, _ := strings.Cut(key, "|")
+	return id
+}
+
+func newQueueItem(msg entity.Message, now, ingestedAt time.Time) (*QueueItem, error) {
+	item := &QueueItem{Key: QueueKey(msg), EnqueuedAt: now, IngestedAt: ingestedAt}
+
+	switch m := msg.(type) {
+	case *entity.Msg:
+		item.Msg = m
+	case *entity.SummaryMsg:
+		inner, ok := m.Message.(*entity.Msg)
+		if !ok {
+			return nil, fmt.Errorf("unknown message type: %T", m.Message)
+		}
+
+		item.Msg, item.Summary, item.Summarized = inner, m.Text(), true
+	default:
+		return nil, fmt.Errorf("unknown message type: %T", msg)
+	}
+
+	return item, nil
+}
+
+// Message restores the message, as it was enqueued.
+func (i *QueueItem) Message() entity.Message {
+	if i.Summarized {
+		return entity.NewSummaryMsg(i.Msg, i.Summary)
+	}
+
+	return i.Msg
+}
+
+// Enqueue adds the message to the queues of the stages in a single
+// transaction, messages which are already queued are skipped.
+func (s *Storage) Enqueue(msg entity.Message, stages ...string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		added, err := enqueue(tx, msg, time.Now(), stages)
+		if err != nil {
+			return err
+		}
+
+		_, err = settle(tx, msg.ID(), added)
+		return err
+	})
+}
+
+// Ingest adds the new message to the queues of the first stages and starts
+// tracking its completion: when all messages derived from it leave the
+// queues, the message itself is released to the final stage.
+//
+// Messages, which are already tracked, are skipped, returning false.
+func (s *Storage) Ingest(msg entity.Message, finalStage string, stages ...string) (bool, error) {
+	var ingested bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(trackerBucket)
+		if err != nil {
+			return err
+		}
+
+		if b.Get([]byte(msg.ID())) != nil {
+			return nil
+		}
+
+		now := time.Now()
+		final, err := newQueueItem(msg, now, now)
+		if err != nil {
+			return err
+		}
+
+		if err = putTracker(b, msg.ID(), &tracker{Final: *final, FinalStage: finalStage}); err != nil {
+			return err
+		}
+
+		added, err := enqueue(tx, msg, now, stages)
+		if err != nil {
+			return err
+		}
+
+		ingested = true
+		_, err = settle(tx, msg.ID(), added)
+		return err
+	})
+
+	return ingested, err
+}
+
+// Forward is a result of the stage and the stages it's passed to.
+type Forward struct {
+	Msg    entity.Message
+	Stages []string
+}
+
+// Advance removes the message from the stage queue and enqueues the
+// results to the next stages atomically, so the message is never lost
+// between the stages; no results mean there is nothing to pass further.
+//
+// Returns true, when it was the last pending message of the tracked one,
+// and the tracked message was released to the final stage.
+func (s *Storage) Advance(stage, key string, results ...Forward) (bool, error) {
+	var released bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			delta      = 0
+			ingestedAt = time.Now()
+		)
+
+		if b := tx.Bucket(queueBucket(stage)); b != nil {
+			item, err := getQueueItem(b, key)
+			switch {
+			case err == nil:
+				if !item.IngestedAt.IsZero() {
+					ingestedAt = item.IngestedAt
+				}
+
+				if err = b.Delete([]byte(key)); err != nil {
+					return err
+				}
+
+				delta--
+			case !errors.Is(err, ErrNotFound):
+				return err
+			}
+		}
+
+		for _, r := range results {
+			added, err := enqueue(tx, r.Msg, ingestedAt, r.Stages)
+			if err != nil {
+				return err
+			}
+
+			delta += added
+		}
+
+		var err error
+		released, err = settle(tx, msgIDFromKey(key), delta)
+		return err
+	})
+
+	return released, err
+}
+
+// Postpone records the failed attempt, the message stays in the queue.
+func (s *Storage) Postpone(stage, key string, attempts int, next time.Time, lastErr string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(queueBucket(stage))
+		if b == nil {
+			return ErrNotFound
+		}
+
+		item, err := getQueueItem(b, key)
+		if err != nil {
+			return err
+		}
+
+		item.Attempts, item.NextAttemptAt, item.LastError = attempts, next, lastErr
+		return putQueueItem(b, item)
+	})
+}
+
+// Queued returns all messages of the stage queue in the key order.
+func (s *Storage) Queued(stage string) ([]QueueItem, error) {
+	var items []QueueItem
+	err := s.each(queueBucket(stage), func(_, v []byte) error {
+		var item QueueItem
+		if e := json.Unmarshal(v, &item); e != nil {
+			return e
+		}
+
+		items = append(items, item)
+		return nil
+	})
+
+	return items, err
+}
+
+// QueueLen returns the number of messages in the stage queue.
+func (s *Storage) QueueLen(stage string) (int, error) {
+	return s.count(queueBucket(stage))
+}
+
+// enqueue returns the number of messages added to the queues.
+func enqueue(tx *bbolt.Tx, msg entity.Message, ingestedAt time.Time, stages []string) (int, error) {
+	item, err := newQueueItem(msg, time.Now(), ingestedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, stage := range stages {
+		b, e := tx.CreateBucketIfNotExists(queueBucket(stage))
+		if e != nil {
+			return added, e
+		}
+
+		if b.Get([]byte(item.Key)) != nil {
+			continue
+		}
+
+		if e = putQueueItem(b, item); e != nil {
+			return added, e
+		}
+
+		added++
+	}
+
+	return added, nil
+}
+
+func getQueueItem(b *bbolt.Bucket, key string) (*QueueItem, error) {
+	content := b.Get([]byte(key))
+	if content == nil {
+		return nil, ErrNotFound
+	}
+
+	var item QueueItem
+	if err := json.Unmarshal(content, &item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+func putQueueItem(b *bbolt.Bucket, item *QueueItem) error {
+	content, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(item.Key), content)
+}
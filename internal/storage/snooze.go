@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+var snoozeBucket = []byte("snoozes")
+
+// Snooze is a summary, which the user asked to remind about later.
+type Snooze struct {
+	ChatID        int64     `json:"chat_id"`
+	ChatMessageID int       `json:"chat_message_id"`
+	MessageID     string    `json:"message_id"`
+	Until         time.Time `json:"until"`
+}
+
+func (s *Snooze) key() []byte {
+	return []byte(strconv.FormatInt(s.ChatID, 10) + ":" + strconv.Itoa(s.ChatMessageID))
+}
+
+// SaveSnooze stores the snooze, snoozing the same chat message again
+// replaces the previous one.
+func (s *Storage) SaveSnooze(sn *Snooze) error {
+	return s.put(snoozeBucket, sn.key(), sn)
+}
+
+// DueSnoozes returns snoozes, which time has come.
+func (s *Storage) DueSnoozes(now time.Time) ([]Snooze, error) {
+	var due []Snooze
+	err := s.each(snoozeBucket, func(_, v []byte) error {
+		var sn Snooze
+		if e := json.Unmarshal(v, &sn); e != nil {
+			return e
+		}
+
+		if !sn.Until.After(now) {
+			due = append(due, sn)
+		}
+
+		return nil
+	})
+
+	return due, err
+}
+
+func (s *Storage) DeleteSnooze(sn *Snooze) error {
+	return s.delete(snoozeBucket, sn.key())
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Storage is an embedded key-value database, used for keeping the state,
+// which must survive restarts, like user feedback and snoozed messages.
+type Storage struct {
+	db *bbolt.DB
+}
+
+// Open opens the database file, creating it and all parent
+// directories if they don't exist.
+func Open(path string) (*Storage, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	db, err := bbolt.Open(filepath.Clean(path), 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// put stores the value as json under the key in the bucket.
+func (s *Storage) put(bucket, key []byte, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b, e := tx.CreateBucketIfNotExists(bucket)
+		if e != nil {
+			return e
+		}
+
+		return b.Put(key, content)
+	})
+}
+
+// append stores the value under the next sequence number of the bucket,
+// keeping the insertion order.
+func (s *Storage) append(bucket []byte, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b, e := tx.CreateBucketIfNotExists(bucket)
+		if e != nil {
+			return e
+		}
+
+		seq, e := b.NextSequence()
+		if e != nil {
+			return e
+		}
+
+		return b.Put(itob(seq), content)
+	})
+}
+
+// each iterates over all values of the bucket in the key order,
+// decoding is done by the caller.
+func (s *Storage) each(bucket []byte, fn func(k, v []byte) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(fn)
+	})
+}
+
+// count returns the number of keys in the bucket.
+func (s *Storage) count(bucket []byte) (int, error) {
+	var n int
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket(bucket); b != nil {
+			n = b.Stats().KeyN
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+func (s *Storage) delete(bucket, key []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete(key)
+	})
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"encoding/json"
+	"github.com/fadyat/i4u/internal/entity"
+	"go.etcd.io/bbolt"
+	"strconv"
+	"time"
+)
+
+var tgCardsBucket = []byte("tg_cards")
+
+// TgCardUpdate is a single summary, reflected on the card.
+type TgCardUpdate struct {
+	MessageID string         `json:"message_id"`
+	Verdict   entity.Verdict `json:"verdict"`
+	At        time.Time      `json:"at"`
+}
+
+// TgCard is a Telegram message, which represents the application
+// in the chat, it's updated when the application status changes.
+type TgCard struct {
+	ChatMessageID int            `json:"chat_message_id"`
+	Updates       []TgCardUpdate `json:"updates"`
+}
+
+func tgCardKey(chatID int64, appKey string) []byte {
+	return []byte(strconv.FormatInt(chatID, 10) + ":" + appKey)
+}
+
+// TgCard returns the card of the application in the chat, ErrNotFound
+// is returned when the application wasn't sent to the chat yet.
+func (s *Storage) TgCard(chatID int64, appKey string) (*TgCard, error) {
+	var card *TgCard
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(tgCardsBucket)
+		if b == nil {
+			return ErrNotFound
+		}
+
+		content := b.Get(tgCardKey(chatID, appKey))
+		if content == nil {
+			return ErrNotFound
+		}
+
+		card = &TgCard{}
+		return json.Unmarshal(content, card)
+	})
+
+	return card, err
+}
+
+func (s *Storage) SaveTgCard(chatID int64, appKey string, card *TgCard) error {
+	return s.put(tgCardsBucket, tgCardKey(chatID, appKey), card)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+)
+
+var trackerBucket = []byte("trackers")
+
+// tracker counts pending messages derived from the ingested one, the
+// dead-lettered ones aren't pending, the ingested message is released to
+// the final stage, when nothing is pending.
+type tracker struct {
+	Pending    int       `json:"pending"`
+	Final      QueueItem `json:"final"`
+	FinalStage string    `json:"final_stage"`
+
+	// Released is set, when the final message is queued,
+	// the tracker is removed after it's processed.
+	Released bool `json:"released"`
+}
+
+func putTracker(b *bbolt.Bucket, msgID string, t *tracker) error {
+	content, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(msgID), content)
+}
+
+// settle applies the change of pending messages to the tracker of the
+// message, untracked messages are ignored. Returns true, when the final
+// message was released.
+func settle(tx *bbolt.Tx, msgID string, delta int) (bool, error) {
+	b := tx.Bucket(trackerBucket)
+	if b == nil || delta == 0 {
+		return false, nil
+	}
+
+	content := b.Get([]byte(msgID))
+	if content == nil {
+		return false, nil
+	}
+
+	var t tracker
+	if err := json.Unmarshal(content, &t); err != nil {
+		return false, err
+	}
+
+	t.Pending += delta
+	if t.Pending > 0 {
+		return false, putTracker(b, msgID, &t)
+	}
+
+	if t.Released {
+		return false, b.Delete([]byte(msgID))
+	}
+
+	q, err := tx.CreateBucketIfNotExists(queueBucket(t.FinalStage))
+	if err != nil {
+		return false, err
+	}
+
+	if err = putQueueItem(q, &t.Final); err != nil {
+		return false, err
+	}
+
+	t.Pending, t.Released = 1, true
+	return true, putTracker(b, msgID, &t)
+}
+
+// isFinal reports, whether the item is the released final message
+// of the message it was derived from.
+func isFinal(tx *bbolt.Tx, item *QueueItem) bool {
+	b := tx.Bucket(trackerBucket)
+	if b == nil {
+		return false
+	}
+
+	content := b.Get([]byte(item.Msg.ID()))
+	if content == nil {
+		return false
+	}
+
+	var t tracker
+	if err := json.Unmarshal(content, &t); err != nil {
+		return false
+	}
+
+	return t.Released && t.Final.Key == item.Key
+}
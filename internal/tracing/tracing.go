@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/fadyat/i4u/internal/entity"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strings"
+)
+
+// Exporters of the spans, the OTLP endpoint is configured with the standard
+// OTEL_EXPORTER_OTLP_* environment variables.
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Attributes of the spans.
+const (
+	AttrMessageID = attribute.Key("i4u.message.id")
+	AttrStage     = attribute.Key("i4u.stage")
+	AttrVerdict   = attribute.Key("i4u.verdict")
+)
+
+// propagator serializes the span context into the message,
+// so the stages continue the trace of the message after the restart.
+var propagator = propagation.TraceContext{}
+
+// Setup installs the global tracer provider, which sends the spans to the
+// exporter; without the exporter, the spans are dropped. Returned function
+// flushes the spans left in memory.
+func Setup(ctx context.Context, exporter, version string) (func(context.Context) error, error) {
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+
+	switch exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		exp, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", exporter)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName("i4u"),
+			semconv.ServiceVersion(version),
+		)),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
+	return tp.Shutdown, nil
+}
+
+// Start starts the span with the global tracer.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer("github.com/fadyat/i4u").Start(ctx, name, opts...)
+}
+
+// Fail marks the span as failed.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// TraceParent serializes the span context of the context,
+// empty when there is no span.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// tracedMessage is implemented by the messages, which remember
+// the trace they belong to.
+type tracedMessage interface {
+	TraceParent() string
+}
+
+// WithMessage returns the context, which continues the trace of the message.
+func WithMessage(ctx context.Context, msg entity.Message) context.Context {
+	traced, ok := msg.(tracedMessage)
+	if !ok || traced.TraceParent() == "" {
+		return ctx
+	}
+
+	return propagator.Extract(ctx, propagation.MapCarrier{"traceparent": traced.TraceParent()})
+}
+
+// HTTPClient creates the client, which makes a span for every request,
+// long polling of the Telegram updates is skipped, it's just noise.
+func HTTPClient() *http.Client {
+	return &http.Client{
+		Transport: otelhttp.NewTransport(
+			http.DefaultTransport,
+			otelhttp.WithFilter(func(r *http.Request) bool {
+				return !strings.HasSuffix(r.URL.Path, "/getUpdates")
+			}),
+		),
+	}
+}
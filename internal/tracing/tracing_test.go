@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/fadyat/i4u/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestWithMessage(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "gmail.get_message")
+	msg := entity.NewMsg("0", "i4u", "kek", true).WithTraceParent(TraceParent(ctx))
+	root.End()
+
+	// the message is persisted between the stages.
+	content, err := json.Marshal(msg)
+	require.NoError(t, err)
+
+	var restored entity.Msg
+	require.NoError(t, json.Unmarshal(content, &restored))
+
+	_, child := tracer.Start(WithMessage(context.Background(), &restored), "stage.analyzer")
+	child.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, spans[0].SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+	assert.Equal(t, spans[0].SpanContext().SpanID(), spans[1].Parent().SpanID())
+
+	// the summary continues the trace of the message it's made of.
+	summarized := WithMessage(context.Background(), entity.NewSummaryMsg(&restored, "summary"))
+	assert.Equal(t, spans[0].SpanContext().TraceID(), trace.SpanContextFromContext(summarized).TraceID())
+}
+
+func TestWithMessage_Untraced(t *testing.T) {
+	ctx := WithMessage(context.Background(), entity.NewMsg("0", "i4u", "kek", true))
+	assert.False(t, trace.SpanContextFromContext(ctx).IsValid())
+	assert.Empty(t, TraceParent(context.Background()))
+}
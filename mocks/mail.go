@@ -70,6 +70,20 @@ func (_m *Mail) LabelMsg(_a0 context.Context, _a1 entity.MessageForLabeler) erro
 	return r0
 }
 
+// ModifyLabels provides a mock function with given fields: ctx, msgID, add, remove
+func (_m *Mail) ModifyLabels(ctx context.Context, msgID string, add []string, remove []string) error {
+	ret := _m.Called(ctx, msgID, add, remove)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, []string) error); ok {
+		r0 = rf(ctx, msgID, add, remove)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewMail creates a new instance of Mail. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMail(t interface {
@@ -0,0 +1,15 @@
+package parser
+
+import "regexp"
+
+var (
+	emailRe = regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`)
+	phoneRe = regexp.MustCompile(`\+?\d[\d\s().-]{7,}\d`)
+)
+
+// Redact masks personal data in the text, like emails and phone numbers,
+// before it's sent to the third-party services.
+func Redact(text string) string {
+	text = emailRe.ReplaceAllString(text, "[email]")
+	return phoneRe.ReplaceAllString(text, "[phone]")
+}